@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var applyVelero = &veleroFlags{}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Set the computed restore-resource-priorities on the Velero server Deployment",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		clients, err := conn.clients()
+		if err != nil {
+			return err
+		}
+
+		graph, err := discover(ctx)
+		if err != nil {
+			return err
+		}
+		priorities := graph.Priorities()
+
+		deploy, err := applyVelero.get(ctx, clients)
+		if err != nil {
+			return err
+		}
+
+		current, _, err := restoreorder.GetDeploymentPriorities(deploy, applyVelero.container)
+		if err != nil {
+			return err
+		}
+		if current == priorities {
+			slog.Info("restore priorities already up to date", "deployment", deploy.GetName())
+			return scanWarnings(graph)
+		}
+
+		if err := restoreorder.SetDeploymentPriorities(deploy, applyVelero.container, priorities); err != nil {
+			return err
+		}
+		restoreorder.NewProvenance(graph, provenanceCluster()).Annotate(deploy)
+		deployments := clients.dynamic.Resource(restoreorder.DeploymentResource).Namespace(veleroNamespace)
+		if _, err := deployments.Update(ctx, deploy, v1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("cannot update velero deployment: %w", err)
+		}
+
+		slog.Info("updated restore priorities", "deployment", deploy.GetName())
+		return scanWarnings(graph)
+	},
+}
+
+func init() {
+	applyVelero.addFlags(applyCmd.Flags())
+	rootCmd.AddCommand(applyCmd)
+}
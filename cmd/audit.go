@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Report what a restore of the scanned resources will need attention for",
+	Args:  cobra.NoArgs,
+}
+
+var auditUIDsCmd = &cobra.Command{
+	Use:   "uids",
+	Short: "List the kinds whose owner references will point at UIDs that no longer exist after a restore",
+	Long: `List the scanned kinds whose owners are scanned, and so backed up, too.
+A restore recreates the owners with new UIDs while the owner references of
+their children keep the UIDs of the backed up owners, so the controllers of
+these kinds have to tolerate or re-adopt the restored children.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if importFile != "" {
+			return fmt.Errorf("audit uids cannot be used with --import, exported graphs do not record owner references")
+		}
+		graph, err := discover(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		w := cmd.OutOrStdout()
+		for _, r := range graph.Remapped {
+			fmt.Fprintf(w, "%s (%d resources): owned by %s\n", r.Resource, r.Count, strings.Join(r.Owners, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	auditCmd.AddCommand(auditUIDsCmd)
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var (
+	fromChart    string
+	chartValues  []string
+	chartVersion string
+)
+
+// chartClients returns clients serving the CRDs and custom resources of
+// --from-chart, rendered client side the way helm template renders an install
+func chartClients(ctx context.Context) (*clients, error) {
+	manifest, err := renderChart(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot render chart %s: %w", fromChart, err)
+	}
+
+	manifests, err := restoreorder.ReadManifests(strings.NewReader(manifest))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse chart %s: %w", fromChart, err)
+	}
+	dynamicClient, metadataClient, err := restoreorder.ManifestClients(manifests)
+	if err != nil {
+		return nil, err
+	}
+	return &clients{dynamic: dynamicClient, metadata: metadataClient}, nil
+}
+
+// renderChart renders --from-chart with its CRDs and hooks, reading the
+// repositories and registry credentials of the helm environment
+func renderChart(ctx context.Context) (string, error) {
+	settings := cli.New()
+	registryClient, err := registry.NewClient(
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+		registry.ClientOptWriter(io.Discard),
+	)
+	if err != nil {
+		return "", fmt.Errorf("cannot create registry client: %w", err)
+	}
+
+	install := action.NewInstall(&action.Configuration{})
+	install.SetRegistryClient(registryClient)
+	install.ReleaseName = "whoisyourdaddyandwhatdoeshedo"
+	install.Namespace = settings.Namespace()
+	install.Version = chartVersion
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = true
+
+	path, err := install.ChartPathOptions.LocateChart(fromChart, settings)
+	if err != nil {
+		return "", err
+	}
+	chart, err := loader.Load(path)
+	if err != nil {
+		return "", err
+	}
+	vals, err := (&values.Options{ValueFiles: chartValues}).MergeValues(getter.All(settings))
+	if err != nil {
+		return "", err
+	}
+
+	release, err := install.RunWithContext(ctx, chart, vals)
+	if err != nil {
+		return "", err
+	}
+	rendered := &strings.Builder{}
+	rendered.WriteString(release.Manifest)
+	for _, hook := range release.Hooks {
+		fmt.Fprintf(rendered, "\n---\n%s\n", hook.Manifest)
+	}
+	return rendered.String(), nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var (
+	checkVelero = &veleroFlags{}
+	checkFlags  struct {
+		file      string
+		configMap string
+	}
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check a stored restore-resource-priorities value is still up to date",
+	Long: `Compute the restore order and compare it against a stored reference: a file,
+a ConfigMap key or, by default, the value the Velero server Deployment runs
+with. The command exits 0 when they match, 3 when they have drifted apart
+(printing a diff), 2 when they match but the scan skipped CRDs or found
+orphans and 1 when the check cannot be made, so it can run as a CronJob
+that alerts when newly installed CRDs change what a restore needs.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		reference, current, err := checkReference(cmd)
+		if err != nil {
+			return err
+		}
+
+		graph, err := discover(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		drifted, err := restoreorder.Diff(cmd.OutOrStdout(), reference, "computed", current, graph.Priorities())
+		if err != nil {
+			return err
+		}
+		if drifted {
+			return withExitCode(ExitDrift, fmt.Errorf("restore priorities of %s differ from the computed order", reference))
+		}
+		return scanWarnings(graph)
+	},
+}
+
+// checkReference returns the name and value of the reference to check against
+func checkReference(cmd *cobra.Command) (string, string, error) {
+	ctx := cmd.Context()
+	switch {
+	case checkFlags.file != "":
+		data, err := os.ReadFile(checkFlags.file)
+		if err != nil {
+			return "", "", fmt.Errorf("cannot read reference: %w", err)
+		}
+		return checkFlags.file, strings.TrimSpace(string(data)), nil
+
+	case checkFlags.configMap != "":
+		namespace, name, key, err := parseConfigMapRef(checkFlags.configMap)
+		if err != nil {
+			return "", "", err
+		}
+		clients, err := conn.clients()
+		if err != nil {
+			return "", "", err
+		}
+		cm, err := clients.dynamic.Resource(restoreorder.ConfigMapResource).Namespace(namespace).Get(ctx, name, v1.GetOptions{})
+		if err != nil {
+			return "", "", fmt.Errorf("cannot get configmap %s/%s: %w", namespace, name, err)
+		}
+		value, ok, _ := unstructured.NestedString(cm.Object, "data", key)
+		if !ok {
+			return "", "", fmt.Errorf("configmap %s/%s has no key %s", namespace, name, key)
+		}
+		return fmt.Sprintf("configmap/%s (namespace %s, key %s)", name, namespace, key), value, nil
+	}
+
+	clients, err := conn.clients()
+	if err != nil {
+		return "", "", err
+	}
+	deploy, err := checkVelero.get(ctx, clients)
+	if err != nil {
+		return "", "", err
+	}
+	value, _, err := restoreorder.GetDeploymentPriorities(deploy, checkVelero.container)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("deployment/%s (namespace %s)", deploy.GetName(), deploy.GetNamespace()), value, nil
+}
+
+func init() {
+	checkVelero.addFlags(checkCmd.Flags())
+	checkCmd.Flags().StringVar(&checkFlags.file, "reference-file", "", "file holding the reference restore-resource-priorities value")
+	checkCmd.Flags().StringVar(&checkFlags.configMap, "reference-configmap", "", "ConfigMap namespace/name[#key] holding the reference restore-resource-priorities value")
+	checkCmd.MarkFlagsMutuallyExclusive("reference-file", "reference-configmap")
+	rootCmd.AddCommand(checkCmd)
+}
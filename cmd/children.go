@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var childrenInstances bool
+
+var childrenCmd = &cobra.Command{
+	Use:   "children <resource>",
+	Short: "List the kinds owned by a resource",
+	Long: `List every kind the discovered graph orders after a resource
+(e.g. nodegroupdeployments.eks.example.com) because the resource owns it,
+directly or through other kinds. This is the inverse of explain and shows
+what depends on a CRD before deleting or renaming it.
+
+With --instances the objects of the directly owned kinds that have an
+owner of the resource's kind are listed too.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		graph, err := discover(cmd.Context())
+		if err != nil {
+			return err
+		}
+		children, err := graph.Children(args[0])
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if len(children) == 0 {
+			fmt.Fprintf(out, "%s owns no kinds\n", args[0])
+			return nil
+		}
+		for _, child := range children {
+			fmt.Fprintf(out, "%s%s\n", strings.Repeat("  ", child.Depth-1), child.Resource)
+			if !childrenInstances || child.Depth > 1 {
+				continue
+			}
+			if err := printOwnedObjects(cmd, graph, args[0], child.Resource); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// printOwnedObjects writes the objects of resource owned by objects of owner
+func printOwnedObjects(cmd *cobra.Command, graph *restoreorder.Graph, owner, resource string) error {
+	clients, err := conn.clients()
+	if err != nil {
+		return err
+	}
+	mapper, err := conn.restMapper()
+	if err != nil {
+		return err
+	}
+	res, err := mapper.ResourceFor(schema.ParseGroupResource(resource).WithVersion(""))
+	if err != nil {
+		return fmt.Errorf("cannot find resource %s: %w", resource, err)
+	}
+	ownerKind, _ := graph.Kind(owner)
+
+	objects, err := restoreorder.OwnedObjects(cmd.Context(), clients.metadata, res, ownerKind)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		name := obj.Name
+		if obj.Namespace != "" {
+			name = obj.Namespace + "/" + obj.Name
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "    - %s\n", name)
+	}
+	return nil
+}
+
+func init() {
+	childrenCmd.Flags().BoolVar(&childrenInstances, "instances", false, "also list the objects of the directly owned kinds")
+	rootCmd.AddCommand(childrenCmd)
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// GraphConfigMapKey is the ConfigMap key the JSON graph is written to
+const GraphConfigMapKey = "graph.json"
+
+var computeFlags = struct {
+	outputConfigMap string
+	failOnOrphans   bool
+	output          string
+	veleroManifest  string
+	summary         bool
+	gitWrite        string
+	perNamespace    bool
+	maxLength       int
+	report          string
+}{}
+
+var computeVelero = &veleroFlags{}
+
+var computeCmd = &cobra.Command{
+	Use:   "compute",
+	Short: "Print the restore-resource-priorities flag for the cluster",
+	Args:  cobra.NoArgs,
+	RunE:  runCompute,
+}
+
+func init() {
+	addComputeFlags(computeCmd.Flags())
+	// running without a subcommand computes the order so takes the same flags
+	addComputeFlags(rootCmd.Flags())
+	rootCmd.AddCommand(computeCmd)
+}
+
+func addComputeFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&computeFlags.outputConfigMap, "output-configmap", "", "also write the priorities and JSON graph to the ConfigMap namespace/name[#key]")
+	flags.StringVarP(&computeFlags.output, "output", "o", "flag", "output format, one of "+strings.Join(outputFormats, ", "))
+	flags.StringVar(&computeFlags.veleroManifest, "velero-manifest", "", "manifest holding the Velero server Deployment to build patches against, instead of the live Deployment")
+	computeVelero.addFlags(flags)
+	flags.BoolVar(&computeFlags.failOnOrphans, "fail-on-orphans", false, "exit 1 rather than 2 when resources whose owners are missing are found")
+	flags.StringVar(&computeFlags.gitWrite, "git-write", "", "open a pull request updating the priorities in a Git repository when they drift, as repo=org/name,path=values.yaml[,branch=main][,provider=github|gitlab][,format=helm-values|kustomize-patch][,url=API URL], authenticated with $GITHUB_TOKEN or $GITLAB_TOKEN")
+	flags.BoolVar(&computeFlags.perNamespace, "per-namespace", false, "also compute the order of every namespace from its resources and the cluster-scoped ones, printed as a YAML map of namespace to priorities")
+	flags.IntVar(&computeFlags.maxLength, "max-length", restoreorder.DefaultMaxPrioritiesLength, "warn when the priorities value is longer than this, 0 to never warn")
+	flags.StringVar(&computeFlags.report, "report", "", "write every CRD skipped, list error, orphaned reference, ownership cycle and ambiguous plural of the scan to this file as JSON with machine-readable codes")
+	flags.BoolVar(&computeFlags.summary, "summary", false, "print statistics about the scan to stderr: CRDs, resources, edges, longest owner chain, largest fan-out and kinds adding nothing")
+}
+
+func runCompute(cmd *cobra.Command, _ []string) error {
+	if !slices.Contains(outputFormats, computeFlags.output) {
+		return fmt.Errorf("unknown output format %q, must be one of %s", computeFlags.output, strings.Join(outputFormats, ", "))
+	}
+	if computeFlags.perNamespace && computeFlags.output != "flag" {
+		return fmt.Errorf("--per-namespace only supports the flag output format")
+	}
+	if computeFlags.perNamespace && importFile != "" {
+		return fmt.Errorf("--per-namespace cannot be used with --import, exported graphs do not record namespaces")
+	}
+
+	graph, err := discover(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	// add final order to end of default order
+	_, span := otel.Tracer(restoreorder.TracerName).Start(cmd.Context(), "Order")
+	priorities := graph.Priorities()
+	span.End()
+	if computeFlags.maxLength > 0 && len(priorities) > computeFlags.maxLength {
+		hint := "leave kinds that own nothing out with --compact or --minimal"
+		if graph.Minimal {
+			hint = "narrow the scan with --include-group, --ignore-group or --ignore-category"
+		}
+		slog.Warn("priorities value is longer than --max-length", "length", len(priorities), "max", computeFlags.maxLength, "hint", hint)
+	}
+	velero := func() (*unstructured.Unstructured, error) {
+		if computeFlags.veleroManifest != "" {
+			return readDeployment(computeFlags.veleroManifest, computeVelero.deployment)
+		}
+		clients, err := conn.clients()
+		if err != nil {
+			return nil, err
+		}
+		return computeVelero.get(cmd.Context(), clients)
+	}
+	provenance := restoreorder.NewProvenance(graph, provenanceCluster())
+	if computeFlags.perNamespace {
+		if err := writeNamespaces(cmd.OutOrStdout(), graph.Namespaces); err != nil {
+			return err
+		}
+	} else if err := writeOutput(cmd.OutOrStdout(), computeFlags.output, graph, provenance, velero); err != nil {
+		return err
+	}
+
+	if computeFlags.outputConfigMap != "" {
+		namespace, name, key, err := parseConfigMapRef(computeFlags.outputConfigMap)
+		if err != nil {
+			return err
+		}
+
+		graphJSON, err := json.Marshal(graph)
+		if err != nil {
+			return fmt.Errorf("cannot encode graph: %w", err)
+		}
+
+		clients, err := conn.clients()
+		if err != nil {
+			return err
+		}
+		if err := restoreorder.ApplyConfigMap(cmd.Context(), clients.dynamic, namespace, name, map[string]string{
+			key:               priorities,
+			GraphConfigMapKey: string(graphJSON),
+		}, provenance); err != nil {
+			return err
+		}
+	}
+
+	if computeFlags.gitWrite != "" {
+		target, err := restoreorder.ParseGitTarget(computeFlags.gitWrite)
+		if err != nil {
+			return err
+		}
+		target.Token = os.Getenv("GITHUB_TOKEN")
+		if target.Provider == "gitlab" {
+			target.Token = os.Getenv("GITLAB_TOKEN")
+		}
+		url, err := target.Write(cmd.Context(), priorities)
+		if err != nil {
+			return err
+		}
+		if url != "" {
+			slog.Info("opened pull request", "url", url)
+		}
+	}
+
+	// the audit goes to stderr so stdout only holds the flag
+	audit := auditWriter(cmd.ErrOrStderr())
+	printOrphans(audit, graph.Orphans)
+	printCrossScope(audit, graph.CrossScope)
+	printWebhooks(audit, graph.Webhooks)
+	printFluxDependsOn(audit, graph.FluxDependsOn)
+	printOperators(audit, graph.Operators)
+	printSkipped(audit, graph.Skipped)
+	if computeFlags.summary {
+		printSummary(audit, graph.Summary())
+	}
+	if computeFlags.report != "" {
+		if err := writeReport(computeFlags.report, graph.Report()); err != nil {
+			return err
+		}
+	}
+	if computeFlags.failOnOrphans && len(graph.Orphans) > 0 {
+		return fmt.Errorf("found %d resources whose owners are missing", len(graph.Orphans))
+	}
+	return scanWarnings(graph)
+}
+
+// printOrphans writes the audit of resources whose owners are missing
+func printOrphans(w io.Writer, orphans []restoreorder.Orphan) {
+	if len(orphans) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "resources whose owners are missing (they will dangle after a restore):")
+	for _, o := range orphans {
+		name := o.Name
+		if o.Namespace != "" {
+			name = o.Namespace + "/" + o.Name
+		}
+		reason := "owner not found"
+		if o.Reason == restoreorder.OrphanOwnerKindMissing {
+			reason = "owner kind not served by the cluster"
+		}
+		fmt.Fprintf(w, "  %s %s: owner %s %s (%s)\n", o.Kind, name, o.Owner.Kind, o.Owner.Name, reason)
+	}
+}
+
+// printCrossScope writes the audit of owner references crossing a scope boundary
+func printCrossScope(w io.Writer, crossScope []restoreorder.CrossScopeOwner) {
+	if len(crossScope) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "owner references crossing a scope boundary (ignored by the garbage collector, likely an operator bug):")
+	for _, c := range crossScope {
+		name := c.Name
+		if c.Namespace != "" {
+			name = c.Namespace + "/" + c.Name
+		}
+		owner := c.Owner.Name
+		if c.OwnerNamespace != "" {
+			owner = c.OwnerNamespace + "/" + c.Owner.Name
+		}
+		fmt.Fprintf(w, "  %s %s: owner %s %s\n", c.Kind, name, c.Owner.Kind, owner)
+	}
+}
+
+// printSummary writes the statistics of the scan
+func printSummary(w io.Writer, s restoreorder.Summary) {
+	fmt.Fprintln(w, "summary:")
+	fmt.Fprintf(w, "  CRDs scanned: %d\n", s.CRDs)
+	fmt.Fprintf(w, "  resources listed: %d\n", s.Resources)
+	fmt.Fprintf(w, "  ownership edges: %d\n", s.Edges)
+	fmt.Fprintf(w, "  longest owner chain: %d\n", s.MaxDepth)
+	if s.MaxFanOut != "" {
+		fmt.Fprintf(w, "  largest fan-out: %s owns %d kinds\n", s.MaxFanOut, s.FanOut)
+	}
+	if len(s.Unrelated) > 0 {
+		fmt.Fprintf(w, "  kinds adding nothing to the order (%d):\n", len(s.Unrelated))
+		for _, name := range s.Unrelated {
+			fmt.Fprintf(w, "    %s\n", name)
+		}
+	}
+	if len(s.NotEstablished) > 0 {
+		fmt.Fprintf(w, "  CRDs skipped as they are not established (%d):\n", len(s.NotEstablished))
+		for _, name := range s.NotEstablished {
+			fmt.Fprintf(w, "    %s\n", name)
+		}
+	}
+	if len(s.Removed) > 0 {
+		fmt.Fprintf(w, "  CRDs removed during the scan (%d):\n", len(s.Removed))
+		for _, name := range s.Removed {
+			fmt.Fprintf(w, "    %s\n", name)
+		}
+	}
+}
+
+// writeReport writes report to path as indented JSON
+func writeReport(path string, report restoreorder.Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return nil
+}
+
+// parseConfigMapRef parses a namespace/name[#key] reference to a ConfigMap key
+func parseConfigMapRef(ref string) (string, string, string, error) {
+	ref, key, _ := strings.Cut(ref, "#")
+	if key == "" {
+		key = restoreorder.DefaultConfigMapKey
+	}
+
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", "", fmt.Errorf("invalid configmap %q, expected namespace/name[#key]", ref)
+	}
+	return namespace, name, key, nil
+}
+
+// printWebhooks writes the resources that need a webhook backend to be restored
+func printWebhooks(w io.Writer, webhooks []restoreorder.WebhookDependency) {
+	if len(webhooks) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "resources that need a webhook backend running to be restored:")
+	for _, dep := range webhooks {
+		webhook := "conversion webhook"
+		if dep.Webhook != "" {
+			webhook = "webhook configuration " + dep.Webhook
+		}
+		if dep.Service != "" {
+			webhook += " served by " + dep.Service
+		}
+		fmt.Fprintf(w, "  %s: %s\n", dep.Resource, webhook)
+	}
+}
+
+// printFluxDependsOn writes the Flux objects depending on objects missing from the scan
+func printFluxDependsOn(w io.Writer, deps []restoreorder.FluxDependency) {
+	if len(deps) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "flux objects depending on objects missing from the scan (flux will not reconcile them after a restore):")
+	for _, dep := range deps {
+		name := dep.Name
+		if dep.Namespace != "" {
+			name = dep.Namespace + "/" + dep.Name
+		}
+		fmt.Fprintf(w, "  %s %s: depends on %s\n", dep.Kind, name, dep.DependsOn)
+	}
+}
+
+// printOperators writes the operator deployments found serving the scanned CRDs
+func printOperators(w io.Writer, operators []restoreorder.Operator) {
+	if len(operators) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "operators to restore before their resources:")
+	for _, op := range operators {
+		fmt.Fprintf(w, "  %s: deployment %s (found by %s)\n", op.Resource, op.Deployment, op.Via)
+	}
+}
+
+// printSkipped writes the resources a best effort scan could not list
+func printSkipped(w io.Writer, skipped restoreorder.ListErrors) {
+	if len(skipped) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "resources that could not be listed, the order may be incomplete:")
+	for _, err := range skipped {
+		fmt.Fprintf(w, "  %s: %v\n", err.Resource, err.Err)
+	}
+}
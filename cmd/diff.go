@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var diffVelero = &veleroFlags{}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the computed restore-resource-priorities against the Velero server Deployment",
+	Long: `Compare the computed restore-resource-priorities against the value the
+Velero server Deployment is running with, printing a unified diff and
+exiting 3 when they differ, or 2 when they match but the scan skipped
+CRDs or found orphans.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		clients, err := conn.clients()
+		if err != nil {
+			return err
+		}
+
+		graph, err := discover(ctx)
+		if err != nil {
+			return err
+		}
+		priorities := graph.Priorities()
+
+		deploy, err := diffVelero.get(ctx, clients)
+		if err != nil {
+			return err
+		}
+
+		current, _, err := restoreorder.GetDeploymentPriorities(deploy, diffVelero.container)
+		if err != nil {
+			return err
+		}
+
+		live := fmt.Sprintf("deployment/%s (namespace %s)", deploy.GetName(), deploy.GetNamespace())
+		drifted, err := restoreorder.Diff(cmd.OutOrStdout(), live, "computed", current, priorities)
+		if err != nil {
+			return err
+		}
+		if drifted {
+			return withExitCode(ExitDrift, fmt.Errorf("restore priorities of %s differ from the computed order", live))
+		}
+		return scanWarnings(graph)
+	},
+}
+
+func init() {
+	diffVelero.addFlags(diffCmd.Flags())
+	rootCmd.AddCommand(diffCmd)
+}
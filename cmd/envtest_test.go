@@ -0,0 +1,89 @@
+//go:build integration
+
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+	restoreordertesting "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder/testing"
+)
+
+// writeKubeconfig writes a kubeconfig authenticating as config does
+func writeKubeconfig(t *testing.T, config *rest.Config) string {
+	t.Helper()
+	kubeconfig := clientcmdapi.NewConfig()
+	kubeconfig.Clusters["envtest"] = &clientcmdapi.Cluster{Server: config.Host, CertificateAuthorityData: config.CAData}
+	kubeconfig.AuthInfos["envtest"] = &clientcmdapi.AuthInfo{ClientCertificateData: config.CertData, ClientKeyData: config.KeyData, Token: config.BearerToken}
+	kubeconfig.Contexts["envtest"] = &clientcmdapi.Context{Cluster: "envtest", AuthInfo: "envtest"}
+	kubeconfig.CurrentContext = "envtest"
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := clientcmd.WriteToFile(*kubeconfig, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// discoverWith discovers the graph of the cluster the connection flags f point at
+func discoverWith(t *testing.T, f *connectionFlags, opts restoreorder.Options) (*restoreorder.Graph, error) {
+	t.Helper()
+	clients, err := f.clients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return restoreorder.Discover(context.Background(), clients.dynamic, clients.metadata, opts)
+}
+
+// TestRBACRules checks a service account bound to the rules the rbac command
+// prints can scan the cluster, impersonating it with --as-service-account
+func TestRBACRules(t *testing.T) {
+	config := restoreordertesting.StartEnv(t, "../pkg/restoreorder/testing/testdata/stack")
+	kubeconfig := writeKubeconfig(t, config)
+
+	admin, err := discoverWith(t, &connectionFlags{kubeconfig: kubeconfig}, restoreorder.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreordertesting.ServiceAccountConfig(t, config, "velero", "scanner", rbacRules(admin))
+
+	t.Run("service account bound to the rules", func(t *testing.T) {
+		graph, err := discoverWith(t, &connectionFlags{kubeconfig: kubeconfig, serviceAccount: "velero/scanner"}, restoreorder.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := graph.Priorities(), admin.Priorities(); got != want {
+			t.Errorf("computed\n%s\nas the service account, want\n%s", got, want)
+		}
+	})
+
+	t.Run("service account without a binding", func(t *testing.T) {
+		_, err := discoverWith(t, &connectionFlags{kubeconfig: kubeconfig, serviceAccount: "velero/nobody"}, restoreorder.Options{BestEffort: true})
+		if !apierrors.IsForbidden(err) {
+			t.Errorf("got error %v, want listing CRDs to be forbidden", err)
+		}
+	})
+
+	t.Run("service account groups", func(t *testing.T) {
+		// the groups of --as-service-account are those the API server
+		// authenticates the tokens of the service account as
+		f := &connectionFlags{kubeconfig: kubeconfig, serviceAccount: "velero/scanner", groups: []string{"scanners"}}
+		clients, err := f.clients()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := config.Host + " system:serviceaccount:velero:scanner"; clients.cluster != want {
+			t.Errorf("got cluster %q, want %q", clients.cluster, want)
+		}
+		if _, err := restoreorder.Discover(context.Background(), clients.dynamic, clients.metadata, restoreorder.Options{}); err != nil {
+			t.Errorf("cannot scan as the service account with an extra group: %s", err)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// the codes the process exits with, so wrappers can branch on the outcome
+const (
+	// ExitOK is a command that completed without problems
+	ExitOK = 0
+	// ExitError is a command that failed
+	ExitError = 1
+	// ExitWarnings is a command that completed but skipped CRDs, found
+	// orphans or found entries Velero cannot resolve
+	ExitWarnings = 2
+	// ExitDrift is a check or diff that found the priorities drifted
+	ExitDrift = 3
+)
+
+// exitError is an error the process exits with a specific code for
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode makes the process exit with code when err is returned
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// ExitCode returns the code the process exits with for the error returned
+// by Execute, ExitError unless the command asked for another
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+	return ExitError
+}
+
+// scanWarnings returns an error exiting with ExitWarnings when the scan of
+// graph skipped CRDs or resources or found orphans, nil otherwise
+func scanWarnings(graph *restoreorder.Graph) error {
+	warnings := []string{}
+	if n := len(graph.Skipped); n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d resources could not be listed", n))
+	}
+	if n := len(graph.NotEstablished) + len(graph.Removed); n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d CRDs were skipped", n))
+	}
+	if n := len(graph.Orphans); n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d resources have missing owners", n))
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	return withExitCode(ExitWarnings, errors.New(strings.Join(warnings, ", ")))
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <resource>",
+	Short: "Show why a resource is ordered where it is",
+	Long: `Show the owner chains discovered for a resource (e.g. nodegroups.eks.example.com),
+its position in the computed restore-resource-priorities and the owners
+that forced that position.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		graph, err := discover(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		explanation, err := graph.Explain(args[0])
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		priorities := restoreorder.ParsePriorities(graph.Priorities())
+		if pos := slices.Index(priorities, explanation.Resource); pos >= 0 {
+			fmt.Fprintf(out, "%s is at position %d of %d\n", explanation.Resource, pos+1, len(priorities))
+		} else {
+			fmt.Fprintf(out, "%s is not listed and is restored after every listed resource\n", explanation.Resource)
+		}
+		fmt.Fprintf(out, "owner depth: %d\n", explanation.Depth)
+
+		if len(explanation.Forcing) == 0 {
+			fmt.Fprintln(out, "it has no owners")
+			return nil
+		}
+		fmt.Fprintf(out, "ordered after: %s\n", strings.Join(explanation.Forcing, ", "))
+
+		fmt.Fprintln(out, "owner chains:")
+		for _, chain := range explanation.Chains {
+			fmt.Fprintf(out, "  %s\n", strings.Join(chain, " -> "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/maps"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	// register the oidc auth provider and the azure and gcp providers, which
+	// point users at their exec credential plugins (exec is built into client-go)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// connectionFlags are the flags shared by every subcommand that talks to a cluster
+type connectionFlags struct {
+	kubeconfig     string
+	contexts       []string
+	allContexts    bool
+	inCluster      bool
+	user           string
+	serviceAccount string
+	uid            string
+	groups         []string
+	extra          []string
+}
+
+func (f *connectionFlags) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.kubeconfig, "kubeconfig", "", "(optional) absolute path to the kubeconfig file, defaults to $KUBECONFIG or ~/.kube/config")
+	flags.StringArrayVar(&f.contexts, "context", nil, "(optional) name of the kubeconfig context to use, defaults to the current context. can be repeated to merge the graphs of several clusters, the first context is the one changed by apply")
+	flags.BoolVar(&f.allContexts, "all-contexts", false, "merge the graphs of the clusters of every kubeconfig context")
+	flags.BoolVar(&f.inCluster, "in-cluster", false, "use the in-cluster service account configuration instead of a kubeconfig")
+	flags.StringVar(&f.user, "as", "", "user to impersonate")
+	flags.StringVar(&f.serviceAccount, "as-service-account", "", "service account to impersonate as namespace/name, e.g. velero/velero, setting the user and the service account groups")
+	flags.StringVar(&f.uid, "as-uid", "", "UID to impersonate")
+	flags.StringArrayVar(&f.groups, "as-group", nil, "group to impersonate, can be repeated to specify multiple groups")
+	flags.StringArrayVar(&f.extra, "as-extra", nil, "extra field to impersonate as key=value, can be repeated, a key given more than once has every value")
+}
+
+// toRESTConfig builds the client configuration described by the flags
+func (f *connectionFlags) toRESTConfig() (*rest.Config, error) {
+	kubecontext := ""
+	if len(f.contexts) > 0 {
+		kubecontext = f.contexts[0]
+	}
+	config, err := buildConfig(f.kubeconfig, kubecontext, f.inCluster)
+	if err != nil {
+		return nil, fmt.Errorf("cannot build client: %w", err)
+	}
+
+	impersonate, err := f.impersonationConfig()
+	if err != nil {
+		return nil, err
+	}
+	// leave any impersonation configured in the kubeconfig alone unless asked to
+	if impersonate != nil {
+		config.Impersonate = *impersonate
+	}
+
+	return config, nil
+}
+
+// impersonationConfig returns the impersonation described by the flags, nil
+// when none is requested. like kubectl, groups, a UID or extras can only be
+// impersonated along with a user or service account
+func (f *connectionFlags) impersonationConfig() (*rest.ImpersonationConfig, error) {
+	if f.user == "" && f.serviceAccount == "" && f.uid == "" && len(f.groups) == 0 && len(f.extra) == 0 {
+		return nil, nil
+	}
+	if f.user != "" && f.serviceAccount != "" {
+		return nil, fmt.Errorf("--as and --as-service-account cannot be used together")
+	}
+	if f.user == "" && f.serviceAccount == "" {
+		return nil, fmt.Errorf("--as-group, --as-uid and --as-extra require --as or --as-service-account")
+	}
+
+	impersonate := &rest.ImpersonationConfig{
+		UserName: f.user,
+		UID:      f.uid,
+		Groups:   f.groups,
+	}
+	if f.serviceAccount != "" {
+		namespace, name, ok := strings.Cut(f.serviceAccount, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid --as-service-account %q, expected namespace/name", f.serviceAccount)
+		}
+		// the user and groups the API server authenticates the tokens of the service account as
+		impersonate.UserName = fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+		impersonate.Groups = slices.Concat([]string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"}, f.groups)
+	}
+	for _, extra := range f.extra {
+		key, value, ok := strings.Cut(extra, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --as-extra %q, expected key=value", extra)
+		}
+		if impersonate.Extra == nil {
+			impersonate.Extra = map[string][]string{}
+		}
+		impersonate.Extra[key] = append(impersonate.Extra[key], value)
+	}
+	return impersonate, nil
+}
+
+type clients struct {
+	dynamic  dynamic.Interface
+	metadata metadata.Interface
+	// discovery is nil for clients that serve manifests
+	discovery discovery.DiscoveryInterface
+	// cluster identifies the cluster and user the clients talk to,
+	// empty for clients that serve manifests
+	cluster string
+}
+
+// clients creates the clients used to talk to the cluster
+func (f *connectionFlags) clients() (*clients, error) {
+	config, err := f.toRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, metadataClient, err := restoreorder.NewClients(config)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create discovery client: %w", err)
+	}
+
+	return &clients{
+		dynamic:   dynamicClient,
+		metadata:  metadataClient,
+		discovery: discoveryClient,
+		cluster:   config.Host + " " + config.Impersonate.UserName,
+	}, nil
+}
+
+// restMapper returns a mapper between the kinds and resources the cluster serves
+func (f *connectionFlags) restMapper() (meta.RESTMapper, error) {
+	config, err := f.toRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create discovery client: %w", err)
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client)), nil
+}
+
+// clusterContexts returns the kubeconfig contexts whose graphs are merged,
+// more than one only with a repeated --context or --all-contexts
+func (f *connectionFlags) clusterContexts() ([]string, error) {
+	if !f.allContexts {
+		return f.contexts, nil
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = f.kubeconfig
+	config, err := rules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load kubeconfig: %w", err)
+	}
+	contexts := maps.Keys(config.Contexts)
+	slices.Sort(contexts)
+	return contexts, nil
+}
+
+// forContext returns the flags with every setting but the context kept
+func (f *connectionFlags) forContext(name string) *connectionFlags {
+	c := *f
+	c.contexts = []string{name}
+	c.allContexts = false
+	return &c
+}
+
+// buildConfig loads the client configuration the same way kubectl does,
+// merging every file in $KUBECONFIG unless an explicit path is given
+// and using the current context unless one is named.
+// when no kubeconfig can be found (e.g. when running as a Job) the
+// in-cluster configuration is used instead
+func buildConfig(kubeconfig, kubecontext string, inCluster bool) (*rest.Config, error) {
+	if inCluster {
+		return rest.InClusterConfig()
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: kubecontext,
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if clientcmd.IsEmptyConfig(err) {
+		slog.Info("no kubeconfig found, falling back to in-cluster configuration")
+		return rest.InClusterConfig()
+	}
+	return config, err
+}
+
+// veleroFlags locate the Velero server Deployment in the Velero namespace
+type veleroFlags struct {
+	deployment string
+	container  string
+}
+
+func (f *veleroFlags) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&f.deployment, "velero-deployment", "velero", "name of the Velero server Deployment")
+	flags.StringVar(&f.container, "velero-container", "velero", "name of the Velero server container")
+}
+
+// get fetches the Velero server Deployment
+func (f *veleroFlags) get(ctx context.Context, c *clients) (*unstructured.Unstructured, error) {
+	deploy, err := c.dynamic.Resource(restoreorder.DeploymentResource).Namespace(veleroNamespace).Get(ctx, f.deployment, v1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get velero deployment: %w", err)
+	}
+	return deploy, nil
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print the ownership graph between custom resource kinds in DOT format",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		graph, err := discover(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return graph.WriteDOT(cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/discovery"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var lintPriorities string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check every restore-resource-priorities entry resolves to a resource the cluster serves",
+	Long: `Check every entry of the computed restore-resource-priorities value, or of
+--priorities, against the resources the cluster serves. Velero silently
+ignores entries it cannot resolve at restore time, so the command reports
+entries of API groups that are not served, entries no served resource is
+named after and plurals without a group that several groups serve, and
+exits 2 when it finds any.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if fromDir != "" || fromStdin {
+			return fmt.Errorf("lint checks the entries against the resources a cluster serves, it cannot be used with --from-dir or --from-stdin")
+		}
+
+		priorities := lintPriorities
+		if priorities == "" {
+			graph, err := discover(cmd.Context())
+			if err != nil {
+				return err
+			}
+			priorities = graph.Priorities()
+		}
+
+		clients, err := conn.clients()
+		if err != nil {
+			return err
+		}
+		// the groups that cannot be discovered are reported as missing
+		served, err := discovery.ServerPreferredResources(clients.discovery)
+		if failed := (&discovery.ErrGroupDiscoveryFailed{}); errors.As(err, &failed) {
+			for gv, err := range failed.Groups {
+				fmt.Fprintf(cmd.ErrOrStderr(), "cannot discover %s: %s\n", gv, err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("cannot discover served resources: %w", err)
+		}
+
+		issues := restoreorder.Lint(restoreorder.ParsePriorities(priorities), served)
+		for _, issue := range issues {
+			fmt.Fprintln(cmd.OutOrStdout(), issue)
+		}
+		if len(issues) > 0 {
+			return withExitCode(ExitWarnings, fmt.Errorf("found %d entries Velero cannot resolve", len(issues)))
+		}
+		return nil
+	},
+}
+
+func init() {
+	lintCmd.Flags().StringVar(&lintPriorities, "priorities", "", "restore-resource-priorities value to lint instead of the computed one")
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+var (
+	logLevel  string
+	logFormat string
+	quiet     bool
+)
+
+// setupLogging sends every log line to stderr in the format and at the level
+// the flags ask for, so stdout only ever holds the output of the command
+func setupLogging() error {
+	level := slog.LevelInfo
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q, expected debug, info, warn or error", logLevel)
+	}
+	if quiet {
+		level = slog.LevelError
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, expected text or json", logFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// auditWriter returns where the audits of a scan are written,
+// nowhere with --quiet
+func auditWriter(w io.Writer) io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	return w
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// outputFormats are the values --output accepts
+var outputFormats = []string{"flag", "delta", "helm-values", "kustomize-patch", "kubectl-patch", "tree", "resource-modifiers", "restore-hooks"}
+
+// ResourceModifiersConfigMap is the name of the ConfigMap the resource-modifiers format writes
+const ResourceModifiersConfigMap = "whoisyourdaddyandwhatdoeshedo-resource-modifiers"
+
+// writeOutput writes the restore order of graph in format, velero returns
+// the Velero server Deployment for the formats that patch it.
+// the formats written to files are headed by the provenance of the order
+func writeOutput(w io.Writer, format string, graph *restoreorder.Graph, provenance restoreorder.Provenance, velero func() (*unstructured.Unstructured, error)) error {
+	switch format {
+	case "flag":
+		// the flag as the velero server takes it
+		_, err := fmt.Fprintf(w, "%s=%s\n", restoreorder.RestoreFlag, graph.Priorities())
+		return err
+	case "delta":
+		// only the entries to append to a value already holding the default order
+		_, err := fmt.Fprintln(w, graph.Additions())
+		return err
+	case "helm-values":
+		// the values of the vmware-tanzu/velero chart, resource names
+		// are plain ASCII so a Go quoted string is a valid YAML string
+		_, err := fmt.Fprintf(w, "%sconfiguration:\n  restoreResourcePriorities: %q\n", provenance.Comment(), graph.Priorities())
+		return err
+	case "kustomize-patch":
+		deploy, patch, err := prioritiesPatch(graph, velero)
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(patch)
+		if err != nil {
+			return fmt.Errorf("cannot encode patch: %w", err)
+		}
+		_, err = fmt.Fprintf(w, `%s# JSON 6902 patch for the Velero server Deployment, add it to kustomization.yaml with
+# patches:
+# - path: <this file>
+#   target:
+#     group: apps
+#     version: v1
+#     kind: Deployment
+#     name: %s
+#     namespace: %s
+%s`, provenance.Comment(), deploy.GetName(), deploy.GetNamespace(), data)
+		return err
+	case "kubectl-patch":
+		deploy, patch, err := prioritiesPatch(graph, velero)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(patch)
+		if err != nil {
+			return fmt.Errorf("cannot encode patch: %w", err)
+		}
+		// single quote the patch for the shell, closing the quotes around any quote in it
+		quoted := "'" + strings.ReplaceAll(string(data), "'", `'\''`) + "'"
+		_, err = fmt.Fprintf(w, "kubectl -n %s patch deployment %s --type=json -p %s\n", deploy.GetNamespace(), deploy.GetName(), quoted)
+		return err
+	case "tree":
+		// the ownership graph with instance counts, for people rather than velero
+		return graph.WriteTree(w)
+	case "resource-modifiers":
+		// complements the order, so the restored custom resources reconcile
+		cm, err := graph.ResourceModifiersConfigMap(veleroNamespace, ResourceModifiersConfigMap, provenance)
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(cm.Object)
+		if err != nil {
+			return fmt.Errorf("cannot encode configmap: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s# restore with velero restore create --resource-modifier-configmap %s\n%s", provenance.Comment(), ResourceModifiersConfigMap, data)
+		return err
+	case "restore-hooks":
+		// the hooks of a Restore spec waiting for the webhook backends
+		hooks := graph.RestoreHooks()
+		data, err := yaml.Marshal(map[string]interface{}{"spec": map[string]interface{}{"hooks": hooks}})
+		if err != nil {
+			return fmt.Errorf("cannot encode restore hooks: %w", err)
+		}
+		header := &strings.Builder{}
+		header.WriteString("# merge into the spec of a velero Restore, hooks run in restored pods only so\n")
+		header.WriteString("# they make the restore wait for the webhook backends rather than hold back their dependents\n")
+		for _, service := range graph.WebhookServices() {
+			fmt.Fprintf(header, "# %s serves %s\n", service, strings.Join(graph.WebhookDependents(service), ", "))
+		}
+		_, err = fmt.Fprintf(w, "%s%s%s", provenance.Comment(), header, data)
+		return err
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// writeNamespaces writes the priorities of every namespace as a YAML map
+// of namespace to priorities, sorted by namespace
+func writeNamespaces(w io.Writer, graphs map[string]*restoreorder.Graph) error {
+	priorities := map[string]string{}
+	for namespace, graph := range graphs {
+		priorities[namespace] = graph.Priorities()
+	}
+	data, err := yaml.Marshal(priorities)
+	if err != nil {
+		return fmt.Errorf("cannot encode namespace priorities: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// prioritiesPatch returns the Velero server Deployment and the JSON patch
+// setting its restore-resource-priorities flag to the priorities of graph
+func prioritiesPatch(graph *restoreorder.Graph, velero func() (*unstructured.Unstructured, error)) (*unstructured.Unstructured, []restoreorder.PatchOperation, error) {
+	deploy, err := velero()
+	if err != nil {
+		return nil, nil, err
+	}
+	patch, err := restoreorder.DeploymentPrioritiesPatch(deploy, computeVelero.container, graph.Priorities())
+	if err != nil {
+		return nil, nil, err
+	}
+	return deploy, patch, nil
+}
+
+// readDeployment returns the named Deployment from a YAML or JSON manifest
+// holding one or more documents
+func readDeployment(path, name string) (*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read manifest: %w", err)
+	}
+	defer f.Close()
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("manifest %s has no Deployment named %s", path, name)
+			}
+			return nil, fmt.Errorf("cannot parse manifest %s: %w", path, err)
+		}
+		if obj.GetKind() == "Deployment" && obj.GetName() == name {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(veleroNamespace)
+			}
+			return obj, nil
+		}
+	}
+}
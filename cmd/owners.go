@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var ownersCmd = &cobra.Command{
+	Use:   "owners <resource> [<namespace>/]<name>",
+	Short: "Show the owner chain of a single object",
+	Long: `Follow the owner references of a single object (e.g. nodegroups.eks.example.com
+default/workers) upwards by UID through the live cluster and print every
+owner above it, to debug why its resource is ordered where it is.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, name, ok := strings.Cut(args[1], "/")
+		if !ok {
+			namespace, name = "", args[1]
+		}
+
+		clients, err := conn.clients()
+		if err != nil {
+			return err
+		}
+		mapper, err := conn.restMapper()
+		if err != nil {
+			return err
+		}
+		res, err := mapper.ResourceFor(schema.ParseGroupResource(args[0]).WithVersion(""))
+		if err != nil {
+			return fmt.Errorf("cannot find resource %s: %w", args[0], err)
+		}
+
+		ancestor, err := restoreorder.Ancestry(cmd.Context(), clients.metadata, mapper, res, namespace, name)
+		if err != nil {
+			return err
+		}
+		printAncestor(cmd.OutOrStdout(), ancestor, 0)
+		return nil
+	},
+}
+
+// printAncestor writes a and its owners, indented by depth
+func printAncestor(w io.Writer, a restoreorder.Ancestor, depth int) {
+	name := a.Name
+	if a.Namespace != "" {
+		name = a.Namespace + "/" + a.Name
+	}
+	notes := []string{}
+	if a.Controller {
+		notes = append(notes, "controller")
+	}
+	if a.Missing != "" {
+		notes = append(notes, a.Missing)
+	}
+	line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", depth), a.Resource, name)
+	if len(notes) > 0 {
+		line += " (" + strings.Join(notes, ", ") + ")"
+	}
+	fmt.Fprintln(w, line)
+
+	for _, owner := range a.Owners {
+		printAncestor(w, owner, depth+1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(ownersCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+var (
+	cpuProfile string
+	memProfile string
+	// stopProfiling writes the profiles once the command returns
+	stopProfiling = func() {}
+)
+
+// startProfiling starts the CPU profile of --cpuprofile and arranges for
+// it and the heap profile of --memprofile to be written by stopProfiling
+func startProfiling() error {
+	var cpu *os.File
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return fmt.Errorf("cannot create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return fmt.Errorf("cannot start CPU profile: %w", err)
+		}
+		cpu = f
+	}
+
+	stopProfiling = func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+		}
+		if memProfile != "" {
+			if err := writeHeapProfile(memProfile); err != nil {
+				slog.Error("cannot write memory profile", "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// writeHeapProfile writes the heap profile to path
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// collect garbage so the profile shows up to date allocations
+	runtime.GC()
+	return pprof.WriteHeapProfile(f)
+}
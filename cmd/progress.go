@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// how often the scan progress is written, on a terminal the line is
+// rewritten in place so it can be written much more often
+const (
+	progressInterval         = 10 * time.Second
+	terminalProgressInterval = 200 * time.Millisecond
+)
+
+// newProgress returns the Options.Progress writing how far a scan got to f,
+// rewriting a single line when f is a terminal and writing a line every
+// progressInterval otherwise, e.g. in CI logs
+func newProgress(f *os.File) func(restoreorder.ScanProgress) {
+	terminal := false
+	if info, err := f.Stat(); err == nil {
+		terminal = info.Mode()&os.ModeCharDevice != 0
+	}
+	interval := progressInterval
+	if terminal {
+		interval = terminalProgressInterval
+	}
+
+	last := time.Now()
+	return func(p restoreorder.ScanProgress) {
+		finished := p.Done == p.Total
+		if !finished && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+
+		line := fmt.Sprintf("scanned %d/%d CRDs, %d resources listed", p.Done, p.Total, p.Resources)
+		if !finished && p.ETA() > 0 {
+			line += fmt.Sprintf(", about %s left", p.ETA().Round(time.Second))
+		}
+		if !terminal {
+			fmt.Fprintln(f, line)
+			return
+		}
+		// clear the rest of the previous line, which may be longer
+		fmt.Fprintf(f, "\r\033[K%s", line)
+		if finished {
+			fmt.Fprintln(f)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	rbacv1 "k8s.io/api/rbac/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var rbacFlags struct {
+	name           string
+	serviceAccount string
+}
+
+var rbacCmd = &cobra.Command{
+	Use:   "rbac",
+	Short: "Print the ClusterRole and ClusterRoleBinding needed to run the tool read-only",
+	Long: `Print the ClusterRole and ClusterRoleBinding needed to run the tool read-only
+against the cluster, granting get and list on CustomResourceDefinitions and
+list on every custom resource discovered with the current flags.
+
+The scan needs to be able to list CustomResourceDefinitions, run it with
+credentials that can before handing the output to a cluster admin.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		namespace, name, ok := strings.Cut(rbacFlags.serviceAccount, "/")
+		if !ok || namespace == "" || name == "" {
+			return fmt.Errorf("invalid service account %q, expected namespace/name", rbacFlags.serviceAccount)
+		}
+
+		graph, err := discover(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		role := &rbacv1.ClusterRole{
+			TypeMeta:   v1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: v1.ObjectMeta{Name: rbacFlags.name},
+			Rules:      rbacRules(graph),
+		}
+		binding := &rbacv1.ClusterRoleBinding{
+			TypeMeta:   v1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: v1.ObjectMeta{Name: rbacFlags.name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: rbacFlags.name},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: namespace, Name: name}},
+		}
+
+		for i, obj := range []any{role, binding} {
+			data, err := yaml.Marshal(obj)
+			if err != nil {
+				return fmt.Errorf("cannot marshal rbac: %w", err)
+			}
+			if i > 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "---")
+			}
+			cmd.OutOrStdout().Write(data)
+		}
+		return nil
+	},
+}
+
+// rbacRules returns the rules a scan with the current flags needs, one per
+// group and set of verbs
+func rbacRules(graph *restoreorder.Graph) []rbacv1.PolicyRule {
+	verbs := map[schema.GroupResource][]string{
+		{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}: {"get", "list"},
+		restoreorder.ValidatingWebhookResource.GroupResource():                 {"list"},
+		restoreorder.MutatingWebhookResource.GroupResource():                   {"list"},
+	}
+	for _, name := range graph.Resources {
+		verbs[schema.ParseGroupResource(name)] = []string{"list"}
+	}
+	if includeBuiltin {
+		for _, res := range restoreorder.BuiltinChildResources {
+			verbs[res.GVR.GroupResource()] = []string{"list"}
+		}
+	}
+	if operatorsFirst {
+		// the builtin children may list them already
+		deployments, services := restoreorder.DeploymentResource.GroupResource(), restoreorder.ServiceResource.GroupResource()
+		if !slices.Contains(verbs[deployments], "list") {
+			verbs[deployments] = append(verbs[deployments], "list")
+		}
+		verbs[services] = append([]string{"get"}, verbs[services]...)
+	}
+	switch {
+	case forBackup != "":
+		verbs[restoreorder.BackupResource.GroupResource()] = []string{"get"}
+	case forSchedule != "":
+		verbs[restoreorder.ScheduleResource.GroupResource()] = []string{"get"}
+	}
+
+	rules := []rbacv1.PolicyRule{}
+	for res, v := range verbs {
+		i := slices.IndexFunc(rules, func(r rbacv1.PolicyRule) bool {
+			return r.APIGroups[0] == res.Group && slices.Equal(r.Verbs, v)
+		})
+		if i < 0 {
+			rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{res.Group}, Verbs: v})
+			i = len(rules) - 1
+		}
+		rules[i].Resources = append(rules[i].Resources, res.Resource)
+	}
+	for _, r := range rules {
+		slices.Sort(r.Resources)
+	}
+	slices.SortFunc(rules, func(a, b rbacv1.PolicyRule) int {
+		if c := strings.Compare(a.APIGroups[0], b.APIGroups[0]); c != 0 {
+			return c
+		}
+		return slices.Compare(a.Verbs, b.Verbs)
+	})
+	return rules
+}
+
+func init() {
+	rbacCmd.Flags().StringVar(&rbacFlags.name, "name", "whoisyourdaddyandwhatdoeshedo", "name of the ClusterRole and ClusterRoleBinding")
+	rbacCmd.Flags().StringVar(&rbacFlags.serviceAccount, "service-account", "velero/whoisyourdaddyandwhatdoeshedo", "namespace/name of the service account to bind the ClusterRole to")
+	rootCmd.AddCommand(rbacCmd)
+}
@@ -0,0 +1,443 @@
+// Package cmd implements the command line interface.
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var (
+	conn                = &connectionFlags{}
+	pageSize            int64
+	respectVeleroLabels bool
+	veleroNamespace     string
+	forBackup           string
+	forSchedule         string
+	includeNamespaces   []string
+	excludeNamespaces   []string
+	selector            string
+	includeBuiltin      bool
+	detectorNames       []string
+	execDetectors       []string
+	syncWaves           bool
+	hintsFile           string
+	inferSpecRefs       bool
+	nonControllerOwners bool
+	qualify             string
+	tiebreak            string
+	noProgress          bool
+	compact             bool
+	minimal             bool
+	includeGroups       []string
+	ignoreGroups        []string
+	includeCategories   []string
+	ignoreCategories    []string
+	crossScopeOwners    bool
+	webhooksFirst       bool
+	operatorsFirst      bool
+	defaultOrder        string
+	defaultOrderFile    string
+	noDefaultOrder      bool
+	lowPriority         []string
+	unrelatedLow        bool
+	bestEffort          bool
+	strict              bool
+	timeout             time.Duration
+	exportFile          string
+	importFile          string
+	fromDir             string
+	fromStdin           bool
+	listTimeout         time.Duration
+	concurrency         int
+	cacheTTL            time.Duration
+	includeAggregated   bool
+	fieldSelector       string
+	resourceVersion     string
+	// cancelTimeout releases the context of --timeout once the command returns
+	cancelTimeout context.CancelFunc = func() {}
+)
+
+// longRunningAnnotation marks the commands that run until stopped, which
+// apply --timeout to every scan they run rather than to the whole command
+const longRunningAnnotation = "whoisyourdaddy.io/long-running"
+
+var rootCmd = &cobra.Command{
+	Use:   "whoisyourdaddyandwhatdoeshedo",
+	Short: "Compute a Velero restore order for custom resources from their owner references",
+	Long: `Compute a Velero restore order for custom resources from their owner references.
+
+Every command exits 0 on success and 1 on errors. Commands computing the
+order exit 2 when they complete but the scan skipped CRDs or resources or
+found orphans, as does lint when it finds entries Velero cannot resolve,
+and check and diff exit 3 when the priorities drifted.`,
+	// running without a subcommand computes the order, as the tool always has
+	RunE:          runCompute,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	// every subcommand logs, is profiled and traced as configured and, unless
+	// it runs until stopped, runs within --timeout
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		if err := setupLogging(); err != nil {
+			return err
+		}
+		if err := startProfiling(); err != nil {
+			return err
+		}
+		if err := startTracing(cmd); err != nil {
+			return err
+		}
+		if timeout > 0 && cmd.Annotations[longRunningAnnotation] == "" {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			cmd.SetContext(ctx)
+			cancelTimeout = cancel
+		}
+		return nil
+	},
+}
+
+func init() {
+	conn.addFlags(rootCmd.PersistentFlags())
+	rootCmd.MarkFlagsMutuallyExclusive("context", "all-contexts", "in-cluster")
+	rootCmd.PersistentFlags().Int64Var(&pageSize, "page-size", 500, "number of resources to request per list call (0 to disable pagination)")
+	rootCmd.PersistentFlags().BoolVar(&respectVeleroLabels, "respect-velero-labels", true, "leave out CRDs and resources labeled velero.io/exclude-from-backup=true")
+	rootCmd.PersistentFlags().StringVar(&veleroNamespace, "velero-namespace", "velero", "namespace Velero is installed in")
+	rootCmd.PersistentFlags().StringVar(&forBackup, "for-backup", "", "only scan the resources the named Velero Backup contains")
+	rootCmd.PersistentFlags().StringVar(&forSchedule, "for-schedule", "", "only scan the resources the backups of the named Velero Schedule contain")
+	rootCmd.MarkFlagsMutuallyExclusive("for-backup", "for-schedule")
+	rootCmd.PersistentFlags().StringSliceVar(&includeNamespaces, "include-namespaces", nil, "only scan resources in these namespaces (globs allowed), replaces the namespaces of --for-backup/--for-schedule")
+	rootCmd.PersistentFlags().StringSliceVar(&excludeNamespaces, "exclude-namespaces", nil, "do not scan resources in these namespaces (globs allowed)")
+	rootCmd.PersistentFlags().StringSliceVar(&includeGroups, "include-group", nil, "only scan the CRDs of these API groups (globs allowed), e.g. '*.crossplane.io'")
+	rootCmd.PersistentFlags().StringSliceVar(&ignoreGroups, "ignore-group", nil, "do not scan the CRDs of these API groups (globs allowed) on top of the groups always left out, e.g. '*.internal.example.com'")
+	rootCmd.PersistentFlags().StringSliceVar(&includeCategories, "include-category", nil, "only scan the CRDs in one of these categories, e.g. crossplane")
+	rootCmd.PersistentFlags().StringSliceVar(&ignoreCategories, "ignore-category", nil, "do not scan the CRDs in any of these categories")
+	rootCmd.PersistentFlags().StringVarP(&selector, "selector", "l", "", "only scan resources matching this label selector, e.g. app.kubernetes.io/part-of=platform")
+	rootCmd.PersistentFlags().StringVar(&fieldSelector, "field-selector", "", "only scan resources matching this field selector, custom resources support metadata.name and metadata.namespace")
+	rootCmd.PersistentFlags().StringVar(&resourceVersion, "resource-version", "", "set to 0 to serve the lists from the API server cache rather than etcd, lowering the load on large clusters at the cost of possibly stale results")
+	rootCmd.PersistentFlags().StringSliceVar(&detectorNames, "detector", []string{restoreorder.OwnerReferences}, "dependency detectors to run, any of "+strings.Join(restoreorder.DetectorNames(), ", ")+". flux orders Kustomizations and HelmReleases after their sources and reports the spec.dependsOn entries naming objects missing from the scan, velero cannot order objects of the same kind after one another")
+	rootCmd.PersistentFlags().StringArrayVar(&execDetectors, "exec-detector", nil, "executable to run as a detector, given the full objects of the listed API groups with PATH=GROUP,..., repeatable. it reads a JSON object per line on stdin and writes a JSON array of {\"kind\": \"Kind.group\", \"owner\": \"Kind.group\"} edges per line on stdout. an executable that fails, or does not answer within 10s, fails the scan unless --best-effort")
+	rootCmd.PersistentFlags().BoolVar(&syncWaves, "argocd-sync-waves", false, "prefer ordering kinds by the argocd.argoproj.io/sync-wave annotations of their resources wherever ownership allows")
+	rootCmd.PersistentFlags().StringVar(&hintsFile, "hints", "", "YAML file of extra edges and forced positions to merge into the discovered graph")
+	rootCmd.PersistentFlags().BoolVar(&nonControllerOwners, "include-non-controller-owners", false, "also order resources after owners that are not their controller, by default only owner references with controller: true are followed")
+	rootCmd.PersistentFlags().BoolVar(&crossScopeOwners, "include-cross-scope-owners", false, "also order resources after owners in another namespace, or namespaced owners of cluster-scoped resources, which the garbage collector ignores")
+	rootCmd.PersistentFlags().BoolVar(&inferSpecRefs, "infer-spec-refs", false, "infer dependencies from fields such as secretRef or clusterName found in CRD schemas")
+	rootCmd.PersistentFlags().BoolVar(&webhooksFirst, "webhooks-first", false, "order deployments and services before resources that need a conversion or admission webhook to be restored")
+	rootCmd.PersistentFlags().BoolVar(&operatorsFirst, "operators-first", false, "find the operator deployments serving the scanned CRDs, by conversion webhook service, OLM labels or installer labels, and order deployments before their resources")
+	rootCmd.PersistentFlags().StringVar(&defaultOrder, "default-order", "", "comma separated resources to put before the computed order instead of Velero's default order")
+	rootCmd.PersistentFlags().StringVar(&defaultOrderFile, "default-order-file", "", "file of resources, one per line, to put before the computed order instead of Velero's default order")
+	rootCmd.PersistentFlags().BoolVar(&noDefaultOrder, "no-default-order", false, "only output the computed order")
+	rootCmd.MarkFlagsMutuallyExclusive("default-order", "default-order-file", "no-default-order")
+	rootCmd.PersistentFlags().StringSliceVar(&lowPriority, "low-priority-resources", nil, "resources to list after the \"-\" delimiter, restored after every other resource")
+	rootCmd.PersistentFlags().StringVar(&tiebreak, "tiebreak", restoreorder.TiebreakAlpha, "order kinds at the same depth by name, or by their number of resources, most or fewest first, one of "+strings.Join(restoreorder.TiebreakModes, ", "))
+	rootCmd.PersistentFlags().BoolVar(&compact, "compact", false, "leave out the kinds that own nothing, which velero restores after every listed kind anyway, to shorten long priorities values")
+	rootCmd.PersistentFlags().BoolVar(&minimal, "minimal", false, "only list the kinds whose position is required: --compact, also leaving out soft preferences and owners of low priority kinds only")
+	rootCmd.PersistentFlags().StringVar(&qualify, "qualify", restoreorder.QualifyAlways, "write the entries of scanned kinds as plural.group always, only when the plural is ambiguous across groups, or never, one of "+strings.Join(restoreorder.QualifyModes, ", "))
+	rootCmd.PersistentFlags().BoolVar(&unrelatedLow, "unrelated-low-priority", false, "list scanned resources without owners or owned resources as low priority")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", true, "fail, reporting every resource that cannot be listed, rather than produce a possibly incomplete order")
+	rootCmd.PersistentFlags().BoolVar(&bestEffort, "best-effort", false, "skip resources that cannot be listed instead of failing and print a summary of them, the order may be incomplete")
+	rootCmd.MarkFlagsMutuallyExclusive("strict", "best-effort")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "abort the whole run after this long, e.g. 5m (0 for no limit). serve aborts every scan after this long instead, or with --watch gives up on the resources whose informers have not synced after this long (2m when 0)")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", restoreorder.DefaultConcurrency, "number of CRDs whose resources are listed at once")
+	rootCmd.PersistentFlags().DurationVar(&listTimeout, "list-timeout", 0, "give up listing the resources of a single CRD after this long, e.g. 30s (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&exportFile, "export", "", "write the scanned graph to this JSON file so later runs can --import it")
+	rootCmd.PersistentFlags().StringVar(&importFile, "import", "", "read the graph from a JSON file written by --export instead of scanning a cluster, the scan flags of the export apply")
+	rootCmd.MarkFlagsMutuallyExclusive("export", "import")
+	rootCmd.PersistentFlags().StringVar(&fromDir, "from-dir", "", "read CRDs and custom resources from the YAML and JSON manifests under this directory instead of a cluster")
+	rootCmd.PersistentFlags().BoolVar(&fromStdin, "from-stdin", false, "read CRDs and custom resources from YAML or JSON manifests on stdin instead of a cluster")
+	rootCmd.MarkFlagsMutuallyExclusive("from-dir", "from-stdin", "import", "all-contexts")
+	rootCmd.PersistentFlags().StringVar(&fromChart, "from-chart", "", "also render this Helm chart (path, URL or repo/name) as helm template would and merge its CRDs and custom resources into the graph")
+	rootCmd.PersistentFlags().StringArrayVar(&chartValues, "values", nil, "values file to render --from-chart with, can be repeated")
+	rootCmd.PersistentFlags().StringVar(&chartVersion, "chart-version", "", "version of --from-chart to render, defaults to the latest")
+	rootCmd.MarkFlagsMutuallyExclusive("from-chart", "import")
+	rootCmd.MarkFlagsMutuallyExclusive("from-dir", "from-stdin", "context")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "minimum level of the logs written to stderr: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "format of the logs written to stderr: text or json")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only log errors and leave out the audits written to stderr")
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "do not write the progress of listing resources to stderr, e.g. in CI")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 0, "cache the resources and edges found for every CRD on disk for this long and reuse them while the CRD is unchanged, 0 disables the cache")
+	rootCmd.PersistentFlags().StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile of the run to this file")
+	rootCmd.PersistentFlags().StringVar(&memProfile, "memprofile", "", "write a heap profile to this file once the run is done")
+	rootCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "send traces of the run to this OTLP/HTTP endpoint, e.g. http://localhost:4318, the OTEL_EXPORTER_OTLP_* variables configure the export further")
+	rootCmd.PersistentFlags().BoolVar(&includeAggregated, "include-aggregated", false, "also scan the resources served by aggregated API servers (APIServices backed by a service), not only those of CRDs")
+	rootCmd.PersistentFlags().BoolVar(&includeBuiltin, "include-builtin-children", false, "also order built-in resources (Deployments, Services, Secrets, ...) owned by custom resources after their owners")
+}
+
+// Execute runs the command line
+func Execute(ctx context.Context) error {
+	defer func() { cancelTimeout() }()
+	defer func() { stopProfiling() }()
+	defer func() { stopTracing() }()
+	return rootCmd.ExecuteContext(ctx)
+}
+
+// discover returns the ownership graph of the custom resources of the
+// cluster the connection flags point at, read from --import rather than
+// scanned when given and written to --export after a scan
+func discover(ctx context.Context) (*restoreorder.Graph, error) {
+	if importFile != "" {
+		return importGraph(importFile)
+	}
+
+	graph, err := scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exportFile != "" {
+		if err := exportGraph(exportFile, graph); err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+// provenanceCluster names what the graph is discovered from in the
+// provenance recorded with the priorities
+func provenanceCluster() string {
+	sources := []string{}
+	switch {
+	case importFile != "":
+		sources = append(sources, "import "+importFile)
+	case fromDir != "":
+		sources = append(sources, "manifests "+fromDir)
+	case fromStdin:
+		sources = append(sources, "manifests from stdin")
+	case conn.allContexts || len(conn.contexts) > 1:
+		contexts, _ := conn.clusterContexts()
+		sources = append(sources, "contexts "+strings.Join(contexts, ","))
+	case len(conn.contexts) == 1:
+		sources = append(sources, "context "+conn.contexts[0])
+	default:
+		if config, err := conn.toRESTConfig(); err == nil {
+			sources = append(sources, config.Host)
+		}
+	}
+	if fromChart != "" && importFile == "" {
+		sources = append(sources, "chart "+fromChart)
+	}
+	return strings.Join(sources, " and ")
+}
+
+// scan scans the cluster the connection flags point at, or the manifests
+// of --from-dir or --from-stdin.
+// with several contexts every cluster is scanned and with --from-chart the
+// rendered chart too, and their graphs merged
+func scan(ctx context.Context) (*restoreorder.Graph, error) {
+	graphs := map[string]*restoreorder.Graph{}
+	if fromDir != "" || fromStdin {
+		clients, err := manifestClients()
+		if err != nil {
+			return nil, err
+		}
+		if graphs["manifests"], err = scanWith(ctx, clients); err != nil {
+			return nil, err
+		}
+	} else {
+		contexts, err := conn.clusterContexts()
+		if err != nil {
+			return nil, err
+		}
+		if len(contexts) <= 1 {
+			clients, err := conn.clients()
+			if err != nil {
+				return nil, err
+			}
+			if graphs["cluster"], err = scanWith(ctx, clients); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, name := range contexts {
+				clients, err := conn.forContext(name).clients()
+				if err != nil {
+					return nil, fmt.Errorf("cannot scan context %s: %w", name, err)
+				}
+				if graphs["context "+name], err = scanWith(ctx, clients); err != nil {
+					return nil, fmt.Errorf("cannot scan context %s: %w", name, err)
+				}
+			}
+		}
+	}
+
+	if fromChart != "" {
+		clients, err := chartClients(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if graphs["chart "+fromChart], err = scanWith(ctx, clients); err != nil {
+			return nil, fmt.Errorf("cannot scan chart %s: %w", fromChart, err)
+		}
+	}
+
+	if len(graphs) == 1 {
+		for _, graph := range graphs {
+			return graph, nil
+		}
+	}
+	return restoreorder.MergeGraphs(graphs)
+}
+
+// scanWith discovers the graph served by clients
+func scanWith(ctx context.Context, clients *clients) (*restoreorder.Graph, error) {
+	opts, err := scanOptions(ctx, clients)
+	if err != nil {
+		return nil, err
+	}
+	if cacheTTL > 0 && clients.cluster != "" {
+		path, err := cachePath(clients.cluster)
+		if err != nil {
+			return nil, err
+		}
+		opts.Cache = restoreorder.OpenCache(path, cacheTTL)
+	}
+	if !noProgress && !quiet {
+		opts.Progress = newProgress(os.Stderr)
+	}
+
+	graph, err := restoreorder.Discover(ctx, clients.dynamic, clients.metadata, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Cache != nil {
+		if err := opts.Cache.Save(); err != nil {
+			slog.Warn("cannot save cache", "error", err)
+		}
+	}
+	return graph, nil
+}
+
+// cachePath returns the file the cache of cluster is kept in
+func cachePath(cluster string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(cluster))
+	return filepath.Join(dir, "whoisyourdaddyandwhatdoeshedo", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// scanOptions returns the options the cluster is scanned with
+func scanOptions(ctx context.Context, clients *clients) (restoreorder.Options, error) {
+	opts := restoreorder.Options{
+		PageSize:                   pageSize,
+		IgnoreGroups:               slices.Concat(restoreorder.DefaultIgnoreGroups, ignoreGroups),
+		IncludeGroups:              includeGroups,
+		IncludeCategories:          includeCategories,
+		IgnoreCategories:           ignoreCategories,
+		RespectVeleroLabels:        respectVeleroLabels,
+		IncludeBuiltinChildren:     includeBuiltin,
+		SyncWaves:                  syncWaves,
+		InferSpecRefs:              inferSpecRefs,
+		IncludeNonControllerOwners: nonControllerOwners,
+		IncludeCrossScopeOwners:    crossScopeOwners,
+		WebhooksFirst:              webhooksFirst,
+		OperatorsFirst:             operatorsFirst,
+		LowPriority:                lowPriority,
+		UnrelatedLowPriority:       unrelatedLow,
+		Qualify:                    qualify,
+		Tiebreak:                   tiebreak,
+		Compact:                    compact,
+		Minimal:                    minimal,
+		BestEffort:                 bestEffort || !strict,
+		ListTimeout:                listTimeout,
+		Concurrency:                concurrency,
+		FieldSelector:              fieldSelector,
+		ResourceVersion:            resourceVersion,
+		PerNamespace:               computeFlags.perNamespace,
+	}
+
+	if !slices.Contains(restoreorder.QualifyModes, qualify) {
+		return opts, fmt.Errorf("invalid --qualify %q, must be one of %s", qualify, strings.Join(restoreorder.QualifyModes, ", "))
+	}
+	if !slices.Contains(restoreorder.TiebreakModes, tiebreak) {
+		return opts, fmt.Errorf("invalid --tiebreak %q, must be one of %s", tiebreak, strings.Join(restoreorder.TiebreakModes, ", "))
+	}
+	if _, err := fields.ParseSelector(fieldSelector); err != nil {
+		return opts, fmt.Errorf("invalid field selector: %w", err)
+	}
+	if resourceVersion != "" && resourceVersion != "0" {
+		return opts, fmt.Errorf("invalid resource version %q, only 0 is supported", resourceVersion)
+	}
+
+	if includeAggregated {
+		if clients.discovery == nil {
+			slog.Warn("manifests have no aggregated APIs, ignoring --include-aggregated")
+		} else {
+			opts.Aggregated = clients.discovery
+		}
+	}
+
+	var err error
+	opts.Detectors, err = restoreorder.LookupDetectors(detectorNames...)
+	if err != nil {
+		return opts, err
+	}
+	for _, value := range execDetectors {
+		path, groups, _ := strings.Cut(value, "=")
+		if path == "" {
+			return opts, fmt.Errorf("invalid --exec-detector %q, expected PATH[=GROUP,...]", value)
+		}
+		d := restoreorder.NewExecDetector(path, nil)
+		if groups != "" {
+			d.Groups = strings.Split(groups, ",")
+		}
+		opts.Detectors = append(opts.Detectors, d)
+	}
+
+	switch {
+	case noDefaultOrder:
+		opts.DefaultOrder = []string{}
+	case defaultOrder != "":
+		opts.DefaultOrder = restoreorder.ParseDefaultOrder(defaultOrder)
+	case defaultOrderFile != "":
+		data, err := os.ReadFile(defaultOrderFile)
+		if err != nil {
+			return opts, fmt.Errorf("cannot read default order: %w", err)
+		}
+		opts.DefaultOrder = restoreorder.ParseDefaultOrder(string(data))
+	}
+
+	if hintsFile != "" {
+		opts.Hints, err = restoreorder.LoadHints(hintsFile)
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	switch {
+	case forBackup != "":
+		opts.Scope, err = restoreorder.ScopeForBackup(ctx, clients.dynamic, veleroNamespace, forBackup)
+	case forSchedule != "":
+		opts.Scope, err = restoreorder.ScopeForSchedule(ctx, clients.dynamic, veleroNamespace, forSchedule)
+	}
+	if err != nil {
+		return opts, err
+	}
+
+	if len(includeNamespaces) > 0 {
+		opts.Scope.IncludedNamespaces = includeNamespaces
+	}
+	opts.Scope.ExcludedNamespaces = append(opts.Scope.ExcludedNamespaces, excludeNamespaces...)
+
+	if selector != "" {
+		if _, err := labels.Parse(selector); err != nil {
+			return opts, fmt.Errorf("invalid selector: %w", err)
+		}
+		if opts.Scope.LabelSelector != "" {
+			opts.Scope.LabelSelector += ","
+		}
+		opts.Scope.LabelSelector += selector
+	}
+	return opts, nil
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/operator"
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/server"
+)
+
+var serveFlags = struct {
+	operator        bool
+	metricsAddr     string
+	leaderElect     bool
+	webhook         bool
+	webhookPort     int
+	webhookCertDir  string
+	http            string
+	grpc            string
+	profiling       bool
+	refreshInterval time.Duration
+	watch           bool
+	notifyURL       string
+}{}
+
+// notifyInterval is how often the served order is checked for changes to notify
+const notifyInterval = 10 * time.Second
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run as a long running service",
+	// --timeout bounds every scan of the operator, webhook, http and grpc modes
+	Annotations: map[string]string{longRunningAnnotation: "true"},
+	Long: `Run as a long running service.
+
+With --operator the RestoreOrder custom resources in the cluster are
+reconciled, writing the computed order into the ConfigMap or Velero
+Deployment each one targets.
+
+With --webhook a mutating admission webhook is served over TLS that points
+every Velero Restore created without resource modifiers at a ConfigMap
+holding the resource modifiers of the most recently computed graph, applied
+in the namespace of the Restore, so the restored custom resources reconcile.
+Velero still reads the order itself from its server flag. An example
+MutatingWebhookConfiguration is in config/webhook.
+
+With --http the most recently computed order is served over HTTP on
+  GET /order       the restore-resource-priorities flag
+  GET /order.json  the order as JSON
+  GET /graph.dot   the ownership graph in DOT format
+
+With --grpc the same is served by the RestoreOrderService gRPC service
+defined in pkg/api/v1/restoreorder.proto, whose GetOrder, GetGraph and
+Explain calls return the order, the graph and the reasons for the position
+of a resource as protobuf messages. server reflection is enabled.
+
+The graph the webhook, http and grpc modes serve is recomputed every
+--refresh-interval or, with --watch, kept up to date from informers on the
+scanned resources so only the resources that change are read again.
+
+With --notify-url a JSON payload with the old and new order, their diff and
+the CRD that triggered the change is posted to the URL, such as a Slack
+incoming webhook, whenever the order served by these modes or written by
+the operator changes.
+
+With --profiling the http mode also serves the runtime profiles of the
+process on /debug/pprof/, to diagnose performance issues on large clusters.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if !serveFlags.operator && !serveFlags.webhook && serveFlags.http == "" && serveFlags.grpc == "" {
+			return fmt.Errorf("no mode to serve, enable one of --operator, --webhook, --http or --grpc")
+		}
+		if serveFlags.profiling && serveFlags.http == "" {
+			return fmt.Errorf("--profiling needs --http to serve the profiles on")
+		}
+
+		clients, err := conn.clients()
+		if err != nil {
+			return err
+		}
+
+		// every mode runs until the context is cancelled,
+		// the first one to fail stops the others
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		errs := make(chan error)
+		modes := 0
+		run := func(name string, f func(context.Context) error) {
+			modes++
+			go func() {
+				slog.Info("starting", "mode", name)
+				err := f(ctx)
+				if err != nil {
+					err = fmt.Errorf("%s: %w", name, err)
+				}
+				cancel()
+				errs <- err
+			}()
+		}
+
+		if serveFlags.operator {
+			run("operator", func(ctx context.Context) error {
+				return serveOperator(ctx, clients)
+			})
+		}
+
+		// the webhook, http and grpc modes share the periodically recomputed
+		// or, with --watch, continuously updated order
+		var source restoreorder.GraphSource
+		if serveFlags.webhook || serveFlags.http != "" || serveFlags.grpc != "" {
+			if serveFlags.watch {
+				watcher, err := newWatcher(ctx, clients)
+				if err != nil {
+					return err
+				}
+				go watcher.Run(ctx)
+				source = watcher
+			} else {
+				refresher := &restoreorder.Refresher{
+					Discover: func(ctx context.Context) (*restoreorder.Graph, error) {
+						if timeout > 0 {
+							var cancel context.CancelFunc
+							ctx, cancel = context.WithTimeout(ctx, timeout)
+							defer cancel()
+						}
+						return discover(ctx)
+					},
+					Interval: serveFlags.refreshInterval,
+				}
+				go refresher.Run(ctx)
+				source = refresher
+			}
+		}
+
+		if source != nil && serveFlags.notifyURL != "" {
+			go restoreorder.NotifyChanges(ctx, source, &restoreorder.Notifier{URL: serveFlags.notifyURL}, notifyInterval)
+		}
+
+		if serveFlags.webhook {
+			run("webhook", func(ctx context.Context) error {
+				return serveWebhook(ctx, clients, source)
+			})
+		}
+
+		if serveFlags.http != "" {
+			run("http", func(ctx context.Context) error {
+				return serveHTTP(ctx, source)
+			})
+		}
+
+		if serveFlags.grpc != "" {
+			run("grpc", func(ctx context.Context) error {
+				return serveGRPC(ctx, source)
+			})
+		}
+
+		var firstErr error
+		for range modes {
+			if err := <-errs; err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	},
+}
+
+func init() {
+	serveCmd.Flags().BoolVar(&serveFlags.operator, "operator", false, "reconcile RestoreOrder custom resources")
+	serveCmd.Flags().StringVar(&serveFlags.metricsAddr, "metrics-bind-address", "0", "address the operator metrics are served on, 0 disables them")
+	serveCmd.Flags().BoolVar(&serveFlags.leaderElect, "leader-elect", false, "enable leader election so only one operator replica is active")
+	serveCmd.Flags().BoolVar(&serveFlags.webhook, "webhook", false, "serve a mutating webhook pointing Velero Restores at the resource modifiers of the computed graph")
+	serveCmd.Flags().IntVar(&serveFlags.webhookPort, "webhook-port", 9443, "port the webhook is served on")
+	serveCmd.Flags().StringVar(&serveFlags.webhookCertDir, "webhook-cert-dir", "", "directory containing the webhook tls.crt and tls.key, defaults to the controller-runtime serving certs directory")
+	serveCmd.Flags().StringVar(&serveFlags.http, "http", "", "address to serve the computed order over HTTP on, e.g. :8080")
+	serveCmd.Flags().StringVar(&serveFlags.grpc, "grpc", "", "address to serve the computed order over gRPC on, e.g. :9090")
+	serveCmd.Flags().BoolVar(&serveFlags.profiling, "profiling", false, "also serve the runtime profiles on /debug/pprof/ of the --http address")
+	serveCmd.Flags().DurationVar(&serveFlags.refreshInterval, "refresh-interval", 10*time.Minute, "how often the order served by the webhook, http and grpc modes is recomputed")
+	serveCmd.Flags().BoolVar(&serveFlags.watch, "watch", false, "keep the order served by the webhook, http and grpc modes up to date from informers on the scanned resources instead of recomputing it every --refresh-interval")
+	serveCmd.Flags().StringVar(&serveFlags.notifyURL, "notify-url", "", "URL to post a JSON notification to, e.g. a Slack incoming webhook, whenever the computed order changes")
+	serveCmd.MarkFlagsMutuallyExclusive("watch", "refresh-interval")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// serveOperator runs a manager reconciling RestoreOrders
+func serveOperator(ctx context.Context, clients *clients) error {
+	config, err := conn.toRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	ctrl.SetLogger(logr.FromSlogHandler(slog.Default().Handler()))
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Metrics:          metricsserver.Options{BindAddress: serveFlags.metricsAddr},
+		LeaderElection:   serveFlags.leaderElect,
+		LeaderElectionID: "restoreorders.whoisyourdaddy.io",
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create manager: %w", err)
+	}
+
+	opts, err := scanOptions(ctx, clients)
+	if err != nil {
+		return err
+	}
+
+	reconciler := &operator.Reconciler{
+		Client:   mgr.GetClient(),
+		Dynamic:  clients.dynamic,
+		Metadata: clients.metadata,
+		Options:  opts,
+		Cluster:  provenanceCluster(),
+		Timeout:  timeout,
+	}
+	if serveFlags.notifyURL != "" {
+		reconciler.Notifier = &restoreorder.Notifier{URL: serveFlags.notifyURL}
+	}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("cannot set up operator: %w", err)
+	}
+
+	return mgr.Start(ctx)
+}
+
+// newWatcher returns a watcher of the cluster the connection flags point at
+func newWatcher(ctx context.Context, clients *clients) (*restoreorder.Watcher, error) {
+	if importFile != "" || fromDir != "" || fromStdin || fromChart != "" || conn.allContexts || len(conn.contexts) > 1 {
+		return nil, fmt.Errorf("--watch only watches a single cluster, it cannot be combined with --import, --from-dir, --from-stdin, --from-chart or several contexts")
+	}
+	opts, err := scanOptions(ctx, clients)
+	if err != nil {
+		return nil, err
+	}
+	return &restoreorder.Watcher{Dynamic: clients.dynamic, Metadata: clients.metadata, Options: opts, SyncTimeout: timeout}, nil
+}
+
+// serveWebhook serves the Velero Restore mutating webhook over TLS until ctx is done
+func serveWebhook(ctx context.Context, clients *clients, source restoreorder.GraphSource) error {
+	srv := webhook.NewServer(webhook.Options{
+		Port:    serveFlags.webhookPort,
+		CertDir: serveFlags.webhookCertDir,
+	})
+	srv.Register(server.RestoreWebhookPath, &webhook.Admission{
+		Handler: &server.RestoreMutator{
+			Source:    source,
+			Dynamic:   clients.dynamic,
+			ConfigMap: ResourceModifiersConfigMap,
+			Cluster:   provenanceCluster(),
+		},
+	})
+	return srv.Start(ctx)
+}
+
+// serveHTTP serves the computed order over plain HTTP until ctx is done
+func serveHTTP(ctx context.Context, source restoreorder.GraphSource) error {
+	handler := server.NewHandler(source)
+	if serveFlags.profiling {
+		handler = server.WithProfiling(handler)
+	}
+	srv := &http.Server{
+		Addr:              serveFlags.http,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveGRPC serves the computed order over gRPC until ctx is done
+func serveGRPC(ctx context.Context, source restoreorder.GraphSource) error {
+	lis, err := net.Listen("tcp", serveFlags.grpc)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %w", serveFlags.grpc, err)
+	}
+	srv := server.NewGRPCServer(source)
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+
+	return srv.Serve(lis)
+}
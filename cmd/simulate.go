@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var simulateFlags struct {
+	backup       string
+	resourceList string
+	priorities   string
+}
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Print the order Velero would restore the objects of a backup in",
+	Long: `Print every object of a backup in the order Velero would restore it with
+the computed restore-resource-priorities (or --priorities), marking the
+objects of the resources that would be restored before a resource owning
+them. The resource list holds no owner references, so every object of such
+a resource is marked, whether or not it has an owner in the backup.
+
+The objects are read from the resource list Velero stores with the backup,
+downloaded through a DownloadRequest like "velero backup describe --details"
+does, or from a resource list file given with --resource-list.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+
+		clients, err := conn.clients()
+		if err != nil {
+			return err
+		}
+
+		var list restoreorder.BackupResourceList
+		if simulateFlags.backup != "" {
+			list, err = restoreorder.DownloadBackupResourceList(ctx, clients.dynamic, veleroNamespace, simulateFlags.backup)
+		} else {
+			list, err = readResourceList(simulateFlags.resourceList)
+		}
+		if err != nil {
+			return err
+		}
+
+		mapper, err := conn.restMapper()
+		if err != nil {
+			return err
+		}
+
+		graph, err := discover(ctx)
+		if err != nil {
+			return err
+		}
+		priorities := graph.Priorities()
+		if simulateFlags.priorities != "" {
+			priorities = simulateFlags.priorities
+		}
+
+		out := cmd.OutOrStdout()
+		misordered := 0
+		for i, step := range graph.SimulateRestore(restoreorder.ParsePriorities(priorities), list.Objects(mapper)) {
+			if len(step.BeforeOwners) == 0 {
+				fmt.Fprintf(out, "%d %s %s\n", i+1, step.Resource, step.Object)
+				continue
+			}
+			misordered++
+			fmt.Fprintf(out, "%d %s %s (%s restored before its owner %s)\n", i+1, step.Resource, step.Object, step.Resource, strings.Join(step.BeforeOwners, ", "))
+		}
+
+		if misordered > 0 {
+			return fmt.Errorf("found %d objects of resources restored before their owners", misordered)
+		}
+		return nil
+	},
+}
+
+// readResourceList reads the backup resource list file at path
+func readResourceList(path string) (restoreorder.BackupResourceList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read resource list: %w", err)
+	}
+	defer f.Close()
+	return restoreorder.ReadBackupResourceList(f)
+}
+
+func init() {
+	simulateCmd.Flags().StringVar(&simulateFlags.backup, "backup", "", "name of the Velero Backup to simulate restoring")
+	simulateCmd.Flags().StringVar(&simulateFlags.resourceList, "resource-list", "", "resource list file of a backup (the gzipped or plain JSON Velero stores) to simulate restoring")
+	simulateCmd.Flags().StringVar(&simulateFlags.priorities, "priorities", "", "restore-resource-priorities value to simulate instead of the computed one")
+	simulateCmd.MarkFlagsOneRequired("backup", "resource-list")
+	simulateCmd.MarkFlagsMutuallyExclusive("backup", "resource-list")
+	rootCmd.AddCommand(simulateCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// exportGraph writes graph to path as JSON
+func exportGraph(path string, graph *restoreorder.Graph) error {
+	data, err := json.MarshalIndent(graph, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode graph: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cannot export graph: %w", err)
+	}
+	return nil
+}
+
+// importGraph reads a graph written by exportGraph from path
+func importGraph(path string) (*restoreorder.Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot import graph: %w", err)
+	}
+	graph := restoreorder.NewGraph()
+	if err := json.Unmarshal(data, graph); err != nil {
+		return nil, fmt.Errorf("cannot import graph %s: %w", path, err)
+	}
+	return graph, nil
+}
+
+// manifestClients returns clients serving the manifests of --from-dir or --from-stdin
+func manifestClients() (*clients, error) {
+	var manifests []unstructured.Unstructured
+	var err error
+	if fromDir != "" {
+		manifests, err = restoreorder.ReadManifestDir(fromDir)
+	} else {
+		manifests, err = restoreorder.ReadManifests(os.Stdin)
+		if err != nil {
+			err = fmt.Errorf("cannot read manifests: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, metadataClient, err := restoreorder.ManifestClients(manifests)
+	if err != nil {
+		return nil, err
+	}
+	return &clients{dynamic: dynamicClient, metadata: metadataClient}, nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var (
+	otlpEndpoint string
+	// stopTracing ends the span of the command and flushes every span
+	// once the command returns
+	stopTracing = func() {}
+)
+
+// startTracing sends the spans of the run to --otlp-endpoint, all of them
+// within a span of the command so a run is a single trace
+func startTracing(cmd *cobra.Command) error {
+	if otlpEndpoint == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(cmd.Context(), otlptracehttp.WithEndpointURL(otlpEndpoint))
+	if err != nil {
+		return fmt.Errorf("cannot create trace exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cmd.Root().Name()))),
+	)
+	otel.SetTracerProvider(provider)
+
+	ctx, span := otel.Tracer(restoreorder.TracerName).Start(cmd.Context(), cmd.CommandPath())
+	cmd.SetContext(ctx)
+
+	stopTracing = func() {
+		span.End()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Error("cannot export traces", "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var validatePriorities string
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check an existing restore-resource-priorities value against the ownership graph",
+	Long: `Check an existing restore-resource-priorities value against the ownership
+graph discovered in the cluster, reporting every resource that would be
+restored before one of its owners.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		graph, err := discover(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		violations := graph.Validate(restoreorder.ParsePriorities(validatePriorities))
+		for _, v := range violations {
+			if v.OwnerPosition < 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s (position %d) is restored before its owner %s (not listed)\n", v.Resource, v.ResourcePosition+1, v.Owner)
+				continue
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s (position %d) is restored before its owner %s (position %d)\n", v.Resource, v.ResourcePosition+1, v.Owner, v.OwnerPosition+1)
+		}
+
+		if len(violations) > 0 {
+			return fmt.Errorf("found %d resources restored before their owners", len(violations))
+		}
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&validatePriorities, "priorities", "", "restore-resource-priorities value to validate")
+	validateCmd.MarkFlagRequired("priorities")
+	rootCmd.AddCommand(validateCmd)
+}
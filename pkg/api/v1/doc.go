@@ -0,0 +1,4 @@
+// Package v1 holds the protobuf definitions of the gRPC API served by serve --grpc.
+package v1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative restoreorder.proto
@@ -0,0 +1,198 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.2
+// source: restoreorder.proto
+
+package v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	RestoreOrderService_GetOrder_FullMethodName = "/whoisyourdaddy.restoreorder.v1.RestoreOrderService/GetOrder"
+	RestoreOrderService_GetGraph_FullMethodName = "/whoisyourdaddy.restoreorder.v1.RestoreOrderService/GetGraph"
+	RestoreOrderService_Explain_FullMethodName  = "/whoisyourdaddy.restoreorder.v1.RestoreOrderService/Explain"
+)
+
+// RestoreOrderServiceClient is the client API for RestoreOrderService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RestoreOrderService serves the most recently computed restore order
+// and the ownership graph it was computed from
+type RestoreOrderServiceClient interface {
+	// GetOrder returns the restore order
+	GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*GetOrderResponse, error)
+	// GetGraph returns the ownership graph between kinds
+	GetGraph(ctx context.Context, in *GetGraphRequest, opts ...grpc.CallOption) (*GetGraphResponse, error)
+	// Explain returns why a resource is at its position in the order
+	Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error)
+}
+
+type restoreOrderServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRestoreOrderServiceClient(cc grpc.ClientConnInterface) RestoreOrderServiceClient {
+	return &restoreOrderServiceClient{cc}
+}
+
+func (c *restoreOrderServiceClient) GetOrder(ctx context.Context, in *GetOrderRequest, opts ...grpc.CallOption) (*GetOrderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetOrderResponse)
+	err := c.cc.Invoke(ctx, RestoreOrderService_GetOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *restoreOrderServiceClient) GetGraph(ctx context.Context, in *GetGraphRequest, opts ...grpc.CallOption) (*GetGraphResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetGraphResponse)
+	err := c.cc.Invoke(ctx, RestoreOrderService_GetGraph_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *restoreOrderServiceClient) Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExplainResponse)
+	err := c.cc.Invoke(ctx, RestoreOrderService_Explain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RestoreOrderServiceServer is the server API for RestoreOrderService service.
+// All implementations must embed UnimplementedRestoreOrderServiceServer
+// for forward compatibility
+//
+// RestoreOrderService serves the most recently computed restore order
+// and the ownership graph it was computed from
+type RestoreOrderServiceServer interface {
+	// GetOrder returns the restore order
+	GetOrder(context.Context, *GetOrderRequest) (*GetOrderResponse, error)
+	// GetGraph returns the ownership graph between kinds
+	GetGraph(context.Context, *GetGraphRequest) (*GetGraphResponse, error)
+	// Explain returns why a resource is at its position in the order
+	Explain(context.Context, *ExplainRequest) (*ExplainResponse, error)
+	mustEmbedUnimplementedRestoreOrderServiceServer()
+}
+
+// UnimplementedRestoreOrderServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRestoreOrderServiceServer struct {
+}
+
+func (UnimplementedRestoreOrderServiceServer) GetOrder(context.Context, *GetOrderRequest) (*GetOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrder not implemented")
+}
+func (UnimplementedRestoreOrderServiceServer) GetGraph(context.Context, *GetGraphRequest) (*GetGraphResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGraph not implemented")
+}
+func (UnimplementedRestoreOrderServiceServer) Explain(context.Context, *ExplainRequest) (*ExplainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Explain not implemented")
+}
+func (UnimplementedRestoreOrderServiceServer) mustEmbedUnimplementedRestoreOrderServiceServer() {}
+
+// UnsafeRestoreOrderServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RestoreOrderServiceServer will
+// result in compilation errors.
+type UnsafeRestoreOrderServiceServer interface {
+	mustEmbedUnimplementedRestoreOrderServiceServer()
+}
+
+func RegisterRestoreOrderServiceServer(s grpc.ServiceRegistrar, srv RestoreOrderServiceServer) {
+	s.RegisterService(&RestoreOrderService_ServiceDesc, srv)
+}
+
+func _RestoreOrderService_GetOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RestoreOrderServiceServer).GetOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RestoreOrderService_GetOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RestoreOrderServiceServer).GetOrder(ctx, req.(*GetOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RestoreOrderService_GetGraph_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGraphRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RestoreOrderServiceServer).GetGraph(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RestoreOrderService_GetGraph_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RestoreOrderServiceServer).GetGraph(ctx, req.(*GetGraphRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RestoreOrderService_Explain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RestoreOrderServiceServer).Explain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RestoreOrderService_Explain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RestoreOrderServiceServer).Explain(ctx, req.(*ExplainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RestoreOrderService_ServiceDesc is the grpc.ServiceDesc for RestoreOrderService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RestoreOrderService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "whoisyourdaddy.restoreorder.v1.RestoreOrderService",
+	HandlerType: (*RestoreOrderServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetOrder",
+			Handler:    _RestoreOrderService_GetOrder_Handler,
+		},
+		{
+			MethodName: "GetGraph",
+			Handler:    _RestoreOrderService_GetGraph_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _RestoreOrderService_Explain_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "restoreorder.proto",
+}
@@ -0,0 +1,246 @@
+// Package operator reconciles RestoreOrder custom resources, writing the
+// computed restore order to the target each one describes.
+package operator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// RestoreOrderGVK is the kind reconciled by the operator
+var RestoreOrderGVK = schema.GroupVersionKind{
+	Group:   "whoisyourdaddy.io",
+	Version: "v1alpha1",
+	Kind:    "RestoreOrder",
+}
+
+// ConditionReady is the condition reporting whether the last computation was written to the target
+const ConditionReady = "Ready"
+
+const defaultInterval = time.Hour
+
+type spec struct {
+	Interval string `json:"interval,omitempty"`
+	Target   struct {
+		ConfigMap *struct {
+			Namespace string `json:"namespace,omitempty"`
+			Name      string `json:"name"`
+			Key       string `json:"key,omitempty"`
+		} `json:"configMap,omitempty"`
+		Deployment *struct {
+			Namespace string `json:"namespace,omitempty"`
+			Name      string `json:"name"`
+			Container string `json:"container,omitempty"`
+		} `json:"deployment,omitempty"`
+	} `json:"target"`
+}
+
+type status struct {
+	Priorities         string         `json:"priorities,omitempty"`
+	LastComputedTime   *v1.Time       `json:"lastComputedTime,omitempty"`
+	ObservedGeneration int64          `json:"observedGeneration,omitempty"`
+	Conditions         []v1.Condition `json:"conditions,omitempty"`
+}
+
+// Reconciler computes the restore order for every RestoreOrder and writes it to its target
+type Reconciler struct {
+	// Client reads and updates RestoreOrders
+	Client client.Client
+	// Dynamic and Metadata are used to scan the cluster and write targets
+	Dynamic  dynamic.Interface
+	Metadata metadata.Interface
+	Options  restoreorder.Options
+	// Cluster names the scanned cluster in the provenance recorded on targets
+	Cluster string
+	// Notifier, when set, is notified whenever the order of a RestoreOrder changes
+	Notifier *restoreorder.Notifier
+	// Timeout, when set, aborts every scan of the cluster after this long
+	Timeout time.Duration
+}
+
+// SetupWithManager registers the reconciler with mgr. only changes to the
+// spec of a RestoreOrder trigger a reconcile, as every reconcile scans the
+// whole cluster: writing the status must not queue another one, and the
+// order is recomputed every interval through RequeueAfter
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(RestoreOrderGVK)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(obj, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
+
+// Reconcile computes the order for a RestoreOrder, writes it to the target
+// and records the outcome in the status
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(RestoreOrderGVK)
+	if err := r.Client.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	s := spec{}
+	rawSpec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, &s); err != nil {
+		return ctrl.Result{}, r.setStatus(ctx, obj, "", fmt.Errorf("invalid spec: %w", err))
+	}
+
+	interval := defaultInterval
+	if s.Interval != "" {
+		d, err := time.ParseDuration(s.Interval)
+		if err != nil {
+			return ctrl.Result{}, r.setStatus(ctx, obj, "", fmt.Errorf("invalid interval: %w", err))
+		}
+		interval = d
+	}
+
+	graph, err := r.discover(ctx)
+	if err != nil {
+		return ctrl.Result{}, r.setStatus(ctx, obj, "", err)
+	}
+	priorities := graph.Priorities()
+
+	if err := r.write(ctx, obj.GetNamespace(), s, priorities, restoreorder.NewProvenance(graph, r.Cluster)); err != nil {
+		return ctrl.Result{}, r.setStatus(ctx, obj, priorities, err)
+	}
+
+	if previous, _, _ := unstructured.NestedString(obj.Object, "status", "priorities"); r.Notifier != nil && previous != "" && previous != priorities {
+		trigger := fmt.Sprintf("RestoreOrder %s/%s", obj.GetNamespace(), obj.GetName())
+		if err := r.Notifier.Notify(ctx, restoreorder.NewOrderChange(previous, priorities, trigger)); err != nil {
+			slog.Error("cannot notify order change", "namespace", obj.GetNamespace(), "name", obj.GetName(), "error", err)
+		}
+	}
+
+	slog.Info("reconciled restore order", "namespace", obj.GetNamespace(), "name", obj.GetName())
+	return ctrl.Result{RequeueAfter: interval}, r.setStatus(ctx, obj, priorities, nil)
+}
+
+// write writes priorities, and their provenance, to the target described by s
+func (r *Reconciler) write(ctx context.Context, namespace string, s spec, priorities string, provenance restoreorder.Provenance) error {
+	switch {
+	case s.Target.ConfigMap != nil && s.Target.Deployment != nil:
+		return fmt.Errorf("only one of target.configMap and target.deployment may be set")
+	case s.Target.ConfigMap != nil:
+		cm := s.Target.ConfigMap
+		if cm.Namespace != "" {
+			namespace = cm.Namespace
+		}
+		key := cm.Key
+		if key == "" {
+			key = restoreorder.DefaultConfigMapKey
+		}
+		return restoreorder.ApplyConfigMap(ctx, r.Dynamic, namespace, cm.Name, map[string]string{key: priorities}, provenance)
+	case s.Target.Deployment != nil:
+		d := s.Target.Deployment
+		if d.Namespace != "" {
+			namespace = d.Namespace
+		}
+		container := d.Container
+		if container == "" {
+			container = "velero"
+		}
+
+		deployments := r.Dynamic.Resource(restoreorder.DeploymentResource).Namespace(namespace)
+		deploy, err := deployments.Get(ctx, d.Name, v1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("cannot get deployment %s/%s: %w", namespace, d.Name, err)
+		}
+		current, _, err := restoreorder.GetDeploymentPriorities(deploy, container)
+		if err != nil {
+			return err
+		}
+		if current == priorities {
+			return nil
+		}
+		if err := restoreorder.SetDeploymentPriorities(deploy, container, priorities); err != nil {
+			return err
+		}
+		provenance.Annotate(deploy)
+		if _, err := deployments.Update(ctx, deploy, v1.UpdateOptions{FieldManager: restoreorder.FieldManager}); err != nil {
+			return fmt.Errorf("cannot update deployment %s/%s: %w", namespace, d.Name, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("one of target.configMap and target.deployment must be set")
+	}
+}
+
+// discover scans the cluster within r.Timeout
+func (r *Reconciler) discover(ctx context.Context) (*restoreorder.Graph, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	return restoreorder.Discover(ctx, r.Dynamic, r.Metadata, r.Options)
+}
+
+// setStatus records the outcome of a reconcile in the status of obj and
+// returns the reconcile error, or the error updating the status. the status
+// is only updated when the priorities, the observed generation or the
+// conditions changed, lastComputedTime is when the priorities last changed
+func (r *Reconciler) setStatus(ctx context.Context, obj *unstructured.Unstructured, priorities string, reconcileErr error) error {
+	s := status{}
+	rawStatus, _, _ := unstructured.NestedMap(obj.Object, "status")
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawStatus, &s); err != nil {
+		return fmt.Errorf("cannot read status: %w", err)
+	}
+
+	changed := s.ObservedGeneration != obj.GetGeneration()
+	s.ObservedGeneration = obj.GetGeneration()
+	condition := v1.Condition{
+		Type:               ConditionReady,
+		Status:             v1.ConditionTrue,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             "Computed",
+		Message:            "restore order written to target",
+	}
+	if reconcileErr != nil {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = "Failed"
+		condition.Message = reconcileErr.Error()
+	}
+	if meta.SetStatusCondition(&s.Conditions, condition) {
+		changed = true
+	}
+
+	if priorities != "" && priorities != s.Priorities {
+		now := v1.Now()
+		s.Priorities = priorities
+		s.LastComputedTime = &now
+		changed = true
+	}
+	if !changed {
+		return reconcileErr
+	}
+
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&s)
+	if err != nil {
+		return fmt.Errorf("cannot write status: %w", err)
+	}
+	if err := unstructured.SetNestedMap(obj.Object, raw, "status"); err != nil {
+		return fmt.Errorf("cannot write status: %w", err)
+	}
+	if err := r.Client.Status().Update(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("cannot update status: %w", err)
+	}
+	return reconcileErr
+}
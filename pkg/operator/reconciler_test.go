@@ -0,0 +1,257 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+	restoreordertesting "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder/testing"
+)
+
+// fixtures holds the CRDs and custom resources the reconciler scans
+const fixtures = "../restoreorder/testdata/stable"
+
+// veleroDeployment is the Deployment a RestoreOrder can target
+const veleroDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: velero
+  namespace: velero
+spec:
+  template:
+    spec:
+      containers:
+      - name: velero
+        args: [server, --restore-resource-priorities=namespaces]
+`
+
+// testReconciler returns a reconciler scanning the fixtures, with
+// restoreOrder stored in its client, and the number of status updates it made
+func testReconciler(t *testing.T, restoreOrder *unstructured.Unstructured) (*Reconciler, *int) {
+	t.Helper()
+	manifests, err := restoreorder.ReadManifestDir(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment, err := restoreorder.ReadManifests(strings.NewReader(veleroDeployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynamicClient, metadataClient, err := restoreorder.ManifestClients(append(manifests, deployment...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := 0
+	c := fake.NewClientBuilder().
+		WithObjects(restoreOrder).
+		WithStatusSubresource(restoreOrder).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				updates++
+				return c.SubResource(subResource).Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	return &Reconciler{Client: c, Dynamic: dynamicClient, Metadata: metadataClient}, &updates
+}
+
+// testRestoreOrder returns the RestoreOrder velero/velero with spec
+func testRestoreOrder(spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(RestoreOrderGVK)
+	obj.SetNamespace("velero")
+	obj.SetName("velero")
+	obj.SetGeneration(1)
+	return obj
+}
+
+// reconcile reconciles velero/velero with r and returns its stored status
+func reconcile(t *testing.T, r *Reconciler) (ctrl.Result, status, error) {
+	t.Helper()
+	key := types.NamespacedName{Namespace: "velero", Name: "velero"}
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(RestoreOrderGVK)
+	if getErr := r.Client.Get(context.Background(), key, obj); getErr != nil {
+		t.Fatal(getErr)
+	}
+	s := status{}
+	rawStatus, _, _ := unstructured.NestedMap(obj.Object, "status")
+	if convErr := runtime.DefaultUnstructuredConverter.FromUnstructured(rawStatus, &s); convErr != nil {
+		t.Fatal(convErr)
+	}
+	return result, s, err
+}
+
+func TestReconcile(t *testing.T) {
+	want := restoreordertesting.Discover(t, fixtures, restoreorder.Options{}).Priorities()
+
+	tests := []struct {
+		name           string
+		spec           map[string]interface{}
+		wantRequeue    time.Duration
+		wantErr        string
+		wantComputed   bool
+		wantConfigMap  bool
+		wantDeployment bool
+	}{
+		{
+			name:          "configmap",
+			spec:          map[string]interface{}{"target": map[string]interface{}{"configMap": map[string]interface{}{"name": "restore-order"}}},
+			wantRequeue:   defaultInterval,
+			wantComputed:  true,
+			wantConfigMap: true,
+		},
+		{
+			name:           "deployment",
+			spec:           map[string]interface{}{"interval": "10m", "target": map[string]interface{}{"deployment": map[string]interface{}{"name": "velero"}}},
+			wantRequeue:    10 * time.Minute,
+			wantComputed:   true,
+			wantDeployment: true,
+		},
+		{
+			name:    "invalid interval",
+			spec:    map[string]interface{}{"interval": "often", "target": map[string]interface{}{"configMap": map[string]interface{}{"name": "restore-order"}}},
+			wantErr: "invalid interval",
+		},
+		{
+			// the order is computed but cannot be written anywhere
+			name:         "no target",
+			spec:         map[string]interface{}{},
+			wantErr:      "one of target.configMap and target.deployment must be set",
+			wantComputed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := testReconciler(t, testRestoreOrder(tt.spec))
+			applied := map[string]interface{}{}
+			r.Dynamic.(*dynamicfake.FakeDynamicClient).PrependReactor("patch", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				cm := &unstructured.Unstructured{}
+				if err := json.Unmarshal(action.(clienttesting.PatchAction).GetPatch(), &cm.Object); err != nil {
+					return true, nil, err
+				}
+				applied, _, _ = unstructured.NestedMap(cm.Object, "data")
+				return true, cm, nil
+			})
+
+			result, s, err := reconcile(t, r)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if result.RequeueAfter != tt.wantRequeue {
+				t.Errorf("got requeue after %s, want %s", result.RequeueAfter, tt.wantRequeue)
+			}
+			if got := s.Priorities != ""; got != tt.wantComputed {
+				t.Errorf("got status priorities %q, want computed %t", s.Priorities, tt.wantComputed)
+			}
+			if tt.wantComputed && s.Priorities != want {
+				t.Errorf("got status priorities %s, want %s", s.Priorities, want)
+			}
+			if s.ObservedGeneration != 1 {
+				t.Errorf("got observed generation %d, want 1", s.ObservedGeneration)
+			}
+
+			ready := meta.FindStatusCondition(s.Conditions, ConditionReady)
+			wantStatus := v1.ConditionTrue
+			if tt.wantErr != "" {
+				wantStatus = v1.ConditionFalse
+			}
+			if ready == nil || ready.Status != wantStatus {
+				t.Fatalf("got ready condition %+v, want status %s", ready, wantStatus)
+			}
+			if tt.wantErr != "" && !strings.Contains(ready.Message, tt.wantErr) {
+				t.Errorf("got ready message %q, want %s", ready.Message, tt.wantErr)
+			}
+
+			if tt.wantConfigMap {
+				if got := applied[restoreorder.DefaultConfigMapKey]; got != want {
+					t.Errorf("got configmap value %v, want %s", got, want)
+				}
+			}
+			if tt.wantDeployment {
+				deploy, err := r.Dynamic.Resource(restoreorder.DeploymentResource).Namespace("velero").Get(context.Background(), "velero", v1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _, err := restoreorder.GetDeploymentPriorities(deploy, "velero")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("got deployment priorities %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileScanError(t *testing.T) {
+	r, _ := testReconciler(t, testRestoreOrder(map[string]interface{}{
+		"target": map[string]interface{}{"deployment": map[string]interface{}{"name": "velero"}},
+	}))
+	r.Dynamic.(*dynamicfake.FakeDynamicClient).PrependReactor("list", "customresourcedefinitions", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+
+	_, s, err := reconcile(t, r)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("got error %v, want the scan error", err)
+	}
+	ready := meta.FindStatusCondition(s.Conditions, ConditionReady)
+	if ready == nil || ready.Status != v1.ConditionFalse || ready.Reason != "Failed" || !strings.Contains(ready.Message, "connection refused") {
+		t.Errorf("got ready condition %+v, want failed with the scan error", ready)
+	}
+	if s.Priorities != "" || s.LastComputedTime != nil {
+		t.Errorf("got status %+v, want no priorities computed", s)
+	}
+}
+
+func TestReconcileStatusUnchanged(t *testing.T) {
+	r, updates := testReconciler(t, testRestoreOrder(map[string]interface{}{
+		"target": map[string]interface{}{"deployment": map[string]interface{}{"name": "velero"}},
+	}))
+
+	_, first, err := reconcile(t, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updates != 1 {
+		t.Fatalf("got %d status updates, want 1", *updates)
+	}
+
+	// the same order, generation and condition leave the status alone
+	_, second, err := reconcile(t, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updates != 1 {
+		t.Errorf("got %d status updates, want the first one only", *updates)
+	}
+	if !second.LastComputedTime.Equal(first.LastComputedTime) {
+		t.Errorf("got last computed time %s, want %s", second.LastComputedTime, first.LastComputedTime)
+	}
+}
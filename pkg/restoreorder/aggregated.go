@@ -0,0 +1,85 @@
+package restoreorder
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// APIServiceResource serves the APIServices registering API groups with the aggregation layer
+var APIServiceResource = schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"}
+
+// aggregatedCRDs returns a CRD for every listable resource served by an
+// aggregated API server, an APIService backed by a service rather than the
+// API server itself, so its resources are scanned like custom resources.
+// the API groups whose resources cannot be discovered are returned as errors
+func aggregatedCRDs(ctx context.Context, client dynamic.Interface, disco discovery.ServerResourcesInterface) ([]unstructured.Unstructured, ListErrors, error) {
+	apiServices, err := client.Resource(APIServiceResource).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot list APIServices: %w", err)
+	}
+
+	crds := []unstructured.Unstructured{}
+	skipped := ListErrors{}
+	for _, apiService := range apiServices.Items {
+		if service, _, _ := unstructured.NestedMap(apiService.Object, "spec", "service"); service == nil {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(apiService.Object, "spec", "group")
+		version, _, _ := unstructured.NestedString(apiService.Object, "spec", "version")
+		groupVersion := schema.GroupVersion{Group: group, Version: version}.String()
+
+		resources, err := disco.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			skipped = append(skipped, ListError{Resource: apiService.GetName(), Err: err})
+			continue
+		}
+		for _, res := range resources.APIResources {
+			// subresources are served along with their resource
+			if strings.Contains(res.Name, "/") || !slices.Contains(res.Verbs, "list") {
+				continue
+			}
+			crds = append(crds, aggregatedCRD(group, version, res))
+		}
+	}
+	return crds, skipped, nil
+}
+
+// aggregatedCRD returns the CRD that would serve res
+func aggregatedCRD(group, version string, res v1.APIResource) unstructured.Unstructured {
+	scope := "Cluster"
+	if res.Namespaced {
+		scope = "Namespaced"
+	}
+	categories := []interface{}{}
+	for _, c := range res.Categories {
+		categories = append(categories, c)
+	}
+	crd := unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"group": group,
+			"names": map[string]interface{}{
+				"kind":       res.Kind,
+				"plural":     res.Name,
+				"categories": categories,
+			},
+			"scope": scope,
+			"versions": []interface{}{map[string]interface{}{
+				"name":    version,
+				"served":  true,
+				"storage": true,
+			}},
+		},
+	}}
+	crd.SetAPIVersion(CRDResource.GroupVersion().String())
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(res.Name + "." + group)
+	return crd
+}
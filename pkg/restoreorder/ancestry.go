@@ -0,0 +1,96 @@
+package restoreorder
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata"
+)
+
+// Ancestor is an object and the owners found by following its owner references
+type Ancestor struct {
+	// Resource is the resource name of the object, e.g. nodegroups.eks.example.com
+	Resource  string
+	Namespace string
+	Name      string
+	UID       types.UID
+	// Controller is set when the object is the controller of the object it owns
+	Controller bool
+	// Missing is why the object could not be found, empty when it was
+	Missing string
+	Owners  []Ancestor
+}
+
+// Ancestry follows the owner references of the object of res named name
+// upwards, by UID, returning the object with every owner above it
+func Ancestry(ctx context.Context, client metadata.Interface, mapper meta.RESTMapper, res schema.GroupVersionResource, namespace, name string) (Ancestor, error) {
+	obj, err := client.Resource(res).Namespace(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return Ancestor{}, fmt.Errorf("cannot get %s %s: %w", res.GroupResource(), name, err)
+	}
+	return ancestors(ctx, client, mapper, res, obj, map[types.UID]bool{})
+}
+
+// ancestors returns obj with the owners of obj, seen holds the objects on the
+// path to obj so owner reference cycles end
+func ancestors(ctx context.Context, client metadata.Interface, mapper meta.RESTMapper, res schema.GroupVersionResource, obj *v1.PartialObjectMetadata, seen map[types.UID]bool) (Ancestor, error) {
+	a := Ancestor{
+		Resource:  res.GroupResource().String(),
+		Namespace: obj.Namespace,
+		Name:      obj.Name,
+		UID:       obj.UID,
+		Owners:    []Ancestor{},
+	}
+	if seen[obj.UID] {
+		a.Missing = "owner reference cycle"
+		return a, nil
+	}
+	seen[obj.UID] = true
+	defer delete(seen, obj.UID)
+
+	for _, ref := range obj.OwnerReferences {
+		gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+		owner := Ancestor{Name: ref.Name, UID: ref.UID, Controller: ref.Controller != nil && *ref.Controller, Resource: gvk.GroupKind().String()}
+
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			owner.Missing = "owner kind not served by the cluster"
+			a.Owners = append(a.Owners, owner)
+			continue
+		}
+		owner.Resource = mapping.Resource.GroupResource().String()
+		// owners of cluster scoped objects are cluster scoped, namespaced
+		// objects can be owned by objects in the same namespace or the cluster
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			owner.Namespace = obj.Namespace
+		}
+
+		found, err := client.Resource(mapping.Resource).Namespace(owner.Namespace).Get(ctx, ref.Name, v1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			owner.Missing = "owner not found"
+		case err != nil:
+			return a, fmt.Errorf("cannot get %s %s: %w", owner.Resource, ref.Name, err)
+		case found.UID != ref.UID:
+			owner.Missing = fmt.Sprintf("owner was replaced, found UID %s", found.UID)
+		}
+		if owner.Missing != "" {
+			a.Owners = append(a.Owners, owner)
+			continue
+		}
+
+		controller := owner.Controller
+		owner, err = ancestors(ctx, client, mapper, mapping.Resource, found, seen)
+		if err != nil {
+			return a, err
+		}
+		owner.Controller = controller
+		a.Owners = append(a.Owners, owner)
+	}
+	return a, nil
+}
@@ -0,0 +1,50 @@
+package restoreorder
+
+import (
+	"log/slog"
+	"strconv"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SyncWaveAnnotation is the annotation ArgoCD orders the resources it syncs by
+const SyncWaveAnnotation = "argocd.argoproj.io/sync-wave"
+
+// recordSyncWaves sets the sync wave of every kind in graph to the earliest
+// wave of its resources, resources without the annotation are in wave 0
+// as they are for ArgoCD
+func recordSyncWaves(graph *Graph, all []v1.PartialObjectMetadata) {
+	for _, res := range all {
+		kind := res.GroupVersionKind().GroupKind()
+		if _, ok := graph.Resources[kind]; !ok {
+			continue
+		}
+
+		wave := 0
+		if value, ok := res.GetAnnotations()[SyncWaveAnnotation]; ok {
+			var err error
+			wave, err = strconv.Atoi(value)
+			if err != nil {
+				slog.Warn("ignoring invalid sync wave", "kind", kind.String(), "namespace", res.Namespace, "name", res.Name, "wave", value)
+				continue
+			}
+		}
+
+		if current, ok := graph.SyncWaves[kind]; !ok || wave < current {
+			graph.SyncWaves[kind] = wave
+		}
+	}
+}
+
+// addSyncWaveEdges prefers every kind of graph after the kinds in earlier
+// sync waves with soft edges, so the waves order kinds at different depths
+// too wherever ownership does not contradict them
+func addSyncWaveEdges(graph *Graph) {
+	for kind, wave := range graph.SyncWaves {
+		for earlier, w := range graph.SyncWaves {
+			if w < wave {
+				graph.AddSoftEdge(kind, earlier, 0)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+)
+
+// BuiltinChildResources are the built-in resources operators commonly create
+// for their custom resources, scanned when Options.IncludeBuiltinChildren is set
+// all of them are namespaced
+var BuiltinChildResources = []GVK{
+	{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, Kind: "Deployment"},
+	{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, Kind: "StatefulSet"},
+	{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, Kind: "DaemonSet"},
+	{GVR: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, Kind: "Job"},
+	{GVR: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}, Kind: "CronJob"},
+	{GVR: schema.GroupVersionResource{Version: "v1", Resource: "services"}, Kind: "Service"},
+	{GVR: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, Kind: "Secret"},
+	{GVR: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, Kind: "ConfigMap"},
+	{GVR: schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}, Kind: "ServiceAccount"},
+	{GVR: schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}, Kind: "PersistentVolumeClaim"},
+}
+
+// builtinKinds returns the kinds of BuiltinChildResources
+func builtinKinds() []schema.GroupKind {
+	kinds := []schema.GroupKind{}
+	for _, res := range BuiltinChildResources {
+		kinds = append(kinds, res.GroupKind())
+	}
+	return kinds
+}
+
+// findBuiltinChildren lists the built-in resources in BuiltinChildResources and
+// returns those owned by a kind in crGroups
+func findBuiltinChildren(ctx context.Context, client metadata.Interface, crGroups []string, opts Options) ([]v1.PartialObjectMetadata, ListErrors) {
+	children := []v1.PartialObjectMetadata{}
+	errs := ListErrors{}
+	for _, res := range BuiltinChildResources {
+		if !opts.Scope.includesResource(res, true) {
+			continue
+		}
+
+		resources, err := findResources(ctx, client, res, true, opts)
+		if errors.Is(err, errNotServed) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, ListError{Resource: res.GVR.GroupResource().String(), Err: err})
+			continue
+		}
+
+		// only resources owned by custom resources need to be ordered
+		resources = slices.DeleteFunc(resources, func(r v1.PartialObjectMetadata) bool {
+			return !ownedByCustomResource(r, crGroups)
+		})
+		children = append(children, resources...)
+	}
+	return children, errs
+}
+
+// ownedByCustomResource reports whether res has an owner in one of crGroups
+func ownedByCustomResource(res v1.PartialObjectMetadata, crGroups []string) bool {
+	return slices.ContainsFunc(res.GetOwnerReferences(), func(ref v1.OwnerReference) bool {
+		return slices.Contains(crGroups, schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).Group)
+	})
+}
+
+// addBuiltinKind records kind in graph if it is one of BuiltinChildResources
+// so the edges detectors find to it are ordered too
+func addBuiltinKind(graph *Graph, kind schema.GroupKind) {
+	if _, ok := graph.Resources[kind]; ok {
+		return
+	}
+	for _, res := range BuiltinChildResources {
+		if res.GroupKind() == kind {
+			addBuiltin(graph, res)
+		}
+	}
+}
+
+// addBuiltin records the built-in res in graph
+func addBuiltin(graph *Graph, res GVK) {
+	// velero names core resources without a group e.g. services
+	graph.Resources[res.GroupKind()] = res.GVR.GroupResource().String()
+	graph.Namespaced[res.GroupKind()] = true
+}
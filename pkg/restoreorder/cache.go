@@ -0,0 +1,184 @@
+package restoreorder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Cache keeps the resources and ownership edges found for every CRD of a
+// cluster between scans, so repeated scans skip listing the resources of
+// the CRDs that are unchanged since, i.e. still have the same resourceVersion.
+// resources created or deleted without the CRD changing are only seen once
+// its entry expires, TTL bounds how stale the graph can be
+type Cache struct {
+	// Path is the file the cache is read from and saved to
+	Path string
+	// TTL is how long the entry of a CRD is used for after it was stored
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is what a scan found for the resources of a single CRD
+type cacheEntry struct {
+	ResourceVersion string `json:"resourceVersion"`
+	// Options identifies the scan options the entry was stored with,
+	// which change the resources listed and edges detected
+	Options string    `json:"options"`
+	Stored  time.Time `json:"stored"`
+	// Resources only keep the metadata the graph is built from
+	Resources []v1.PartialObjectMetadata `json:"resources"`
+	// Edges were detected from the resources
+	Edges []Edge `json:"edges"`
+}
+
+// OpenCache reads the cache stored at path, a missing or unreadable file
+// is an empty cache
+func OpenCache(path string, ttl time.Duration) *Cache {
+	c := &Cache{Path: path, TTL: ttl, entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &c.entries)
+	}
+	if err != nil {
+		slog.Warn("ignoring unreadable cache", "path", path, "error", err)
+		c.entries = map[string]cacheEntry{}
+	}
+	return c
+}
+
+// Save writes the entries that have not expired to Path
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, entry := range c.entries {
+		if c.expired(entry) {
+			delete(c.entries, name)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("cannot encode cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o700); err != nil {
+		return fmt.Errorf("cannot save cache: %w", err)
+	}
+	// write a temporary file first so concurrent runs never read half a cache
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot save cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot save cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot save cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.Path); err != nil {
+		return fmt.Errorf("cannot save cache: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) expired(entry cacheEntry) bool {
+	return time.Since(entry.Stored) > c.TTL
+}
+
+// lookup returns the entry of crd when it is unchanged and has not expired
+func (c *Cache) lookup(crd unstructured.Unstructured, options string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[crd.GetName()]
+	if !ok || entry.ResourceVersion != crd.GetResourceVersion() || entry.Options != options || c.expired(entry) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// store records the resources of crd and the edges detected from them
+func (c *Cache) store(crd unstructured.Unstructured, options string, resources []v1.PartialObjectMetadata, edges []Edge) {
+	// the CRDs of aggregated APIs have no resource version telling when their resources changed
+	if crd.GetResourceVersion() == "" {
+		return
+	}
+	kept := make([]v1.PartialObjectMetadata, 0, len(resources))
+	for _, res := range resources {
+		kept = append(kept, trimMetadata(res))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[crd.GetName()] = cacheEntry{
+		ResourceVersion: crd.GetResourceVersion(),
+		Options:         options,
+		Stored:          time.Now(),
+		Resources:       kept,
+		Edges:           edges,
+	}
+}
+
+// trimMetadata returns the metadata of res the graph is built from
+func trimMetadata(res v1.PartialObjectMetadata) v1.PartialObjectMetadata {
+	annotations := map[string]string{}
+	for _, key := range []string{DependsOnAnnotation, SyncWaveAnnotation} {
+		if value, ok := res.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+	return v1.PartialObjectMetadata{
+		TypeMeta: res.TypeMeta,
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:       res.Namespace,
+			Name:            res.Name,
+			UID:             res.UID,
+			OwnerReferences: res.OwnerReferences,
+			Annotations:     annotations,
+		},
+	}
+}
+
+// cacheOptions identifies the options that change what is cached for a CRD,
+// known are the kinds a SpecRefDetector looks for
+func cacheOptions(opts Options, detectors []DependencyDetector, known []schema.GroupKind) string {
+	types := []string{}
+	for _, d := range detectors {
+		types = append(types, fmt.Sprintf("%T", d))
+		if d, ok := d.(OwnerReferenceDetector); ok && d.IncludeNonController {
+			types = append(types, "non-controller")
+		}
+		if d, ok := d.(*ExecDetector); ok {
+			types = append(types, d.Path+"="+strings.Join(d.Groups, ";"))
+		}
+	}
+	kinds := []string{}
+	if opts.InferSpecRefs {
+		for _, kind := range known {
+			kinds = append(kinds, kind.String())
+		}
+		slices.Sort(kinds)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v %+v %s %s", opts.listOptions(), opts.Scope, strings.Join(types, ","), strings.Join(kinds, ","))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package restoreorder
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ClusterAPI is the name of the Cluster API detector
+const ClusterAPI = "cluster-api"
+
+// ClusterAPIGroup is the group of Clusters, MachineDeployments, MachineSets and Machines
+const ClusterAPIGroup = "cluster.x-k8s.io"
+
+func init() {
+	RegisterDetector(ClusterAPI, ClusterAPIDetector{})
+}
+
+// the Cluster API kinds ordered by the detector
+var (
+	capiCluster           = schema.GroupKind{Group: ClusterAPIGroup, Kind: "Cluster"}
+	capiMachineDeployment = schema.GroupKind{Group: ClusterAPIGroup, Kind: "MachineDeployment"}
+	capiMachineSet        = schema.GroupKind{Group: ClusterAPIGroup, Kind: "MachineSet"}
+	capiResourceSet       = schema.GroupKind{Group: "addons." + ClusterAPIGroup, Kind: "ClusterResourceSet"}
+	capiResourceSetBind   = schema.GroupKind{Group: "addons." + ClusterAPIGroup, Kind: "ClusterResourceSetBinding"}
+)
+
+// the spec fields holding typed references to the infrastructure,
+// control plane and bootstrap objects a Cluster API object is built from
+var capiRefs = [][]string{
+	{"spec", "infrastructureRef"},
+	{"spec", "controlPlaneRef"},
+	{"spec", "bootstrap", "configRef"},
+	{"spec", "template", "spec", "infrastructureRef"},
+	{"spec", "template", "spec", "bootstrap", "configRef"},
+	{"spec", "machineTemplate", "infrastructureRef"},
+}
+
+// ClusterAPIDetector orders Cluster API objects
+// Cluster -> MachineDeployment -> MachineSet -> Machine, with the objects
+// each of them references through spec.*Ref fields after it, as those point
+// from the Cluster API object to its provider objects rather than the way
+// owner references would need to for a restore
+type ClusterAPIDetector struct{}
+
+// WantsObjects reports whether crd serves a Cluster API core or control plane kind,
+// the others are ordered from their metadata
+func (ClusterAPIDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return group == ClusterAPIGroup || group == "controlplane."+ClusterAPIGroup
+}
+
+// Detect returns the edges between obj and the Cluster API objects it is built on
+func (ClusterAPIDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	edges := []Edge{}
+
+	// the objects referenced by obj are created for it
+	for _, fields := range capiRefs {
+		if ref, ok := typedRef(obj.Object, fields...); ok {
+			edges = append(edges, Edge{Kind: ref, Owner: kind})
+		}
+	}
+
+	// everything belonging to a cluster comes after the Cluster
+	labels := obj.GetLabels()
+	clusterName, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterName")
+	if (clusterName != "" || labels[ClusterAPIGroup+"/cluster-name"] != "") && kind != capiCluster {
+		edges = append(edges, Edge{Kind: kind, Owner: capiCluster})
+	}
+
+	switch kind {
+	case capiMachineSet:
+		if labels[ClusterAPIGroup+"/deployment-name"] != "" {
+			edges = append(edges, Edge{Kind: kind, Owner: capiMachineDeployment})
+		}
+	case schema.GroupKind{Group: ClusterAPIGroup, Kind: "Machine"}:
+		if labels[ClusterAPIGroup+"/set-name"] != "" {
+			edges = append(edges, Edge{Kind: kind, Owner: capiMachineSet})
+		}
+	case capiResourceSetBind:
+		edges = append(edges, Edge{Kind: kind, Owner: capiResourceSet}, Edge{Kind: kind, Owner: capiCluster})
+	}
+	return edges
+}
@@ -0,0 +1,174 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClusterAPIDetector(t *testing.T) {
+	machine := schema.GroupKind{Group: ClusterAPIGroup, Kind: "Machine"}
+	awsCluster := schema.GroupKind{Group: "infrastructure.cluster.x-k8s.io", Kind: "AWSCluster"}
+	awsMachine := schema.GroupKind{Group: "infrastructure.cluster.x-k8s.io", Kind: "AWSMachine"}
+	awsMachineTemplate := schema.GroupKind{Group: "infrastructure.cluster.x-k8s.io", Kind: "AWSMachineTemplate"}
+	controlPlane := schema.GroupKind{Group: "controlplane.cluster.x-k8s.io", Kind: "KubeadmControlPlane"}
+	configTemplate := schema.GroupKind{Group: "bootstrap.cluster.x-k8s.io", Kind: "KubeadmConfigTemplate"}
+	config := schema.GroupKind{Group: "bootstrap.cluster.x-k8s.io", Kind: "KubeadmConfig"}
+
+	testDetector(t, ClusterAPIDetector{}, []detectorTest{
+		{
+			name: "cluster",
+			manifest: `
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: Cluster
+metadata:
+  name: prod
+  namespace: fleet
+spec:
+  infrastructureRef:
+    apiVersion: infrastructure.cluster.x-k8s.io/v1beta2
+    kind: AWSCluster
+    name: prod
+  controlPlaneRef:
+    apiVersion: controlplane.cluster.x-k8s.io/v1beta1
+    kind: KubeadmControlPlane
+    name: prod-control-plane
+`,
+			want: []Edge{
+				{Kind: awsCluster, Owner: capiCluster},
+				{Kind: controlPlane, Owner: capiCluster},
+			},
+		},
+		{
+			name: "machine deployment",
+			manifest: `
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: MachineDeployment
+metadata:
+  name: prod-md-0
+  namespace: fleet
+  labels:
+    cluster.x-k8s.io/cluster-name: prod
+spec:
+  clusterName: prod
+  replicas: 3
+  template:
+    spec:
+      clusterName: prod
+      bootstrap:
+        configRef:
+          apiVersion: bootstrap.cluster.x-k8s.io/v1beta1
+          kind: KubeadmConfigTemplate
+          name: prod-md-0
+      infrastructureRef:
+        apiVersion: infrastructure.cluster.x-k8s.io/v1beta2
+        kind: AWSMachineTemplate
+        name: prod-md-0
+`,
+			want: []Edge{
+				{Kind: awsMachineTemplate, Owner: capiMachineDeployment},
+				{Kind: configTemplate, Owner: capiMachineDeployment},
+				{Kind: capiMachineDeployment, Owner: capiCluster},
+			},
+		},
+		{
+			name: "machine set",
+			manifest: `
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: MachineSet
+metadata:
+  name: prod-md-0-5f8c9
+  namespace: fleet
+  labels:
+    cluster.x-k8s.io/cluster-name: prod
+    cluster.x-k8s.io/deployment-name: prod-md-0
+spec:
+  clusterName: prod
+`,
+			want: []Edge{
+				{Kind: capiMachineSet, Owner: capiCluster},
+				{Kind: capiMachineSet, Owner: capiMachineDeployment},
+			},
+		},
+		{
+			name: "machine",
+			manifest: `
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: Machine
+metadata:
+  name: prod-md-0-5f8c9-x2v7q
+  namespace: fleet
+  labels:
+    cluster.x-k8s.io/cluster-name: prod
+    cluster.x-k8s.io/set-name: prod-md-0-5f8c9
+spec:
+  clusterName: prod
+  bootstrap:
+    configRef:
+      apiVersion: bootstrap.cluster.x-k8s.io/v1beta1
+      kind: KubeadmConfig
+      name: prod-md-0-5f8c9-x2v7q
+  infrastructureRef:
+    apiVersion: infrastructure.cluster.x-k8s.io/v1beta2
+    kind: AWSMachine
+    name: prod-md-0-5f8c9-x2v7q
+`,
+			want: []Edge{
+				{Kind: awsMachine, Owner: machine},
+				{Kind: config, Owner: machine},
+				{Kind: machine, Owner: capiCluster},
+				{Kind: machine, Owner: capiMachineSet},
+			},
+		},
+		{
+			name: "control plane",
+			manifest: `
+apiVersion: controlplane.cluster.x-k8s.io/v1beta1
+kind: KubeadmControlPlane
+metadata:
+  name: prod-control-plane
+  namespace: fleet
+  labels:
+    cluster.x-k8s.io/cluster-name: prod
+spec:
+  machineTemplate:
+    infrastructureRef:
+      apiVersion: infrastructure.cluster.x-k8s.io/v1beta2
+      kind: AWSMachineTemplate
+      name: prod-control-plane
+`,
+			want: []Edge{
+				{Kind: awsMachineTemplate, Owner: controlPlane},
+				{Kind: controlPlane, Owner: capiCluster},
+			},
+		},
+		{
+			// the Cluster is found twice, the graph keeps an edge once
+			name: "cluster resource set binding",
+			manifest: `
+apiVersion: addons.cluster.x-k8s.io/v1beta1
+kind: ClusterResourceSetBinding
+metadata:
+  name: prod
+  namespace: fleet
+spec:
+  clusterName: prod
+`,
+			want: []Edge{
+				{Kind: capiResourceSetBind, Owner: capiCluster},
+				{Kind: capiResourceSetBind, Owner: capiResourceSet},
+				{Kind: capiResourceSetBind, Owner: capiCluster},
+			},
+		},
+		{
+			name: "unrelated",
+			manifest: `
+apiVersion: x.io/v1
+kind: App
+metadata:
+  name: a
+`,
+			want: []Edge{},
+		},
+	})
+}
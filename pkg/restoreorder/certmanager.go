@@ -0,0 +1,51 @@
+package restoreorder
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CertManager is the name of the cert-manager detector
+const CertManager = "cert-manager"
+
+// CertManagerGroup is the group of Certificates, Issuers and ClusterIssuers
+const CertManagerGroup = "cert-manager.io"
+
+func init() {
+	RegisterDetector(CertManager, CertManagerDetector{})
+}
+
+// CertManagerDetector orders Certificates after the Issuer or ClusterIssuer
+// named in spec.issuerRef. the Secrets named in spec.secretName keep their
+// default position: an edge orders a whole kind, so it would restore every
+// Secret, issuer keys included, after the Certificates, and cert-manager
+// would reissue the Certificates before their backed up keypairs are restored
+type CertManagerDetector struct{}
+
+// WantsObjects reports whether crd serves a cert-manager kind
+func (CertManagerDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return group == CertManagerGroup
+}
+
+// Detect returns the edge from a Certificate to its issuer
+func (CertManagerDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	if kind != (schema.GroupKind{Group: CertManagerGroup, Kind: "Certificate"}) {
+		return nil
+	}
+
+	edges := []Edge{}
+	if _, ok, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "name"); ok {
+		issuer := schema.GroupKind{Group: CertManagerGroup, Kind: "Issuer"}
+		// the kind and group default to a cert-manager Issuer
+		if k, _, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "kind"); k != "" {
+			issuer.Kind = k
+		}
+		if g, _, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "group"); g != "" {
+			issuer.Group = g
+		}
+		edges = append(edges, Edge{Kind: kind, Owner: issuer})
+	}
+	return edges
+}
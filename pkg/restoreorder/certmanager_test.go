@@ -0,0 +1,124 @@
+package restoreorder
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCertManagerDetector(t *testing.T) {
+	certificate := schema.GroupKind{Group: CertManagerGroup, Kind: "Certificate"}
+	issuer := schema.GroupKind{Group: CertManagerGroup, Kind: "Issuer"}
+	clusterIssuer := schema.GroupKind{Group: CertManagerGroup, Kind: "ClusterIssuer"}
+	awsIssuer := schema.GroupKind{Group: "awspca.cert-manager.io", Kind: "AWSPCAClusterIssuer"}
+
+	testDetector(t, CertManagerDetector{}, []detectorTest{
+		{
+			name: "issuer",
+			manifest: `
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: shop-tls
+  namespace: shop
+spec:
+  dnsNames:
+  - shop.example.com
+  issuerRef:
+    name: letsencrypt
+  secretName: shop-tls
+`,
+			want: []Edge{{Kind: certificate, Owner: issuer}},
+		},
+		{
+			name: "cluster issuer",
+			manifest: `
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: shop-tls
+  namespace: shop
+spec:
+  issuerRef:
+    name: letsencrypt
+    kind: ClusterIssuer
+    group: cert-manager.io
+  secretName: shop-tls
+`,
+			want: []Edge{{Kind: certificate, Owner: clusterIssuer}},
+		},
+		{
+			name: "external issuer",
+			manifest: `
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: shop-tls
+  namespace: shop
+spec:
+  issuerRef:
+    name: private-ca
+    kind: AWSPCAClusterIssuer
+    group: awspca.cert-manager.io
+`,
+			want: []Edge{{Kind: certificate, Owner: awsIssuer}},
+		},
+		{
+			name: "issuer kind",
+			manifest: `
+apiVersion: cert-manager.io/v1
+kind: Issuer
+metadata:
+  name: letsencrypt
+  namespace: shop
+spec:
+  acme:
+    server: https://acme-v02.api.letsencrypt.org/directory
+`,
+		},
+	})
+}
+
+func TestCertManagerDetectorKeepsSecretsDefault(t *testing.T) {
+	manifests := []unstructured.Unstructured{
+		testCRD(CertManagerGroup, "Certificate"),
+		testCRD(CertManagerGroup, "Issuer"),
+		testManifest(t, `
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: shop-tls
+  namespace: shop
+spec:
+  issuerRef:
+    name: letsencrypt
+  secretName: shop-tls
+`),
+		testManifest(t, `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: shop-tls
+  namespace: shop
+`),
+	}
+	graph := discoverManifests(t, manifests, Options{
+		Detectors:              []DependencyDetector{OwnerReferenceDetector{}, CertManagerDetector{}},
+		IncludeBuiltinChildren: true,
+	})
+
+	entries := ParsePriorities(graph.Priorities())
+	secrets := slices.Index(entries, "secrets")
+	certificates := slices.IndexFunc(entries, func(entry string) bool { return strings.HasPrefix(entry, "certificates") })
+	issuers := slices.IndexFunc(entries, func(entry string) bool { return strings.HasPrefix(entry, "issuers") })
+	if secrets < 0 || certificates < 0 || issuers < 0 {
+		t.Fatalf("got priorities %v, want secrets, issuers and certificates", entries)
+	}
+	// the kept defaults come before the computed entries
+	if secrets > issuers || issuers > certificates {
+		t.Errorf("got priorities %v, want secrets at its default position before issuers before certificates", entries)
+	}
+}
@@ -0,0 +1,68 @@
+package restoreorder
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+)
+
+// Child is a kind owned by another kind, directly or through other kinds
+type Child struct {
+	// Resource is the CRD name of the owned kind
+	Resource string
+	// Depth is 1 for the kinds owned directly, 2 for the kinds those own and so on
+	Depth int
+}
+
+// Children returns every kind owned by the named resource, the nearest first
+func (g *Graph) Children(resource string) ([]Child, error) {
+	kind, ok := g.Kind(resource)
+	if !ok {
+		return nil, fmt.Errorf("resource %s is not served by any CRD", resource)
+	}
+
+	owned := map[schema.GroupKind][]schema.GroupKind{}
+	for _, k := range sortedKinds(g.Owners) {
+		for owner := range g.Owners[k] {
+			owned[owner] = append(owned[owner], k)
+		}
+	}
+
+	children := []Child{}
+	depths := map[schema.GroupKind]int{kind: 0}
+	queue := []schema.GroupKind{kind}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range owned[current] {
+			if _, ok := depths[child]; ok {
+				continue
+			}
+			depths[child] = depths[current] + 1
+			children = append(children, Child{Resource: g.Name(child), Depth: depths[child]})
+			queue = append(queue, child)
+		}
+	}
+	slices.SortFunc(children, func(a, b Child) int {
+		return cmp.Or(cmp.Compare(a.Depth, b.Depth), cmp.Compare(a.Resource, b.Resource))
+	})
+	return children, nil
+}
+
+// OwnedObjects lists the objects of res that have an owner of the kind owner
+func OwnedObjects(ctx context.Context, client metadata.Interface, res schema.GroupVersionResource, owner schema.GroupKind) ([]v1.PartialObjectMetadata, error) {
+	objects, err := listPages(ctx, client.Resource(res).Namespace("").List, v1.ListOptions{Limit: 500}, defaultListBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list %s: %w", res.GroupResource(), err)
+	}
+	return slices.DeleteFunc(objects, func(obj v1.PartialObjectMetadata) bool {
+		return !slices.ContainsFunc(obj.OwnerReferences, func(ref v1.OwnerReference) bool {
+			return schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind() == owner
+		})
+	}), nil
+}
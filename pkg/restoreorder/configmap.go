@@ -0,0 +1,52 @@
+package restoreorder
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// FieldManager is the field manager used when applying objects
+const FieldManager = "whoisyourdaddyandwhatdoeshedo"
+
+// DefaultConfigMapKey is the ConfigMap key the priorities value is written to
+const DefaultConfigMapKey = "restoreResourcePriorities"
+
+// ConfigMapResource is the resource ConfigMaps are served by
+var ConfigMapResource = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "configmaps",
+}
+
+// ApplyConfigMap creates or server-side applies a ConfigMap holding data
+// and the provenance of the priorities as annotations
+func ApplyConfigMap(ctx context.Context, client dynamic.Interface, namespace, name string, data map[string]string, provenance Provenance) error {
+	values := map[string]interface{}{}
+	for k, v := range data {
+		values[k] = v
+	}
+
+	cm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"data": values,
+	}}
+	provenance.Annotate(cm)
+
+	_, err := client.Resource(ConfigMapResource).Namespace(namespace).Apply(ctx, name, cm, v1.ApplyOptions{
+		FieldManager: FieldManager,
+		Force:        true,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot apply configmap %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package restoreorder
+
+import (
+	"fmt"
+	"slices"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// CRDResource is the resource that serves CustomResourceDefinitions
+var CRDResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// CRDResourceV1beta1 serves CustomResourceDefinitions on clusters older
+// than Kubernetes 1.16, which do not serve CRDResource
+var CRDResourceV1beta1 = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1beta1",
+	Resource: "customresourcedefinitions",
+}
+
+type GVK struct {
+	GVR  schema.GroupVersionResource
+	Kind string
+}
+
+// GroupKind returns the group and kind of the resource
+func (g GVK) GroupKind() schema.GroupKind {
+	return schema.GroupKind{Group: g.GVR.Group, Kind: g.Kind}
+}
+
+// GetRes gets the GVK of the custom resource a CRD defines and whether it is namespaced.
+// both apiextensions.k8s.io/v1 CRDs and the v1beta1 CRDs of older clusters,
+// which can name their only version in spec.version, are understood
+func GetRes(in unstructured.Unstructured) (GVK, bool, error) {
+	if in.DeepCopy() == nil {
+		return GVK{}, false, fmt.Errorf("cannot get resource from nil object")
+	}
+
+	if in.GetKind() != "CustomResourceDefinition" {
+		return GVK{}, false, fmt.Errorf("cannot get resource from non-CRD object %s", in.GetKind())
+	}
+
+	spec, err := decodeCRDSpec(in)
+	if err != nil {
+		return GVK{}, false, fmt.Errorf("cannot get resource from CRD %s: %w", in.GetName(), err)
+	}
+	for field, value := range map[string]string{
+		"spec.group":        spec.Group,
+		"spec.names.kind":   spec.Names.Kind,
+		"spec.names.plural": spec.Names.Plural,
+		"spec.scope":        string(spec.Scope),
+	} {
+		if value == "" {
+			return GVK{}, false, fmt.Errorf("cannot get resource from CRD %s: missing %s", in.GetName(), field)
+		}
+	}
+
+	version, err := pickVersion(spec.Versions)
+	if err != nil {
+		return GVK{}, false, fmt.Errorf("cannot get resource from CRD %s: %w", in.GetName(), err)
+	}
+
+	return GVK{
+		GVR: schema.GroupVersionResource{
+			Group:    spec.Group,
+			Version:  version,
+			Resource: spec.Names.Plural,
+		},
+		Kind: spec.Names.Kind,
+	}, spec.Scope == apiextensionsv1.NamespaceScoped, nil
+}
+
+// decodeCRDSpec decodes the spec of a CRD of either version into the v1 types.
+// the versions of a v1beta1 CRD that only sets spec.version are that version
+func decodeCRDSpec(in unstructured.Unstructured) (apiextensionsv1.CustomResourceDefinitionSpec, error) {
+	if in.GetAPIVersion() != CRDResourceV1beta1.GroupVersion().String() {
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(in.Object, &crd); err != nil {
+			return apiextensionsv1.CustomResourceDefinitionSpec{}, fmt.Errorf("cannot decode CRD: %w", err)
+		}
+		return crd.Spec, nil
+	}
+
+	var crd apiextensionsv1beta1.CustomResourceDefinition
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(in.Object, &crd); err != nil {
+		return apiextensionsv1.CustomResourceDefinitionSpec{}, fmt.Errorf("cannot decode CRD: %w", err)
+	}
+	spec := apiextensionsv1.CustomResourceDefinitionSpec{
+		Group: crd.Spec.Group,
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Kind:   crd.Spec.Names.Kind,
+			Plural: crd.Spec.Names.Plural,
+		},
+		Scope: apiextensionsv1.ResourceScope(crd.Spec.Scope),
+	}
+	for _, v := range crd.Spec.Versions {
+		spec.Versions = append(spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{
+			Name:    v.Name,
+			Served:  v.Served,
+			Storage: v.Storage,
+		})
+	}
+	if len(spec.Versions) == 0 && crd.Spec.Version != "" {
+		spec.Versions = []apiextensionsv1.CustomResourceDefinitionVersion{{
+			Name:    crd.Spec.Version,
+			Served:  true,
+			Storage: true,
+		}}
+	}
+	return spec, nil
+}
+
+// pickVersion returns the version to list a CRD's resources with.
+// the storage version is preferred, otherwise the highest served version
+// according to the Kubernetes version priority rules (v2 > v1 > v1beta1 > v1alpha1)
+func pickVersion(versions []apiextensionsv1.CustomResourceDefinitionVersion) (string, error) {
+	served := []string{}
+	for _, v := range versions {
+		if v.Name == "" || !v.Served {
+			continue
+		}
+		if v.Storage {
+			return v.Name, nil
+		}
+		served = append(served, v.Name)
+	}
+
+	if len(served) == 0 {
+		return "", fmt.Errorf("no served versions")
+	}
+
+	return slices.MaxFunc(served, version.CompareKubeAwareVersionStrings), nil
+}
+
+// notEstablished reports why the resources of crd cannot be listed: it is
+// being deleted or its Established condition is not true. CRDs without any
+// conditions, as in manifests, are taken to be established
+func notEstablished(crd unstructured.Unstructured) (string, bool) {
+	if crd.GetDeletionTimestamp() != nil {
+		return "terminating", true
+	}
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	if len(conditions) == 0 {
+		return "", false
+	}
+	for _, condition := range conditions {
+		condition, ok := condition.(map[string]interface{})
+		if !ok || condition["type"] != string(apiextensionsv1.Established) {
+			continue
+		}
+		if condition["status"] == string(apiextensionsv1.ConditionTrue) {
+			return "", false
+		}
+		if reason, _ := condition["reason"].(string); reason != "" {
+			return reason, true
+		}
+		break
+	}
+	return "not established", true
+}
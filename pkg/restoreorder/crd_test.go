@@ -0,0 +1,100 @@
+package restoreorder
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// FuzzDecodeCRDSpec decodes arbitrary CRDs, which must never panic, and
+// checks the resource of every CRD GetRes accepts is one the CRD serves
+func FuzzDecodeCRDSpec(f *testing.F) {
+	crd := testCRD("x.io", "App")
+	seed, err := json.Marshal(crd.Object)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	f.Add([]byte(`{"apiVersion":"apiextensions.k8s.io/v1beta1","kind":"CustomResourceDefinition","metadata":{"name":"apps.x.io"},` +
+		`"spec":{"group":"x.io","version":"v1alpha1","scope":"Cluster","names":{"kind":"App","plural":"apps"}}}`))
+	f.Add([]byte(`{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","spec":{"versions":[{"name":"v1","served":"yes"}]}}`))
+	f.Add([]byte(`{"kind":"CustomResourceDefinition","spec":null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return
+		}
+		in := unstructured.Unstructured{Object: obj}
+		spec, err := decodeCRDSpec(in)
+		if err != nil {
+			return
+		}
+
+		res, namespaced, err := GetRes(in)
+		if err != nil {
+			return
+		}
+		if res.GVR.Group != spec.Group || res.Kind != spec.Names.Kind || res.GVR.Resource != spec.Names.Plural {
+			t.Errorf("got resource %v, want %s.%s of kind %s", res, spec.Names.Plural, spec.Group, spec.Names.Kind)
+		}
+		if namespaced != (spec.Scope == apiextensionsv1.NamespaceScoped) {
+			t.Errorf("got namespaced %t for scope %s", namespaced, spec.Scope)
+		}
+		if !slices.ContainsFunc(spec.Versions, func(v apiextensionsv1.CustomResourceDefinitionVersion) bool {
+			return v.Name == res.GVR.Version && v.Served
+		}) {
+			t.Errorf("got version %s, which is not served", res.GVR.Version)
+		}
+	})
+}
+
+// FuzzPickVersion picks the version of three arbitrary ones, the low six
+// bits of flags marking which are served and which are the storage version
+func FuzzPickVersion(f *testing.F) {
+	f.Add("v1", "v1beta1", "v2alpha1", uint8(0b000111))
+	f.Add("v1", "v2", "", uint8(0b010011))
+	f.Add("v1alpha1", "v1beta2", "v1beta10", uint8(0b000000))
+	f.Add("", "v1", "foo", uint8(0b111101))
+
+	f.Fuzz(func(t *testing.T, a, b, c string, flags uint8) {
+		versions := []apiextensionsv1.CustomResourceDefinitionVersion{}
+		for i, name := range []string{a, b, c} {
+			versions = append(versions, apiextensionsv1.CustomResourceDefinitionVersion{
+				Name:    name,
+				Served:  flags&(1<<i) != 0,
+				Storage: flags&(1<<(i+3)) != 0,
+			})
+		}
+		served := slices.DeleteFunc(slices.Clone(versions), func(v apiextensionsv1.CustomResourceDefinitionVersion) bool {
+			return v.Name == "" || !v.Served
+		})
+
+		got, err := pickVersion(versions)
+		if len(served) == 0 {
+			if err == nil {
+				t.Errorf("picked %q of no served versions", got)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("cannot pick version of %v: %s", served, err)
+		}
+
+		if i := slices.IndexFunc(served, func(v apiextensionsv1.CustomResourceDefinitionVersion) bool { return v.Storage }); i >= 0 {
+			if got != served[i].Name {
+				t.Errorf("picked %q, want the storage version %q", got, served[i].Name)
+			}
+			return
+		}
+		for _, v := range served {
+			if version.CompareKubeAwareVersionStrings(got, v.Name) < 0 {
+				t.Errorf("picked %q, but %q is newer", got, v.Name)
+			}
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package restoreorder
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Crossplane is the name of the Crossplane detector
+const Crossplane = "crossplane"
+
+// CrossplaneGroup is the group of compositions and composite resource definitions
+const CrossplaneGroup = "apiextensions.crossplane.io"
+
+func init() {
+	RegisterDetector(Crossplane, CrossplaneDetector{})
+}
+
+// CrossplaneDetector orders composite resources (XRs) after their claims and
+// compositions and before the resources composed from them, as crossplane
+// records these links in spec references rather than always in owner references
+type CrossplaneDetector struct{}
+
+// WantsObjects reports whether crd serves XRs or claims, whose CRDs crossplane
+// creates for a CompositeResourceDefinition and makes it the owner of
+func (CrossplaneDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	for _, ref := range crd.GetOwnerReferences() {
+		owner := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+		if owner.Group == CrossplaneGroup && owner.Kind == "CompositeResourceDefinition" {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect returns the edges given by the composition, claim and
+// composed resource references of an XR or claim
+func (CrossplaneDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	edges := []Edge{}
+
+	// crossplane v1 keeps its references in spec, v2 in spec.crossplane
+	for _, spec := range [][]string{{"spec"}, {"spec", "crossplane"}} {
+		if _, ok, _ := unstructured.NestedMap(obj.Object, append(spec, "compositionRef")...); ok {
+			edges = append(edges, Edge{Kind: kind, Owner: schema.GroupKind{Group: CrossplaneGroup, Kind: "Composition"}})
+		}
+		if _, ok, _ := unstructured.NestedMap(obj.Object, append(spec, "compositionRevisionRef")...); ok {
+			edges = append(edges, Edge{Kind: kind, Owner: schema.GroupKind{Group: CrossplaneGroup, Kind: "CompositionRevision"}})
+		}
+
+		// an XR is created for its claim
+		if claim, ok := typedRef(obj.Object, append(spec, "claimRef")...); ok {
+			edges = append(edges, Edge{Kind: kind, Owner: claim})
+		}
+		// a claim points at its XR, which is restored after it
+		if xr, ok := typedRef(obj.Object, append(spec, "resourceRef")...); ok {
+			edges = append(edges, Edge{Kind: xr, Owner: kind})
+		}
+
+		// the composed (managed) resources are restored after the XR
+		refs, _, _ := unstructured.NestedSlice(obj.Object, append(spec, "resourceRefs")...)
+		for _, ref := range refs {
+			if ref, ok := ref.(map[string]interface{}); ok {
+				if composed, ok := typedRef(ref); ok {
+					edges = append(edges, Edge{Kind: composed, Owner: kind})
+				}
+			}
+		}
+	}
+	return edges
+}
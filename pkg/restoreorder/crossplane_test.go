@@ -0,0 +1,136 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCrossplaneDetector(t *testing.T) {
+	composition := schema.GroupKind{Group: CrossplaneGroup, Kind: "Composition"}
+	revision := schema.GroupKind{Group: CrossplaneGroup, Kind: "CompositionRevision"}
+	xr := schema.GroupKind{Group: "example.org", Kind: "XPostgreSQLInstance"}
+	claim := schema.GroupKind{Group: "example.org", Kind: "PostgreSQLInstance"}
+	instance := schema.GroupKind{Group: "rds.aws.upbound.io", Kind: "Instance"}
+	subnetGroup := schema.GroupKind{Group: "rds.aws.upbound.io", Kind: "SubnetGroup"}
+
+	testDetector(t, CrossplaneDetector{}, []detectorTest{
+		{
+			name: "composite resource",
+			manifest: `
+apiVersion: example.org/v1alpha1
+kind: XPostgreSQLInstance
+metadata:
+  name: orders-x7k2p
+spec:
+  parameters:
+    storageGB: 20
+  compositionRef:
+    name: aws-postgres
+  compositionRevisionRef:
+    name: aws-postgres-8d2f1
+  claimRef:
+    apiVersion: example.org/v1alpha1
+    kind: PostgreSQLInstance
+    name: orders
+    namespace: shop
+  resourceRefs:
+  - apiVersion: rds.aws.upbound.io/v1beta1
+    kind: Instance
+    name: orders-x7k2p-db
+  - apiVersion: rds.aws.upbound.io/v1beta1
+    kind: SubnetGroup
+    name: orders-x7k2p-subnets
+`,
+			want: []Edge{
+				{Kind: xr, Owner: composition},
+				{Kind: xr, Owner: revision},
+				{Kind: xr, Owner: claim},
+				{Kind: instance, Owner: xr},
+				{Kind: subnetGroup, Owner: xr},
+			},
+		},
+		{
+			name: "claim",
+			manifest: `
+apiVersion: example.org/v1alpha1
+kind: PostgreSQLInstance
+metadata:
+  name: orders
+  namespace: shop
+spec:
+  compositionRef:
+    name: aws-postgres
+  resourceRef:
+    apiVersion: example.org/v1alpha1
+    kind: XPostgreSQLInstance
+    name: orders-x7k2p
+`,
+			want: []Edge{
+				{Kind: claim, Owner: composition},
+				{Kind: xr, Owner: claim},
+			},
+		},
+		{
+			name: "crossplane v2 composite resource",
+			manifest: `
+apiVersion: example.org/v1alpha1
+kind: XPostgreSQLInstance
+metadata:
+  name: orders
+  namespace: shop
+spec:
+  crossplane:
+    compositionRef:
+      name: aws-postgres
+    resourceRefs:
+    - apiVersion: rds.aws.upbound.io/v1beta1
+      kind: Instance
+      name: orders-db
+`,
+			want: []Edge{
+				{Kind: xr, Owner: composition},
+				{Kind: instance, Owner: xr},
+			},
+		},
+		{
+			// a reference without an apiVersion names no group
+			name: "untyped claim reference",
+			manifest: `
+apiVersion: example.org/v1alpha1
+kind: XPostgreSQLInstance
+metadata:
+  name: orders-x7k2p
+spec:
+  claimRef:
+    kind: PostgreSQLInstance
+    name: orders
+`,
+			want: []Edge{},
+		},
+		{
+			name: "no references",
+			manifest: `
+apiVersion: example.org/v1alpha1
+kind: XPostgreSQLInstance
+metadata:
+  name: orders-x7k2p
+spec:
+  parameters:
+    storageGB: 20
+`,
+			want: []Edge{},
+		},
+	})
+}
+
+func TestCrossplaneDetectorWantsObjects(t *testing.T) {
+	crd := testCRD("example.org", "XPostgreSQLInstance")
+	if (CrossplaneDetector{}).WantsObjects(crd) {
+		t.Error("wants the objects of a CRD crossplane does not own")
+	}
+	crd.SetOwnerReferences(append(crd.GetOwnerReferences(), testOwner(schema.GroupKind{Group: CrossplaneGroup, Kind: "CompositeResourceDefinition"}, "xpostgresqlinstances.example.org", true)))
+	if !(CrossplaneDetector{}).WantsObjects(crd) {
+		t.Error("does not want the objects of a CRD defined by a CompositeResourceDefinition")
+	}
+}
@@ -0,0 +1,99 @@
+package restoreorder
+
+import (
+	"cmp"
+	"slices"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CrossScopeOwner is an owner reference crossing a scope boundary: a
+// cluster-scoped resource owned by a namespaced one, or a resource owned by
+// one in another namespace. the garbage collector ignores such references,
+// they usually point at an operator bug that also breaks restores
+type CrossScopeOwner struct {
+	Kind      schema.GroupKind
+	Namespace string
+	Name      string
+	UID       types.UID
+	Owner     v1.OwnerReference
+	// OwnerNamespace is the namespace of the owner, empty when it was not found
+	OwnerNamespace string
+}
+
+// findCrossScopeOwners returns the owner references of all crossing a scope
+// boundary, namespaced holds the scope of the scanned kinds. owners that
+// were not scanned can only be told to be namespaced from their kind
+func findCrossScopeOwners(all []v1.PartialObjectMetadata, namespaced map[schema.GroupKind]bool) []CrossScopeOwner {
+	owners := map[types.UID]v1.PartialObjectMetadata{}
+	for _, res := range all {
+		owners[res.UID] = res
+	}
+
+	found := []CrossScopeOwner{}
+	for _, res := range all {
+		for _, ref := range res.GetOwnerReferences() {
+			crossing := CrossScopeOwner{
+				Kind:      res.GroupVersionKind().GroupKind(),
+				Namespace: res.Namespace,
+				Name:      res.Name,
+				UID:       res.UID,
+				Owner:     ref,
+			}
+			if owner, ok := owners[ref.UID]; ok && ref.UID != "" {
+				if owner.Namespace == "" || owner.Namespace == res.Namespace {
+					continue
+				}
+				crossing.OwnerNamespace = owner.Namespace
+			} else if res.Namespace != "" || !namespaced[schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()] {
+				continue
+			}
+			found = append(found, crossing)
+		}
+	}
+
+	slices.SortFunc(found, func(a, b CrossScopeOwner) int {
+		return cmp.Or(
+			cmp.Compare(a.Kind.String(), b.Kind.String()),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Owner.Name, b.Owner.Name),
+		)
+	})
+	return found
+}
+
+// crossScopeRefs returns the UIDs of the owners crossing a scope boundary by
+// the UID of the resources referencing them
+func crossScopeRefs(crossScope []CrossScopeOwner) map[types.UID][]types.UID {
+	refs := map[types.UID][]types.UID{}
+	for _, c := range crossScope {
+		refs[c.UID] = append(refs[c.UID], c.Owner.UID)
+	}
+	return refs
+}
+
+// withoutCrossScope drops the edges detected from res to the kinds it only
+// references through owner references crossing a scope boundary
+func withoutCrossScope(edges []Edge, res v1.PartialObjectMetadata, refs map[types.UID][]types.UID) []Edge {
+	crossing := refs[res.UID]
+	if len(crossing) == 0 {
+		return edges
+	}
+
+	invalid := map[schema.GroupKind]bool{}
+	valid := map[schema.GroupKind]bool{}
+	for _, ref := range res.GetOwnerReferences() {
+		kind := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()
+		if slices.Contains(crossing, ref.UID) {
+			invalid[kind] = true
+		} else {
+			valid[kind] = true
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(edges), func(e Edge) bool {
+		return invalid[e.Owner] && !valid[e.Owner]
+	})
+}
@@ -0,0 +1,52 @@
+package restoreorder
+
+import (
+	"log/slog"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DependsOnAnnotation declares the kinds a CRD's resources, or a single
+// resource, have to be restored after as a comma separated list of group/kind
+// e.g. whoisyourdaddy.io/depends-on: eks.example.com/Nodegroup,iam.example.com/IAMRole
+const DependsOnAnnotation = "whoisyourdaddy.io/depends-on"
+
+// dependsOn returns the edges declared by the depends-on annotation of a resource of kind
+func dependsOn(kind schema.GroupKind, annotations map[string]string) []Edge {
+	value, ok := annotations[DependsOnAnnotation]
+	if !ok {
+		return nil
+	}
+
+	edges := []Edge{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, k, ok := strings.Cut(entry, "/")
+		if !ok || k == "" {
+			slog.Warn("ignoring invalid depends-on entry, expected group/kind", "kind", kind.String(), "entry", entry)
+			continue
+		}
+		edges = append(edges, Edge{Kind: kind, Owner: schema.GroupKind{Group: group, Kind: k}})
+	}
+	return edges
+}
+
+// addDeclaredEdges adds edges to graph, warning about kinds that are not scanned
+// as those will not be part of the order
+func addDeclaredEdges(graph *Graph, edges []Edge) {
+	seen := map[Edge]bool{}
+	for _, edge := range edges {
+		if seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		if _, ok := graph.Resources[edge.Owner]; !ok {
+			slog.Warn("depends-on names a kind that is not scanned", "kind", edge.Kind.String(), "dependsOn", edge.Owner.String())
+		}
+		graph.AddEdge(edge.Kind, edge.Owner)
+	}
+}
@@ -0,0 +1,254 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/maps"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// Edge records that Kind has to be restored after Owner
+type Edge struct {
+	Kind  schema.GroupKind
+	Owner schema.GroupKind
+	// Soft only prefers Kind after Owner, the edge is dropped rather than
+	// create a cycle with the hard edges or heavier soft edges
+	Soft bool
+	// Weight ranks soft edges, heavier ones win conflicts between them
+	Weight int
+}
+
+// DependencyDetector finds the kinds an object has to be restored after
+type DependencyDetector interface {
+	Detect(obj unstructured.Unstructured) []Edge
+}
+
+// ObjectDetector is a DependencyDetector that needs the full objects of
+// some kinds, rather than only their metadata, e.g. to read spec references
+type ObjectDetector interface {
+	DependencyDetector
+	// WantsObjects reports whether the resources of crd are needed in full
+	WantsObjects(crd unstructured.Unstructured) bool
+}
+
+// FailingDetector is a DependencyDetector that can fail during a scan, e.g.
+// as it runs an executable, leaving the edges it found incomplete
+type FailingDetector interface {
+	DependencyDetector
+	// Err returns the error the detector failed with, nil if it did not
+	Err() error
+	// String names the detector in the errors of a scan
+	String() string
+}
+
+// OwnerReferenceDetector orders every object after the kind of its controller
+type OwnerReferenceDetector struct {
+	// IncludeNonController also orders objects after the kinds of the owners
+	// that do not control them, such references are often only informational
+	IncludeNonController bool
+}
+
+// Detect returns an edge to the kind of the controller reference of obj, or
+// of every owner reference with IncludeNonController
+func (d OwnerReferenceDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	edges := []Edge{}
+	for _, ref := range obj.GetOwnerReferences() {
+		if !d.IncludeNonController && (ref.Controller == nil || !*ref.Controller) {
+			continue
+		}
+		owner := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()
+		edges = append(edges, Edge{Kind: kind, Owner: owner})
+	}
+	return edges
+}
+
+// OwnerReferences is the name of the owner reference detector
+const OwnerReferences = "owner-references"
+
+// DefaultDetectors are the detectors used when Options.Detectors is empty
+var DefaultDetectors = []DependencyDetector{OwnerReferenceDetector{}}
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   = map[string]DependencyDetector{
+		OwnerReferences: OwnerReferenceDetector{},
+	}
+)
+
+// RegisterDetector makes a detector available under name to LookupDetectors
+func RegisterDetector(name string, d DependencyDetector) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors[name] = d
+}
+
+// DetectorNames returns the names of every registered detector
+func DetectorNames() []string {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	names := maps.Keys(detectors)
+	slices.Sort(names)
+	return names
+}
+
+// LookupDetectors returns the registered detectors with the given names
+func LookupDetectors(names ...string) ([]DependencyDetector, error) {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	found := []DependencyDetector{}
+	for _, name := range names {
+		d, ok := detectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		found = append(found, d)
+	}
+	return found, nil
+}
+
+// typedRef returns the kind of the {apiVersion, kind, name} reference at fields,
+// references that only carry an apiGroup (e.g. Cluster API v1beta2) are understood too
+func typedRef(obj map[string]interface{}, fields ...string) (schema.GroupKind, bool) {
+	kind, _, _ := unstructured.NestedString(obj, append(fields, "kind")...)
+	if kind == "" {
+		return schema.GroupKind{}, false
+	}
+	if apiVersion, _, _ := unstructured.NestedString(obj, append(fields, "apiVersion")...); apiVersion != "" {
+		return schema.FromAPIVersionAndKind(apiVersion, kind).GroupKind(), true
+	}
+	if group, ok, _ := unstructured.NestedString(obj, append(fields, "apiGroup")...); ok {
+		return schema.GroupKind{Group: group, Kind: kind}, true
+	}
+	return schema.GroupKind{}, false
+}
+
+// detect runs every detector over res, using its full object when in objects
+func detect(detectors []DependencyDetector, res v1.PartialObjectMetadata, objects map[types.UID]unstructured.Unstructured) ([]Edge, error) {
+	obj, ok := objects[res.UID]
+	if !ok || res.UID == "" {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&res)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %s %s: %w", res.Kind, res.Name, err)
+		}
+		obj = unstructured.Unstructured{Object: content}
+	}
+
+	edges := []Edge{}
+	for _, d := range detectors {
+		edges = append(edges, d.Detect(obj)...)
+	}
+	return edges, nil
+}
+
+// errDetectorFailed is the error of the detectors that failed during a scan
+var errDetectorFailed = errors.New("detector failed")
+
+// detectorErrors returns the errors of the detectors that failed, as the
+// ListErrors of the resources they stand for
+func detectorErrors(detectors []DependencyDetector) ListErrors {
+	errs := ListErrors{}
+	for _, d := range detectors {
+		if fd, ok := d.(FailingDetector); ok && fd.Err() != nil {
+			errs = append(errs, ListError{Resource: fmt.Sprint(d), Err: fmt.Errorf("%w: %w", errDetectorFailed, fd.Err())})
+		}
+	}
+	return errs
+}
+
+// closeDetectors closes the detectors holding resources, such as the
+// processes of ExecDetectors, once a scan is done with them
+func closeDetectors(detectors []DependencyDetector) {
+	for _, d := range detectors {
+		if c, ok := d.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				slog.Warn("cannot close detector", "error", err)
+			}
+		}
+	}
+}
+
+// wantsObjects reports whether an ObjectDetector among detectors reads the
+// full objects of crd
+func wantsObjects(detectors []DependencyDetector, crd unstructured.Unstructured) bool {
+	return slices.ContainsFunc(detectors, func(d DependencyDetector) bool {
+		od, ok := d.(ObjectDetector)
+		return ok && od.WantsObjects(crd)
+	})
+}
+
+// findObjects lists the full objects of every CRD in crds an ObjectDetector wants,
+// returning the CRDs whose objects cannot be listed as errors
+func findObjects(ctx context.Context, client dynamic.Interface, crds *unstructured.UnstructuredList, detectors []DependencyDetector, opts Options) (map[types.UID]unstructured.Unstructured, ListErrors) {
+	objects := map[types.UID]unstructured.Unstructured{}
+	errs := ListErrors{}
+	for _, crd := range crds.Items {
+		if !wantsObjects(detectors, crd) {
+			continue
+		}
+
+		res, namespaced, err := GetRes(crd)
+		if err != nil {
+			errs = append(errs, ListError{Resource: crd.GetName(), Err: err})
+			continue
+		}
+		var ri dynamic.ResourceInterface = client.Resource(res.GVR)
+		if namespaced {
+			ri = client.Resource(res.GVR).Namespace("")
+		}
+
+		listCtx, span := tracer().Start(ctx, "ListObjects", trace.WithAttributes(
+			attribute.String("group", res.GVR.Group),
+			attribute.String("resource", res.GVR.GroupResource().String()),
+		))
+		err = listObjects(listCtx, ri, objects, opts)
+		endSpan(span, err)
+		if err != nil {
+			errs = append(errs, ListError{Resource: crd.GetName(), Err: err})
+		}
+	}
+	return objects, errs
+}
+
+// listObjects adds every object listed from ri to objects
+func listObjects(ctx context.Context, ri dynamic.ResourceInterface, objects map[types.UID]unstructured.Unstructured, opts Options) error {
+	ctx, cancel := opts.listContext(ctx)
+	defer cancel()
+
+	listOpts := opts.listOptions()
+	for {
+		var page *unstructured.UnstructuredList
+		err := withRetry(ctx, opts.listBackoff(), func() (err error) {
+			page, err = ri.List(ctx, listOpts)
+			return err
+		})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			objects[item.GetUID()] = item
+		}
+		if page.GetContinue() == "" {
+			return nil
+		}
+		listOpts.Continue = page.GetContinue()
+		listOpts.ResourceVersion = ""
+	}
+}
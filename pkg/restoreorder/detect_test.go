@@ -0,0 +1,130 @@
+package restoreorder
+
+import (
+	"slices"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestOwnerReferenceDetector(t *testing.T) {
+	app := schema.GroupKind{Group: "x.io", Kind: "App"}
+	team := schema.GroupKind{Group: "x.io", Kind: "Team"}
+	policy := schema.GroupKind{Group: "y.io", Kind: "Policy"}
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+
+	tests := []struct {
+		name                 string
+		owners               []v1.OwnerReference
+		includeNonController bool
+		want                 []Edge
+	}{
+		{
+			name: "no owners",
+			want: []Edge{},
+		},
+		{
+			name:   "controller",
+			owners: []v1.OwnerReference{testOwner(app, "a", true)},
+			want:   []Edge{{Kind: database, Owner: app}},
+		},
+		{
+			name:   "controller and other owners",
+			owners: []v1.OwnerReference{testOwner(team, "t", false), testOwner(app, "a", true), testOwner(policy, "p", false)},
+			want:   []Edge{{Kind: database, Owner: app}},
+		},
+		{
+			name:                 "controller and other owners with non-controllers",
+			owners:               []v1.OwnerReference{testOwner(team, "t", false), testOwner(app, "a", true), testOwner(policy, "p", false)},
+			includeNonController: true,
+			want:                 []Edge{{Kind: database, Owner: team}, {Kind: database, Owner: app}, {Kind: database, Owner: policy}},
+		},
+		{
+			name:   "only other owners",
+			owners: []v1.OwnerReference{testOwner(team, "t", false), testOwner(policy, "p", false)},
+			want:   []Edge{},
+		},
+		{
+			name:                 "only other owners with non-controllers",
+			owners:               []v1.OwnerReference{testOwner(team, "t", false), testOwner(policy, "p", false)},
+			includeNonController: true,
+			want:                 []Edge{{Kind: database, Owner: team}, {Kind: database, Owner: policy}},
+		},
+		{
+			name:   "controller reference without controller field",
+			owners: []v1.OwnerReference{{APIVersion: "x.io/v1", Kind: "App", Name: "a", UID: "a"}},
+			want:   []Edge{},
+		},
+		{
+			name:                 "several owners of the same kind with non-controllers",
+			owners:               []v1.OwnerReference{testOwner(team, "t1", false), testOwner(team, "t2", false)},
+			includeNonController: true,
+			want:                 []Edge{{Kind: database, Owner: team}, {Kind: database, Owner: team}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := testObject(database, "d", tt.owners...)
+			got := OwnerReferenceDetector{IncludeNonController: tt.includeNonController}.Detect(obj)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got edges %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiscoverSeveralOwners checks every owner of a resource ends up in the
+// graph, rather than the last one overwriting the others
+func TestDiscoverSeveralOwners(t *testing.T) {
+	app := schema.GroupKind{Group: "x.io", Kind: "App"}
+	team := schema.GroupKind{Group: "x.io", Kind: "Team"}
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+	manifests := slices.Concat(
+		[]unstructured.Unstructured{testCRD("x.io", "App"), testCRD("x.io", "Team"), testCRD("x.io", "Database")},
+		[]unstructured.Unstructured{
+			testObject(app, "a"),
+			testObject(team, "t"),
+			testObject(database, "d", testOwner(app, "a", true), testOwner(team, "t", false)),
+		},
+	)
+
+	tests := []struct {
+		name                 string
+		includeNonController bool
+		want                 []schema.GroupKind
+	}{
+		{name: "controller only", want: []schema.GroupKind{app}},
+		{name: "every owner", includeNonController: true, want: []schema.GroupKind{app, team}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := discoverManifests(t, manifests, Options{IncludeNonControllerOwners: tt.includeNonController})
+			if got := sortedKinds(graph.Owners[database]); !slices.Equal(got, tt.want) {
+				t.Errorf("got owners %v of %s, want %v", got, database, tt.want)
+			}
+		})
+	}
+}
+
+// detectorTest is an object given to a detector as a YAML manifest and the
+// edges it should detect
+type detectorTest struct {
+	name     string
+	manifest string
+	want     []Edge
+}
+
+// testDetector runs d over the manifest of every test
+func testDetector(t *testing.T, d DependencyDetector, tests []detectorTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := d.Detect(testManifest(t, tt.manifest))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got edges %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package restoreorder
+
+import (
+	"fmt"
+	"io"
+)
+
+// Diff writes a unified diff between two restore-resource-priorities values
+// with one entry per line and reports whether they differ
+func Diff(w io.Writer, fromName, toName, from, to string) (bool, error) {
+	a := ParsePriorities(from)
+	b := ParsePriorities(to)
+
+	lines := diffLines(a, b)
+	changed := false
+	for _, line := range lines {
+		if line[0] != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n@@ -1,%d +1,%d @@\n", fromName, toName, len(a), len(b)); err != nil {
+		return true, err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// diffLines returns every line of a and b prefixed with ' ', '-' or '+'
+// using the longest common subsequence between them
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+"+b[j])
+	}
+	return lines
+}
@@ -0,0 +1,238 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/metadata"
+)
+
+// errNotServed is returned when the resources of a CRD are not served,
+// usually as the CRD was deleted after it was listed
+var errNotServed = errors.New("resource is not served")
+
+// FindAll finds all resources of given CRDs
+// only the metadata of each resource is fetched, as that is all that is
+// needed to work out owners, which keeps memory usage down on clusters
+// with many large custom resources.
+// the CRDs whose resources cannot be listed are returned as ListErrors
+// along with the resources of every other CRD, those removed since they
+// were listed have no resources
+func FindAll(ctx context.Context, crds *unstructured.UnstructuredList, client metadata.Interface, opts Options) ([]v1.PartialObjectMetadata, error) {
+	resources, _, err := findAll(ctx, crds, client, opts)
+	return resources, err
+}
+
+// findAll is FindAll also returning the names of the CRDs removed since
+// they were listed
+func findAll(ctx context.Context, crds *unstructured.UnstructuredList, client metadata.Interface, opts Options) ([]v1.PartialObjectMetadata, []string, error) {
+	if crds == nil {
+		return nil, nil, fmt.Errorf("cannot find resources from nil object")
+	}
+
+	// each goroutine sends the resources it found on the channel
+	// and the results are collected below, so no goroutine writes
+	// to the shared slice directly
+	type result struct {
+		items   []v1.PartialObjectMetadata
+		removed string
+		err     *ListError
+	}
+	found := make(chan result, len(crds.Items))
+	// at most opts.Concurrency goroutines list resources at once, each
+	// takes a slot before it starts and frees it once it is done. they are
+	// started in the background so the results are collected, and progress
+	// reported, while the remaining CRDs wait for a slot
+	slots := make(chan struct{}, opts.concurrency())
+	go func() {
+		wg := sync.WaitGroup{}
+		wg.Add(len(crds.Items))
+		for _, crd := range crds.Items {
+			slots <- struct{}{}
+			go func(crd unstructured.Unstructured) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				res, namespaced, err := GetRes(crd)
+				if err != nil {
+					found <- result{err: &ListError{Resource: crd.GetName(), Err: err}}
+					return
+				}
+
+				resources, err := findResources(ctx, client, res, namespaced, opts)
+				if errors.Is(err, errNotServed) {
+					removed, getErr := crdRemoved(ctx, client, crd)
+					if getErr != nil {
+						err = fmt.Errorf("%w, %w", err, getErr)
+					}
+					if removed {
+						slog.Warn("skipping CRD removed during the scan", "crd", crd.GetName())
+						found <- result{removed: crd.GetName()}
+						return
+					}
+				}
+				if err != nil {
+					found <- result{err: &ListError{Resource: crd.GetName(), Err: err}}
+					return
+				}
+
+				found <- result{items: resources}
+			}(crd)
+		}
+		wg.Wait()
+		close(found)
+	}()
+
+	allResources := []v1.PartialObjectMetadata{}
+	removed := []string{}
+	errs := ListErrors{}
+	start := time.Now()
+	done := 0
+	for r := range found {
+		switch {
+		case r.err != nil:
+			errs = append(errs, *r.err)
+		case r.removed != "":
+			removed = append(removed, r.removed)
+		default:
+			allResources = append(allResources, r.items...)
+		}
+		done++
+		if opts.Progress != nil {
+			opts.Progress(ScanProgress{Done: done, Total: len(crds.Items), Resources: len(allResources), Elapsed: time.Since(start)})
+		}
+	}
+	slices.Sort(removed)
+	if len(errs) > 0 {
+		return allResources, removed, errs.sorted()
+	}
+	return allResources, removed, nil
+}
+
+// crdRemoved reports whether crd, whose resources are not served, was
+// deleted or replaced since it was listed. a CRD that is still there is not
+// mistaken for a removed one, its resources are not served for another
+// reason such as an API server whose discovery is not up to date
+func crdRemoved(ctx context.Context, client metadata.Interface, crd unstructured.Unstructured) (bool, error) {
+	// the CRDs of aggregated APIs are made up from discovery, there is no object to get
+	if crd.GetUID() == "" {
+		return true, nil
+	}
+	gvr := schema.FromAPIVersionAndKind(crd.GetAPIVersion(), crd.GetKind()).GroupVersion().WithResource(CRDResource.Resource)
+	current, err := client.Resource(gvr).Get(ctx, crd.GetName(), v1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot get CRD: %w", err)
+	}
+	return current.UID != crd.GetUID() || current.DeletionTimestamp != nil, nil
+}
+
+// findResources lists every resource of res in scope, failing with
+// errNotServed when res is not served (anymore)
+func findResources(ctx context.Context, client metadata.Interface, res GVK, namespaced bool, opts Options) (_ []v1.PartialObjectMetadata, err error) {
+	ctx, span := tracer().Start(ctx, "ListResources", trace.WithAttributes(
+		attribute.String("group", res.GVR.Group),
+		attribute.String("resource", res.GVR.GroupResource().String()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	// get all resources whether they are namespaced or not
+	var list func(context.Context, v1.ListOptions) (*v1.PartialObjectMetadataList, error)
+	if namespaced {
+		list = client.Resource(res.GVR).Namespace("").List
+	} else {
+		list = client.Resource(res.GVR).List
+	}
+
+	ctx, cancel := opts.listContext(ctx)
+	defer cancel()
+
+	// get all resources of this type
+	resources, err := listPages(ctx, list, opts.listOptions(), opts.listBackoff())
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %w", errNotServed, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// only keep resources in the namespaces in scope
+	if namespaced {
+		resources = slices.DeleteFunc(resources, func(r v1.PartialObjectMetadata) bool {
+			return !opts.Scope.includesNamespace(r.Namespace)
+		})
+	}
+	resources = slices.DeleteFunc(resources, func(r v1.PartialObjectMetadata) bool {
+		return ignored(&r)
+	})
+
+	slog.Info("found resources", "kind", res.Kind, "count", len(resources))
+	span.SetAttributes(attribute.Int("count", len(resources)))
+
+	// the metadata API returns every item as a PartialObjectMetadata
+	// so set the type information back to that of the listed resource
+	for i := range resources {
+		resources[i].APIVersion = res.GVR.GroupVersion().String()
+		resources[i].Kind = res.Kind
+	}
+	return resources, nil
+}
+
+// listPages calls list until the server has returned every page
+// so large lists are fetched in chunks of opts.Limit rather than all at once,
+// retrying every page with backoff
+func listPages(ctx context.Context, list func(context.Context, v1.ListOptions) (*v1.PartialObjectMetadataList, error), opts v1.ListOptions, backoff wait.Backoff) ([]v1.PartialObjectMetadata, error) {
+	items := []v1.PartialObjectMetadata{}
+	for {
+		var page *v1.PartialObjectMetadataList
+		err := withRetry(ctx, backoff, func() (err error) {
+			page, err = list(ctx, opts)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+
+		if page.Continue == "" {
+			return items, nil
+		}
+		// the continue token carries the resource version the first page was served at
+		opts.Continue = page.Continue
+		opts.ResourceVersion = ""
+	}
+}
+
+// ScanProgress is how far listing the resources of the scanned CRDs got
+type ScanProgress struct {
+	// Done is the number of CRDs whose resources are listed, or failed to be
+	Done int
+	// Total is the number of CRDs whose resources are listed
+	Total int
+	// Resources is the number of resources listed so far
+	Resources int
+	// Elapsed is the time since listing started
+	Elapsed time.Duration
+}
+
+// ETA estimates the time left from the pace so far, 0 until a CRD is done
+func (p ScanProgress) ETA() time.Duration {
+	if p.Done == 0 {
+		return 0
+	}
+	return p.Elapsed / time.Duration(p.Done) * time.Duration(p.Total-p.Done)
+}
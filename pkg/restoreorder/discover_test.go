@@ -0,0 +1,359 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// testCRD returns a namespaced CRD serving kind in group as v1
+func testCRD(group, kind string) unstructured.Unstructured {
+	plural := strings.ToLower(kind) + "s"
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": CRDResource.GroupVersion().String(),
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": plural + "." + group},
+		"spec": map[string]interface{}{
+			"group": group,
+			"scope": "Namespaced",
+			"names": map[string]interface{}{"kind": kind, "plural": plural},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1", "served": true, "storage": true},
+			},
+		},
+	}}
+}
+
+// testObject returns the namespaced object kind.group/name controlled by owners
+func testObject(kind schema.GroupKind, name string, owners ...v1.OwnerReference) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(kind.WithVersion("v1").GroupVersion().String())
+	obj.SetKind(kind.Kind)
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	obj.SetUID(types.UID(kind.String() + "/" + name))
+	obj.SetOwnerReferences(owners)
+	return obj
+}
+
+// testOwner returns a reference to the object kind.group/name of testObject
+func testOwner(kind schema.GroupKind, name string, controller bool) v1.OwnerReference {
+	return v1.OwnerReference{
+		APIVersion: kind.WithVersion("v1").GroupVersion().String(),
+		Kind:       kind.Kind,
+		Name:       name,
+		UID:        types.UID(kind.String() + "/" + name),
+		Controller: &controller,
+	}
+}
+
+// testManifest parses a YAML manifest
+func testManifest(t *testing.T, manifest string) unstructured.Unstructured {
+	t.Helper()
+	obj := unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(manifest), &obj.Object); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+// discoverManifests discovers the graph of manifests served by fake clients
+func discoverManifests(t testing.TB, manifests []unstructured.Unstructured, opts Options) *Graph {
+	t.Helper()
+	dynamicClient, metadataClient, err := ManifestClients(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := Discover(context.Background(), dynamicClient, metadataClient, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+// chainManifests returns n CRDs of group x.io with a resource each, every
+// kind but the first controlled by the kind before it
+func chainManifests(n int) []unstructured.Unstructured {
+	manifests := []unstructured.Unstructured{}
+	for i := 0; i < n; i++ {
+		kind := schema.GroupKind{Group: "x.io", Kind: fmt.Sprintf("Kind%03d", i)}
+		manifests = append(manifests, testCRD(kind.Group, kind.Kind))
+		owners := []v1.OwnerReference{}
+		if i > 0 {
+			owner := schema.GroupKind{Group: "x.io", Kind: fmt.Sprintf("Kind%03d", i-1)}
+			owners = append(owners, testOwner(owner, "a", true))
+		}
+		manifests = append(manifests, testObject(kind, "a", owners...))
+	}
+	return manifests
+}
+
+// countingClient records the most list calls its metadata client had in flight at once
+type countingClient struct {
+	metadata.Interface
+	inFlight, most atomic.Int32
+}
+
+func (c *countingClient) Resource(gvr schema.GroupVersionResource) metadata.Getter {
+	getter := c.Interface.Resource(gvr)
+	return countingGetter{ResourceInterface: countingResource{getter, c}, getter: getter, client: c}
+}
+
+type countingGetter struct {
+	metadata.ResourceInterface
+	getter metadata.Getter
+	client *countingClient
+}
+
+func (g countingGetter) Namespace(namespace string) metadata.ResourceInterface {
+	return countingResource{g.getter.Namespace(namespace), g.client}
+}
+
+type countingResource struct {
+	metadata.ResourceInterface
+	client *countingClient
+}
+
+func (r countingResource) List(ctx context.Context, opts v1.ListOptions) (*v1.PartialObjectMetadataList, error) {
+	inFlight := r.client.inFlight.Add(1)
+	defer r.client.inFlight.Add(-1)
+	for {
+		most := r.client.most.Load()
+		if inFlight <= most || r.client.most.CompareAndSwap(most, inFlight) {
+			break
+		}
+	}
+	// the fake client serializes its calls, so the call is held outside of
+	// it for the others to overlap
+	time.Sleep(time.Millisecond)
+	return r.ResourceInterface.List(ctx, opts)
+}
+
+// TestDiscoverConcurrent lists the resources of many CRDs at once, run it
+// with go test -race to catch unsynchronized writes to the results
+func TestDiscoverConcurrent(t *testing.T) {
+	const crds = 200
+	dynamicClient, metadataClient, err := ManifestClients(chainManifests(crds))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, concurrency := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			client := &countingClient{Interface: metadataClient}
+			graph, err := Discover(context.Background(), dynamicClient, client, Options{Concurrency: concurrency})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(graph.Resources); got != crds {
+				t.Errorf("got %d kinds, want %d", got, crds)
+			}
+			for i := 1; i < crds; i++ {
+				kind := schema.GroupKind{Group: "x.io", Kind: fmt.Sprintf("Kind%03d", i)}
+				owner := schema.GroupKind{Group: "x.io", Kind: fmt.Sprintf("Kind%03d", i-1)}
+				if _, ok := graph.Owners[kind][owner]; !ok {
+					t.Errorf("missing edge from %s to %s", kind, owner)
+				}
+				if got := graph.Counts[kind]; got != 1 {
+					t.Errorf("got %d resources of %s, want 1", got, kind)
+				}
+			}
+
+			limit := int32(Options{Concurrency: concurrency}.concurrency())
+			if most := client.most.Load(); most > limit {
+				t.Errorf("listed %d CRDs at once, want at most %d", most, limit)
+			}
+		})
+	}
+}
+
+// TestDiscoverInvalidCRD checks a CRD that cannot be parsed is skipped in a
+// best effort scan and fails a strict one
+func TestDiscoverInvalidCRD(t *testing.T) {
+	invalid := testCRD("x.io", "Broken")
+	unstructured.RemoveNestedField(invalid.Object, "spec", "names")
+	dynamicClient, metadataClient, err := ManifestClients(chainManifests(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fake clients only serve CRDs that parse, so it is added afterwards
+	if _, err := dynamicClient.Resource(CRDResource).Create(context.Background(), &invalid, v1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := Discover(context.Background(), dynamicClient, metadataClient, Options{BestEffort: true})
+	if err != nil {
+		t.Fatalf("best effort scan failed: %s", err)
+	}
+	if len(graph.Skipped) != 1 || graph.Skipped[0].Resource != invalid.GetName() {
+		t.Errorf("got skipped %v, want %s", graph.Skipped, invalid.GetName())
+	}
+	if got := len(graph.Resources); got != 2 {
+		t.Errorf("got %d kinds, want 2", got)
+	}
+
+	_, err = Discover(context.Background(), dynamicClient, metadataClient, Options{})
+	listErrs := ListErrors{}
+	if !errors.As(err, &listErrs) || len(listErrs) != 1 || listErrs[0].Resource != invalid.GetName() {
+		t.Errorf("got strict scan error %v, want a list error of %s", err, invalid.GetName())
+	}
+}
+
+// notServedClient is a metadata client the resources named resource are not served by
+type notServedClient struct {
+	metadata.Interface
+	resource string
+}
+
+func (c notServedClient) Resource(gvr schema.GroupVersionResource) metadata.Getter {
+	getter := c.Interface.Resource(gvr)
+	if gvr.Resource != c.resource {
+		return getter
+	}
+	return notServedGetter{Getter: getter, gvr: gvr}
+}
+
+type notServedGetter struct {
+	metadata.Getter
+	gvr schema.GroupVersionResource
+}
+
+func (g notServedGetter) Namespace(string) metadata.ResourceInterface {
+	return g
+}
+
+func (g notServedGetter) List(context.Context, v1.ListOptions) (*v1.PartialObjectMetadataList, error) {
+	return nil, apierrors.NewNotFound(g.gvr.GroupResource(), "")
+}
+
+// TestDiscoverNotServed checks resources that are not served are only taken
+// to belong to a removed CRD when the CRD is gone, and are skipped otherwise
+func TestDiscoverNotServed(t *testing.T) {
+	crd := testCRD("x.io", "Kind001")
+
+	tests := []struct {
+		name    string
+		deleted bool
+	}{
+		{name: "CRD deleted", deleted: true},
+		{name: "CRD still there"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient, metadataClient, err := ManifestClients(chainManifests(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.deleted {
+				// the CRD is deleted after it was listed, but before its resources were
+				tracker := metadataClient.(*metadatafake.FakeMetadataClient).Tracker()
+				if err := tracker.Delete(CRDResource, "", crd.GetName()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			client := notServedClient{Interface: metadataClient, resource: "kind001s"}
+
+			graph, err := Discover(context.Background(), dynamicClient, client, Options{BestEffort: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.deleted {
+				if !slices.Equal(graph.Removed, []string{crd.GetName()}) || len(graph.Skipped) > 0 {
+					t.Errorf("got removed %v and skipped %v, want %s removed", graph.Removed, graph.Skipped, crd.GetName())
+				}
+				return
+			}
+			if len(graph.Removed) > 0 || len(graph.Skipped) != 1 || graph.Skipped[0].Resource != crd.GetName() {
+				t.Errorf("got removed %v and skipped %v, want %s skipped", graph.Removed, graph.Skipped, crd.GetName())
+			}
+			if !apierrors.IsNotFound(graph.Skipped[0].Err) {
+				t.Errorf("got error %v, want the not found error of the list", graph.Skipped[0].Err)
+			}
+		})
+	}
+}
+
+// gatedClient is a metadata client whose list calls past the first ones
+// started wait for wait to be closed, failing once timed out
+type gatedClient struct {
+	metadata.Interface
+	started atomic.Int32
+	first   int32
+	wait    chan struct{}
+}
+
+func (c *gatedClient) Resource(gvr schema.GroupVersionResource) metadata.Getter {
+	getter := c.Interface.Resource(gvr)
+	if gvr == CRDResource {
+		return getter
+	}
+	return gatedGetter{ResourceInterface: getter, getter: getter, client: c}
+}
+
+type gatedGetter struct {
+	metadata.ResourceInterface
+	getter metadata.Getter
+	client *gatedClient
+}
+
+func (g gatedGetter) Namespace(namespace string) metadata.ResourceInterface {
+	return gatedGetter{ResourceInterface: g.getter.Namespace(namespace), getter: g.getter, client: g.client}
+}
+
+func (g gatedGetter) List(ctx context.Context, opts v1.ListOptions) (*v1.PartialObjectMetadataList, error) {
+	if g.client.started.Add(1) > g.client.first {
+		select {
+		case <-g.client.wait:
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("timed out waiting for progress")
+		}
+	}
+	return g.ResourceInterface.List(ctx, opts)
+}
+
+// TestDiscoverProgress checks progress is reported as the resources of every
+// CRD are listed, rather than once all but the last CRDs are listed: the
+// CRDs after the first are only listed once its progress was reported
+func TestDiscoverProgress(t *testing.T) {
+	const crds = 4
+	dynamicClient, metadataClient, err := ManifestClients(chainManifests(crds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &gatedClient{Interface: metadataClient, first: 1, wait: make(chan struct{})}
+
+	reports := []ScanProgress{}
+	progress := func(p ScanProgress) {
+		if len(reports) == 0 {
+			close(client.wait)
+		}
+		reports = append(reports, p)
+	}
+	if _, err := Discover(context.Background(), dynamicClient, client, Options{Concurrency: 1, Progress: progress}); err != nil {
+		t.Fatalf("no progress was reported before the scan ended: %s", err)
+	}
+
+	if len(reports) != crds {
+		t.Fatalf("got %d progress reports, want %d", len(reports), crds)
+	}
+	for i, p := range reports {
+		if p.Done != i+1 || p.Total != crds {
+			t.Errorf("got report %d of %d CRDs done, want %d", p.Done, p.Total, i+1)
+		}
+	}
+}
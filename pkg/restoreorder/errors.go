@@ -0,0 +1,43 @@
+package restoreorder
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ListError is a resource whose objects could not be listed
+type ListError struct {
+	// Resource is the CRD name or resource.group of the resource
+	Resource string
+	Err      error
+}
+
+func (e ListError) Error() string {
+	return fmt.Sprintf("cannot list %s: %v", e.Resource, e.Err)
+}
+
+func (e ListError) Unwrap() error {
+	return e.Err
+}
+
+// ListErrors are every resource that could not be listed during a scan
+type ListErrors []ListError
+
+func (e ListErrors) Error() string {
+	lines := []string{fmt.Sprintf("cannot list %d resources:", len(e))}
+	for _, err := range e {
+		lines = append(lines, fmt.Sprintf("  %s: %v", err.Resource, err.Err))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sorted returns the errors sorted by resource
+func (e ListErrors) sorted() ListErrors {
+	sorted := slices.Clone(e)
+	slices.SortFunc(sorted, func(a, b ListError) int {
+		return cmp.Compare(a.Resource, b.Resource)
+	})
+	return sorted
+}
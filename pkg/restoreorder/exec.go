@@ -0,0 +1,226 @@
+package restoreorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ExecDetector runs an executable as a detector, so relationships between
+// proprietary kinds can be encoded without forking the tool. the executable
+// is started once and kept running: every object is written to its stdin as
+// a line of JSON, and it answers each with a line holding the JSON array of
+// the edges of the object, e.g.
+//
+//	[{"kind": "Database.acme.io", "owner": "Instance.acme.io"}]
+//
+// kinds are written Kind.group, or Kind for the core group, and an edge can
+// be made soft with "soft": true and a "weight", see Edge. the executable
+// is given the full objects of the CRDs in Groups and only the metadata of
+// the others. a detector that fails, or takes longer than Timeout to answer,
+// is stopped and disabled until it is closed, and its error is returned by Err
+type ExecDetector struct {
+	// Path is the executable
+	Path string
+	// Groups are the API groups whose full objects the executable reads
+	Groups []string
+	// Timeout bounds how long the executable takes to answer for an object,
+	// DefaultExecTimeout when 0
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	err    error
+}
+
+// DefaultExecTimeout is how long an ExecDetector waits for the edges of an
+// object unless its Timeout says otherwise
+const DefaultExecTimeout = 10 * time.Second
+
+// execEdge is an edge as an ExecDetector writes it
+type execEdge struct {
+	Kind   string `json:"kind"`
+	Owner  string `json:"owner"`
+	Soft   bool   `json:"soft"`
+	Weight int    `json:"weight"`
+}
+
+// NewExecDetector returns a detector running the executable at path, given
+// the full objects of the CRDs in groups
+func NewExecDetector(path string, groups []string) *ExecDetector {
+	return &ExecDetector{Path: path, Groups: groups}
+}
+
+// WantsObjects reports whether crd is in one of the groups of d
+func (d *ExecDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return slices.Contains(d.Groups, group)
+}
+
+// Detect returns the edges the executable finds for obj
+func (d *ExecDetector) Detect(obj unstructured.Unstructured) []Edge {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil
+	}
+
+	edges, err := d.detect(obj)
+	if err != nil {
+		slog.Error("disabling exec detector", "path", d.Path, "error", err)
+		d.err = err
+		if d.cmd != nil {
+			d.cmd.Process.Kill()
+		}
+		d.close()
+		return nil
+	}
+	return edges
+}
+
+// Err returns the error that disabled d, nil while it works
+func (d *ExecDetector) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *ExecDetector) String() string {
+	return "exec detector " + d.Path
+}
+
+// detect sends obj to the executable, starting it first, and reads its edges
+func (d *ExecDetector) detect(obj unstructured.Unstructured) ([]Edge, error) {
+	if d.cmd == nil {
+		if err := d.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %s %s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	line, err := d.exchange(append(data, '\n'))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	found := []execEdge{}
+	if err := json.Unmarshal(line, &found); err != nil {
+		return nil, fmt.Errorf("cannot decode edges of %s %s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	edges := make([]Edge, 0, len(found))
+	for _, e := range found {
+		if e.Kind == "" || e.Owner == "" {
+			return nil, fmt.Errorf("invalid edge %+v of %s %s", e, obj.GetKind(), obj.GetName())
+		}
+		edges = append(edges, Edge{Kind: schema.ParseGroupKind(e.Kind), Owner: schema.ParseGroupKind(e.Owner), Soft: e.Soft, Weight: e.Weight})
+	}
+	return edges, nil
+}
+
+// exchange writes line to the executable and reads its answer, killing it
+// when it does not answer within the timeout of d
+func (d *ExecDetector) exchange(line []byte) ([]byte, error) {
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type answer struct {
+		line []byte
+		err  error
+	}
+	// the pipes block until the executable reads or writes, so they are used
+	// in the background, the read fails once the executable is killed
+	answered := make(chan answer, 1)
+	stdin, stdout := d.stdin, d.stdout
+	go func() {
+		if _, err := stdin.Write(line); err != nil {
+			answered <- answer{err: fmt.Errorf("cannot write to detector: %w", err)}
+			return
+		}
+		line, err := stdout.ReadBytes('\n')
+		if err != nil {
+			err = fmt.Errorf("cannot read from detector: %w", err)
+		}
+		answered <- answer{line: line, err: err}
+	}()
+
+	select {
+	case a := <-answered:
+		return a.line, a.err
+	case <-ctx.Done():
+		d.cmd.Process.Kill()
+		return nil, fmt.Errorf("detector did not answer within %s", timeout)
+	}
+}
+
+// start starts the executable
+func (d *ExecDetector) start() error {
+	cmd := exec.Command(d.Path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("cannot open detector stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("cannot open detector stdout: %w", err)
+	}
+	// the diagnostics of the detector are logged
+	cmd.Stderr = slogWriter{path: d.Path}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cannot start detector: %w", err)
+	}
+	d.cmd, d.stdin, d.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// Close stops the executable and enables d again if it failed, the
+// executable is started again on the next object
+func (d *ExecDetector) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = nil
+	return d.close()
+}
+
+// close stops the executable, d.mu is held
+func (d *ExecDetector) close() error {
+	if d.cmd == nil {
+		return nil
+	}
+	// the executable exits once its stdin is closed
+	d.stdin.Close()
+	err := d.cmd.Wait()
+	d.cmd = nil
+	if err != nil {
+		return fmt.Errorf("detector %s failed: %w", d.Path, err)
+	}
+	return nil
+}
+
+// slogWriter logs every write of an executable as a warning
+type slogWriter struct {
+	path string
+}
+
+func (w slogWriter) Write(p []byte) (int, error) {
+	slog.Warn("exec detector", "path", w.path, "output", string(p))
+	return len(p), nil
+}
@@ -0,0 +1,177 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// testExecutable writes a shell script answering every object with script
+func testExecutable(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("exec detectors are tested with shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), "detector")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const (
+	// execValid answers every object with an edge to App.x.io
+	execValid = `while read -r line; do echo '[{"kind": "Database.x.io", "owner": "App.x.io"}]'; done`
+	// execMalformed answers with something other than JSON
+	execMalformed = `while read -r line; do echo 'not json'; done`
+	// execCrash exits as soon as it reads an object
+	execCrash = `read -r line; exit 3`
+	// execHang reads objects and never answers
+	execHang = `read -r line; exec sleep 60`
+)
+
+func TestExecDetector(t *testing.T) {
+	app := schema.GroupKind{Group: "x.io", Kind: "App"}
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+
+	tests := []struct {
+		name    string
+		script  string
+		want    []Edge
+		wantErr bool
+	}{
+		{name: "valid edge", script: execValid, want: []Edge{{Kind: database, Owner: app}}},
+		{name: "no edges", script: `while read -r line; do echo '[]'; done`, want: []Edge{}},
+		{name: "malformed output", script: execMalformed, wantErr: true},
+		{name: "invalid edge", script: `while read -r line; do echo '[{"kind": "Database.x.io"}]'; done`, wantErr: true},
+		{name: "crash", script: execCrash, wantErr: true},
+		{name: "hang", script: execHang, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewExecDetector(testExecutable(t, tt.script), nil)
+			d.Timeout = 200 * time.Millisecond
+			defer d.Close()
+
+			start := time.Now()
+			got := d.Detect(testObject(database, "d"))
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("detector answered after %s", elapsed)
+			}
+			if err := d.Err(); (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Errorf("got edges %v from a failed detector", got)
+				}
+				// a failed detector is disabled until it is closed
+				if got := d.Detect(testObject(database, "e")); got != nil {
+					t.Errorf("got edges %v from a disabled detector", got)
+				}
+				return
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got edges %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExecDetectorClose checks a closed detector stops its executable and
+// starts it again on the next object
+func TestExecDetectorClose(t *testing.T) {
+	d := NewExecDetector(testExecutable(t, execCrash), nil)
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+	d.Detect(testObject(database, "d"))
+	if d.Err() == nil {
+		t.Fatal("crashed detector has no error")
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("cannot close failed detector: %s", err)
+	}
+	if d.Err() != nil {
+		t.Errorf("closed detector still has error %v", d.Err())
+	}
+
+	d.Path = testExecutable(t, execValid)
+	if got := d.Detect(testObject(database, "d")); len(got) != 1 {
+		t.Errorf("got edges %v after closing, want 1", got)
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("cannot close detector: %s", err)
+	}
+	if d.cmd != nil {
+		t.Error("closed detector still runs its executable")
+	}
+}
+
+// TestDiscoverExecDetector checks a failing exec detector fails a strict
+// scan and is recorded as skipped in a best effort one
+func TestDiscoverExecDetector(t *testing.T) {
+	app := schema.GroupKind{Group: "x.io", Kind: "App"}
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+	manifests := []unstructured.Unstructured{
+		testCRD("x.io", "App"), testCRD("x.io", "Database"),
+		testObject(app, "a"), testObject(database, "d"),
+	}
+	dynamicClient, metadataClient, err := ManifestClients(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "malformed output", script: execMalformed},
+		{name: "crash", script: execCrash},
+		{name: "hang", script: execHang},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewExecDetector(testExecutable(t, tt.script), nil)
+			d.Timeout = 200 * time.Millisecond
+
+			_, err := Discover(context.Background(), dynamicClient, metadataClient, Options{Detectors: []DependencyDetector{d}})
+			listErrs := ListErrors{}
+			if !errors.As(err, &listErrs) || len(listErrs) != 1 || !errors.Is(listErrs[0].Err, errDetectorFailed) {
+				t.Fatalf("got strict scan error %v, want a failed detector", err)
+			}
+			if d.cmd != nil {
+				t.Error("detector still runs its executable after the scan")
+			}
+
+			graph, err := Discover(context.Background(), dynamicClient, metadataClient, Options{Detectors: []DependencyDetector{d}, BestEffort: true})
+			if err != nil {
+				t.Fatalf("best effort scan failed: %s", err)
+			}
+			if len(graph.Skipped) != 1 || graph.Skipped[0].Resource != d.String() {
+				t.Errorf("got skipped %v, want %s", graph.Skipped, d)
+			}
+			if report := graph.Report(); report.Counts[ReportDetectorFailed] != 1 {
+				t.Errorf("got report counts %v, want a failed detector", report.Counts)
+			}
+		})
+	}
+
+	d := NewExecDetector(testExecutable(t, execValid), nil)
+	graph, err := Discover(context.Background(), dynamicClient, metadataClient, Options{Detectors: []DependencyDetector{d}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := graph.Owners[database][app]; !ok {
+		t.Errorf("got owners %v of %s, want %s", graph.Owners[database], database, app)
+	}
+	if d.cmd != nil {
+		t.Error("detector still runs its executable after the scan")
+	}
+}
@@ -0,0 +1,86 @@
+package restoreorder
+
+import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Explanation describes why a resource is ordered where it is
+type Explanation struct {
+	// Resource is the CRD name of the explained resource
+	Resource string
+	// Depth is the length of the longest owner chain above the resource
+	Depth int
+	// Chains are every owner chain ending at the resource, root owner first
+	Chains [][]string
+	// Forcing are the owners one level above the resource, or kinds it is
+	// preferably restored after, the edges that put the resource at its depth
+	Forcing []string
+}
+
+// Kind returns the kind served by the named CRD
+func (g *Graph) Kind(resource string) (schema.GroupKind, bool) {
+	for kind, name := range g.Resources {
+		if name == resource {
+			return kind, true
+		}
+	}
+	return schema.GroupKind{}, false
+}
+
+// Explain returns the owner chains and depth of the named resource
+func (g *Graph) Explain(resource string) (Explanation, error) {
+	kind, ok := g.Kind(resource)
+	if !ok {
+		return Explanation{}, fmt.Errorf("resource %s is not served by any CRD", resource)
+	}
+
+	// the depth is the one the kind is ordered at, soft edges included
+	edges := g.orderingEdges()
+	depths := map[schema.GroupKind]int{}
+	d := depth(edges, kind, depths, map[schema.GroupKind]bool{})
+
+	explanation := Explanation{
+		Resource: resource,
+		Depth:    d,
+		Chains:   [][]string{},
+		Forcing:  []string{},
+	}
+
+	for owner := range edges[kind] {
+		if depth(edges, owner, depths, map[schema.GroupKind]bool{})+1 == d {
+			explanation.Forcing = append(explanation.Forcing, g.Name(owner))
+		}
+	}
+	slices.Sort(explanation.Forcing)
+
+	for _, chain := range g.chains(kind, map[schema.GroupKind]bool{}) {
+		names := []string{}
+		for _, k := range chain {
+			names = append(names, g.Name(k))
+		}
+		explanation.Chains = append(explanation.Chains, names)
+	}
+	slices.SortFunc(explanation.Chains, slices.Compare)
+
+	return explanation, nil
+}
+
+// chains returns every owner chain ending at kind, root owner first
+func (g *Graph) chains(kind schema.GroupKind, visiting map[schema.GroupKind]bool) [][]schema.GroupKind {
+	if len(g.Owners[kind]) == 0 || visiting[kind] {
+		return [][]schema.GroupKind{{kind}}
+	}
+	visiting[kind] = true
+	defer delete(visiting, kind)
+
+	result := [][]schema.GroupKind{}
+	for owner := range g.Owners[kind] {
+		for _, chain := range g.chains(owner, visiting) {
+			result = append(result, append(chain, kind))
+		}
+	}
+	return result
+}
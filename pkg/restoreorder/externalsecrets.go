@@ -0,0 +1,89 @@
+package restoreorder
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ExternalSecrets is the name of the External Secrets Operator detector
+const ExternalSecrets = "external-secrets"
+
+// ExternalSecretsGroup is the group of the External Secrets Operator kinds
+const ExternalSecretsGroup = "external-secrets.io"
+
+func init() {
+	RegisterDetector(ExternalSecrets, ExternalSecretsDetector{})
+}
+
+// the External Secrets Operator kinds ordered by the detector
+var (
+	esoExternalSecret        = schema.GroupKind{Group: ExternalSecretsGroup, Kind: "ExternalSecret"}
+	esoClusterExternalSecret = schema.GroupKind{Group: ExternalSecretsGroup, Kind: "ClusterExternalSecret"}
+	esoPushSecret            = schema.GroupKind{Group: ExternalSecretsGroup, Kind: "PushSecret"}
+	esoSecretStore           = schema.GroupKind{Group: ExternalSecretsGroup, Kind: "SecretStore"}
+)
+
+// ExternalSecretsDetector orders ExternalSecrets, ClusterExternalSecrets and
+// PushSecrets after the SecretStores, ClusterSecretStores and generators
+// they reference, so the secrets are synced as soon as they are restored
+// rather than failing until their stores exist
+type ExternalSecretsDetector struct{}
+
+// WantsObjects reports whether crd serves an External Secrets Operator kind
+func (ExternalSecretsDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return group == ExternalSecretsGroup
+}
+
+// Detect returns the edges from obj to the kinds of the stores and generators it references
+func (ExternalSecretsDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	spec := []string{"spec"}
+	switch kind {
+	case esoExternalSecret:
+	case esoClusterExternalSecret:
+		spec = []string{"spec", "externalSecretSpec"}
+	case esoPushSecret:
+		edges := []Edge{}
+		refs, _, _ := unstructured.NestedSlice(obj.Object, "spec", "secretStoreRefs")
+		for _, ref := range refs {
+			if ref, ok := ref.(map[string]interface{}); ok {
+				edges = append(edges, Edge{Kind: kind, Owner: storeRef(ref)})
+			}
+		}
+		return edges
+	default:
+		return nil
+	}
+
+	edges := []Edge{}
+	if ref, ok, _ := unstructured.NestedMap(obj.Object, append(spec, "secretStoreRef")...); ok {
+		edges = append(edges, Edge{Kind: kind, Owner: storeRef(ref)})
+	}
+	// entries can read from another store or a generator than the secret
+	for _, field := range []string{"data", "dataFrom"} {
+		entries, _, _ := unstructured.NestedSlice(obj.Object, append(spec, field)...)
+		for _, entry := range entries {
+			entry, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if ref, ok, _ := unstructured.NestedMap(entry, "sourceRef", "storeRef"); ok {
+				edges = append(edges, Edge{Kind: kind, Owner: storeRef(ref)})
+			}
+			if ref, ok := typedRef(entry, "sourceRef", "generatorRef"); ok {
+				edges = append(edges, Edge{Kind: kind, Owner: ref})
+			}
+		}
+	}
+	return edges
+}
+
+// storeRef returns the kind of a store reference, a SecretStore unless it names another kind
+func storeRef(ref map[string]interface{}) schema.GroupKind {
+	kind, _, _ := unstructured.NestedString(ref, "kind")
+	if kind == "" {
+		return esoSecretStore
+	}
+	return schema.GroupKind{Group: ExternalSecretsGroup, Kind: kind}
+}
@@ -0,0 +1,127 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestExternalSecretsDetector(t *testing.T) {
+	clusterSecretStore := schema.GroupKind{Group: ExternalSecretsGroup, Kind: "ClusterSecretStore"}
+	password := schema.GroupKind{Group: "generators.external-secrets.io", Kind: "Password"}
+
+	testDetector(t, ExternalSecretsDetector{}, []detectorTest{
+		{
+			name: "external secret",
+			manifest: `
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: db-credentials
+  namespace: shop
+spec:
+  refreshInterval: 1h
+  secretStoreRef:
+    name: vault
+  target:
+    name: db-credentials
+  data:
+  - secretKey: password
+    remoteRef:
+      key: shop/db
+`,
+			want: []Edge{{Kind: esoExternalSecret, Owner: esoSecretStore}},
+		},
+		{
+			name: "data sources",
+			manifest: `
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: db-credentials
+  namespace: shop
+spec:
+  secretStoreRef:
+    name: vault
+    kind: ClusterSecretStore
+  data:
+  - secretKey: username
+    remoteRef:
+      key: shop/db
+    sourceRef:
+      storeRef:
+        name: aws
+  dataFrom:
+  - sourceRef:
+      generatorRef:
+        apiVersion: generators.external-secrets.io/v1alpha1
+        kind: Password
+        name: db-password
+  - extract:
+      key: shop/db
+`,
+			want: []Edge{
+				{Kind: esoExternalSecret, Owner: clusterSecretStore},
+				{Kind: esoExternalSecret, Owner: esoSecretStore},
+				{Kind: esoExternalSecret, Owner: password},
+			},
+		},
+		{
+			name: "cluster external secret",
+			manifest: `
+apiVersion: external-secrets.io/v1beta1
+kind: ClusterExternalSecret
+metadata:
+  name: registry-credentials
+spec:
+  namespaceSelector:
+    matchLabels:
+      registry: private
+  externalSecretSpec:
+    secretStoreRef:
+      name: vault
+      kind: ClusterSecretStore
+    dataFrom:
+    - extract:
+        key: registry
+`,
+			want: []Edge{{Kind: esoClusterExternalSecret, Owner: clusterSecretStore}},
+		},
+		{
+			name: "push secret",
+			manifest: `
+apiVersion: external-secrets.io/v1alpha1
+kind: PushSecret
+metadata:
+  name: tls
+  namespace: shop
+spec:
+  secretStoreRefs:
+  - name: vault
+    kind: ClusterSecretStore
+  - name: aws
+  selector:
+    secret:
+      name: shop-tls
+`,
+			want: []Edge{
+				{Kind: esoPushSecret, Owner: clusterSecretStore},
+				{Kind: esoPushSecret, Owner: esoSecretStore},
+			},
+		},
+		{
+			name: "secret store",
+			manifest: `
+apiVersion: external-secrets.io/v1beta1
+kind: SecretStore
+metadata:
+  name: vault
+  namespace: shop
+spec:
+  provider:
+    vault:
+      server: https://vault.example.com
+`,
+		},
+	})
+}
@@ -0,0 +1,131 @@
+package restoreorder
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Flux is the name of the Flux detector
+const Flux = "flux"
+
+// FluxGroupSuffix is the suffix of every Flux API group
+const FluxGroupSuffix = ".toolkit.fluxcd.io"
+
+func init() {
+	RegisterDetector(Flux, FluxDetector{})
+}
+
+// FluxDetector orders Flux Kustomizations and HelmReleases after the sources
+// they are built from. their spec.dependsOn entries are not edges: they only
+// hold the name and namespace of another object of the same kind, and velero
+// orders whole resources, so it cannot restore a Kustomization before another
+// one; Flux waits for them to be ready itself after the restore. the entries
+// naming an object missing from the scan are recorded in Graph.FluxDependsOn
+// instead, as Flux holds back the objects depending on it forever
+type FluxDetector struct{}
+
+// WantsObjects reports whether crd serves a Flux kind
+func (FluxDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return strings.HasSuffix(group, FluxGroupSuffix)
+}
+
+// Detect returns the edges from a Kustomization or HelmRelease to its sources
+func (FluxDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	if !strings.HasSuffix(kind.Group, FluxGroupSuffix) {
+		return nil
+	}
+
+	edges := []Edge{}
+	for _, fields := range [][]string{
+		{"spec", "sourceRef"},
+		{"spec", "chartRef"},
+		{"spec", "chart", "spec", "sourceRef"},
+	} {
+		if source, ok := fluxRef(obj.Object, "source"+FluxGroupSuffix, fields...); ok {
+			edges = append(edges, Edge{Kind: kind, Owner: source})
+		}
+	}
+
+	return edges
+}
+
+// fluxRef returns the kind of the Flux reference at fields, whose
+// apiVersion is optional and defaults to group
+func fluxRef(obj map[string]interface{}, group string, fields ...string) (schema.GroupKind, bool) {
+	if ref, ok := typedRef(obj, fields...); ok {
+		return ref, true
+	}
+	kind, _, _ := unstructured.NestedString(obj, append(fields, "kind")...)
+	if kind == "" {
+		return schema.GroupKind{}, false
+	}
+	return schema.GroupKind{Group: group, Kind: kind}, true
+}
+
+// FluxDependency is a spec.dependsOn entry of a scanned Flux object, such
+// as a Kustomization or HelmRelease, naming an object that was not scanned.
+// a restore does not bring that object back, and Flux does not reconcile the
+// object depending on it until it exists
+type FluxDependency struct {
+	Kind      schema.GroupKind
+	Namespace string
+	Name      string
+	// DependsOn is the [namespace/]name of the missing object, of the same kind
+	DependsOn string
+}
+
+// findFluxDependsOn returns the spec.dependsOn entries of the Flux objects
+// of objects naming an object of their kind missing from objects, the
+// namespace of an entry defaults to the namespace of its object
+func findFluxDependsOn(objects map[types.UID]unstructured.Unstructured) []FluxDependency {
+	scanned := map[schema.GroupKind]map[string]bool{}
+	for _, obj := range objects {
+		kind := obj.GroupVersionKind().GroupKind()
+		if scanned[kind] == nil {
+			scanned[kind] = map[string]bool{}
+		}
+		scanned[kind][objectName(obj.GetNamespace(), obj.GetName())] = true
+	}
+
+	missing := []FluxDependency{}
+	for _, obj := range objects {
+		kind := obj.GroupVersionKind().GroupKind()
+		if !strings.HasSuffix(kind.Group, FluxGroupSuffix) {
+			continue
+		}
+		dependsOn, _, _ := unstructured.NestedSlice(obj.Object, "spec", "dependsOn")
+		for _, dep := range dependsOn {
+			dep, ok := dep.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(dep, "name")
+			if name == "" {
+				continue
+			}
+			namespace, _, _ := unstructured.NestedString(dep, "namespace")
+			if namespace == "" {
+				namespace = obj.GetNamespace()
+			}
+			if target := objectName(namespace, name); !scanned[kind][target] {
+				missing = append(missing, FluxDependency{Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName(), DependsOn: target})
+			}
+		}
+	}
+	slices.SortFunc(missing, func(a, b FluxDependency) int {
+		return cmp.Or(
+			cmp.Compare(a.Kind.String(), b.Kind.String()),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.DependsOn, b.DependsOn),
+		)
+	})
+	return missing
+}
@@ -0,0 +1,199 @@
+package restoreorder
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFluxDetector(t *testing.T) {
+	kustomization := schema.GroupKind{Group: "kustomize.toolkit.fluxcd.io", Kind: "Kustomization"}
+	helmRelease := schema.GroupKind{Group: "helm.toolkit.fluxcd.io", Kind: "HelmRelease"}
+	gitRepository := schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "GitRepository"}
+	ociRepository := schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "OCIRepository"}
+	helmRepository := schema.GroupKind{Group: "source.toolkit.fluxcd.io", Kind: "HelmRepository"}
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     []Edge
+	}{
+		{
+			name: "kustomization",
+			manifest: `
+apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: apps
+  namespace: flux-system
+spec:
+  interval: 10m
+  path: ./apps
+  prune: true
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+  dependsOn:
+  - name: infrastructure
+  - name: crds
+    namespace: flux-system
+`,
+			want: []Edge{{Kind: kustomization, Owner: gitRepository}},
+		},
+		{
+			name: "helm release from a chart template",
+			manifest: `
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  interval: 5m
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.x
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+        namespace: flux-system
+  dependsOn:
+  - name: redis
+    namespace: apps
+`,
+			want: []Edge{{Kind: helmRelease, Owner: helmRepository}},
+		},
+		{
+			name: "helm release from a chart reference",
+			manifest: `
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  interval: 5m
+  chartRef:
+    kind: OCIRepository
+    name: podinfo
+    namespace: flux-system
+`,
+			want: []Edge{{Kind: helmRelease, Owner: ociRepository}},
+		},
+		{
+			name: "source",
+			manifest: `
+apiVersion: source.toolkit.fluxcd.io/v1
+kind: GitRepository
+metadata:
+  name: flux-system
+  namespace: flux-system
+spec:
+  interval: 1m
+  url: https://github.com/org/gitops
+`,
+			want: []Edge{},
+		},
+		{
+			name: "other group",
+			manifest: `
+apiVersion: x.io/v1
+kind: App
+metadata:
+  name: a
+spec:
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FluxDetector{}.Detect(testManifest(t, tt.manifest))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got edges %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFluxDependsOn(t *testing.T) {
+	kustomization := schema.GroupKind{Group: "kustomize.toolkit.fluxcd.io", Kind: "Kustomization"}
+	helmRelease := schema.GroupKind{Group: "helm.toolkit.fluxcd.io", Kind: "HelmRelease"}
+	manifests := []unstructured.Unstructured{
+		testCRD(kustomization.Group, kustomization.Kind),
+		testCRD(helmRelease.Group, helmRelease.Kind),
+		testManifest(t, `
+apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: infra
+  namespace: flux-system
+`),
+		// infra is found in the namespace of apps, crds is missing
+		testManifest(t, `
+apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: apps
+  namespace: flux-system
+spec:
+  dependsOn:
+  - name: infra
+  - name: crds
+    namespace: platform
+`),
+		// a HelmRelease does not depend on a Kustomization of the same name
+		testManifest(t, `
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  dependsOn:
+  - name: infra
+    namespace: flux-system
+  - name: database
+`),
+		testManifest(t, `
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: database
+  namespace: shop
+`),
+	}
+
+	want := []FluxDependency{
+		{Kind: helmRelease, Namespace: "shop", Name: "shop", DependsOn: "flux-system/infra"},
+		{Kind: kustomization, Namespace: "flux-system", Name: "apps", DependsOn: "platform/crds"},
+	}
+	tests := []struct {
+		name      string
+		detectors []DependencyDetector
+		want      []FluxDependency
+	}{
+		{name: "owner references", detectors: []DependencyDetector{OwnerReferenceDetector{}}},
+		{name: "flux", detectors: []DependencyDetector{OwnerReferenceDetector{}, FluxDetector{}}, want: want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := discoverManifests(t, manifests, Options{Detectors: tt.detectors})
+			if !slices.Equal(graph.FluxDependsOn, tt.want) {
+				t.Errorf("got flux dependencies %v, want %v", graph.FluxDependsOn, tt.want)
+			}
+			if got := graph.Report().Counts[ReportFluxDependsOnMissing]; got != len(tt.want) {
+				t.Errorf("got %d %s report entries, want %d", got, ReportFluxDependsOnMissing, len(tt.want))
+			}
+			// entries of the same kind cannot be ordered by velero
+			if len(graph.Owners) > 0 {
+				t.Errorf("got owners %v, want none", graph.Owners)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package restoreorder
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// the names of the routing detectors
+const (
+	GatewayAPI = "gateway-api"
+	Istio      = "istio"
+)
+
+// the groups of the routing kinds
+const (
+	GatewayAPIGroup      = "gateway.networking.k8s.io"
+	IstioNetworkingGroup = "networking.istio.io"
+)
+
+// istioMesh is the reserved gateway name binding a VirtualService to the sidecars
+const istioMesh = "mesh"
+
+func init() {
+	RegisterDetector(GatewayAPI, GatewayAPIDetector{})
+	RegisterDetector(Istio, IstioDetector{})
+}
+
+// the routing kinds ordered by the detectors
+var (
+	gatewayAPIGateway      = schema.GroupKind{Group: GatewayAPIGroup, Kind: "Gateway"}
+	gatewayAPIGatewayClass = schema.GroupKind{Group: GatewayAPIGroup, Kind: "GatewayClass"}
+	istioGateway           = schema.GroupKind{Group: IstioNetworkingGroup, Kind: "Gateway"}
+	istioVirtualService    = schema.GroupKind{Group: IstioNetworkingGroup, Kind: "VirtualService"}
+)
+
+// GatewayAPIDetector orders Gateways after their GatewayClass and routes
+// after the Gateways, or other parents, they attach to through
+// spec.parentRefs, as some implementations report errors for every route
+// restored before its parent exists
+type GatewayAPIDetector struct{}
+
+// WantsObjects reports whether crd serves a Gateway API kind
+func (GatewayAPIDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return group == GatewayAPIGroup
+}
+
+// Detect returns the edges from a Gateway to its class or from a route to its parents
+func (GatewayAPIDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	if kind.Group != GatewayAPIGroup {
+		return nil
+	}
+	if kind == gatewayAPIGateway {
+		if class, _, _ := unstructured.NestedString(obj.Object, "spec", "gatewayClassName"); class != "" {
+			return []Edge{{Kind: kind, Owner: gatewayAPIGatewayClass}}
+		}
+		return nil
+	}
+
+	edges := []Edge{}
+	parents, _, _ := unstructured.NestedSlice(obj.Object, "spec", "parentRefs")
+	for _, parent := range parents {
+		parent, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// the group and kind default to a Gateway, an empty group is the core group
+		owner := gatewayAPIGateway
+		if group, ok, _ := unstructured.NestedString(parent, "group"); ok {
+			owner.Group = group
+		}
+		if kind, _, _ := unstructured.NestedString(parent, "kind"); kind != "" {
+			owner.Kind = kind
+		}
+		edge := Edge{Kind: kind, Owner: owner}
+		if owner != kind && !slices.Contains(edges, edge) {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// IstioDetector orders VirtualServices bound to Gateways through
+// spec.gateways after the Istio Gateways
+type IstioDetector struct{}
+
+// WantsObjects reports whether crd serves VirtualServices
+func (IstioDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	return group == IstioNetworkingGroup && kind == istioVirtualService.Kind
+}
+
+// Detect returns the edge from a VirtualService bound to gateways other
+// than the mesh to the Gateway kind
+func (IstioDetector) Detect(obj unstructured.Unstructured) []Edge {
+	if obj.GroupVersionKind().GroupKind() != istioVirtualService {
+		return nil
+	}
+	gateways, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "gateways")
+	if slices.ContainsFunc(gateways, func(g string) bool { return g != istioMesh }) {
+		return []Edge{{Kind: istioVirtualService, Owner: istioGateway}}
+	}
+	return nil
+}
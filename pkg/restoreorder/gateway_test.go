@@ -0,0 +1,166 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGatewayAPIDetector(t *testing.T) {
+	httpRoute := schema.GroupKind{Group: GatewayAPIGroup, Kind: "HTTPRoute"}
+	service := schema.GroupKind{Kind: "Service"}
+	listenerSet := schema.GroupKind{Group: "gateway.networking.x-k8s.io", Kind: "XListenerSet"}
+
+	testDetector(t, GatewayAPIDetector{}, []detectorTest{
+		{
+			name: "gateway",
+			manifest: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: public
+  namespace: ingress
+spec:
+  gatewayClassName: envoy
+  listeners:
+  - name: http
+    protocol: HTTP
+    port: 80
+`,
+			want: []Edge{{Kind: gatewayAPIGateway, Owner: gatewayAPIGatewayClass}},
+		},
+		{
+			name: "gateway without class",
+			manifest: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: public
+  namespace: ingress
+`,
+		},
+		{
+			// both parents default to a Gateway, which is ordered once
+			name: "route",
+			manifest: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  parentRefs:
+  - name: public
+    namespace: ingress
+    sectionName: http
+  - name: public
+    namespace: ingress
+    sectionName: https
+  rules:
+  - backendRefs:
+    - name: shop
+      port: 8080
+`,
+			want: []Edge{{Kind: httpRoute, Owner: gatewayAPIGateway}},
+		},
+		{
+			// an empty group is the core group, as for a mesh route attached to a Service
+			name: "route parents",
+			manifest: `
+apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  parentRefs:
+  - group: ""
+    kind: Service
+    name: shop
+  - group: gateway.networking.x-k8s.io
+    kind: XListenerSet
+    name: shop-listeners
+  - group: gateway.networking.k8s.io
+    kind: HTTPRoute
+    name: shop
+`,
+			want: []Edge{
+				{Kind: httpRoute, Owner: service},
+				{Kind: httpRoute, Owner: listenerSet},
+			},
+		},
+		{
+			name: "other group",
+			manifest: `
+apiVersion: networking.istio.io/v1
+kind: Gateway
+metadata:
+  name: public
+  namespace: ingress
+`,
+		},
+	})
+}
+
+func TestIstioDetector(t *testing.T) {
+	testDetector(t, IstioDetector{}, []detectorTest{
+		{
+			name: "gateways",
+			manifest: `
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  hosts: [shop.example.com]
+  gateways:
+  - mesh
+  - ingress/public
+  http:
+  - route:
+    - destination:
+        host: shop
+`,
+			want: []Edge{{Kind: istioVirtualService, Owner: istioGateway}},
+		},
+		{
+			name: "mesh only",
+			manifest: `
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  hosts: [shop]
+  gateways: [mesh]
+`,
+		},
+		{
+			name: "no gateways",
+			manifest: `
+apiVersion: networking.istio.io/v1
+kind: VirtualService
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  hosts: [shop]
+`,
+		},
+		{
+			name: "gateway",
+			manifest: `
+apiVersion: networking.istio.io/v1
+kind: Gateway
+metadata:
+  name: public
+  namespace: ingress
+spec:
+  selector:
+    istio: ingressgateway
+`,
+		},
+	})
+}
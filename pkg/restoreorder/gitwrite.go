@@ -0,0 +1,629 @@
+package restoreorder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GitTarget is a file in a Git repository holding the restore priorities,
+// updated through a pull request when the computed order drifts from it
+type GitTarget struct {
+	// Provider is the API the repository is hosted behind, github or gitlab
+	Provider string
+	// Repo is the owner/name of a GitHub repository or the path of a GitLab project
+	Repo string
+	// Path is the file holding the priorities
+	Path string
+	// Branch is the branch the pull request is opened against
+	Branch string
+	// Format is how the file holds the priorities: helm-values, the values of
+	// the vmware-tanzu/velero chart, or kustomize-patch, any manifest or
+	// patch passing the restore-resource-priorities flag to the Velero server
+	Format string
+	// APIURL is the API to call, the public GitHub or GitLab API when empty
+	APIURL string
+	// Token authenticates the API calls
+	Token string
+	// Client calls the API, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// ParseGitTarget parses a target given as comma separated key=value pairs,
+// e.g. repo=org/gitops,path=velero/values.yaml,branch=main.
+// provider defaults to github, branch to main and format to helm-values
+func ParseGitTarget(value string) (*GitTarget, error) {
+	t := &GitTarget{Provider: "github", Branch: "main", Format: "helm-values"}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid git target %q, expected key=value pairs", value)
+		}
+		switch key {
+		case "provider":
+			t.Provider = val
+		case "repo":
+			t.Repo = val
+		case "path":
+			t.Path = val
+		case "branch":
+			t.Branch = val
+		case "format":
+			t.Format = val
+		case "url":
+			t.APIURL = strings.TrimSuffix(val, "/")
+		default:
+			return nil, fmt.Errorf("invalid git target %q, unknown key %s", value, key)
+		}
+	}
+	if t.Repo == "" || t.Path == "" {
+		return nil, fmt.Errorf("invalid git target %q, repo and path are required", value)
+	}
+	if t.Provider != "github" && t.Provider != "gitlab" {
+		return nil, fmt.Errorf("invalid git provider %q, expected github or gitlab", t.Provider)
+	}
+	if t.Format != "helm-values" && t.Format != "kustomize-patch" {
+		return nil, fmt.Errorf("invalid git target format %q, expected helm-values or kustomize-patch", t.Format)
+	}
+	if t.APIURL == "" {
+		t.APIURL = "https://api.github.com"
+		if t.Provider == "gitlab" {
+			t.APIURL = "https://gitlab.com/api/v4"
+		}
+	}
+	return t, nil
+}
+
+// Write opens a pull request setting the priorities in the target file when
+// they differ from priorities, returning its URL. the branch of the pull
+// request is named after the priorities, so a value with an open pull request
+// is not proposed again and an empty URL is returned. a branch left without
+// the commit or the pull request by an earlier run is picked up where it stopped
+func (t *GitTarget) Write(ctx context.Context, priorities string) (string, error) {
+	content, _, err := t.getFile(ctx, t.Branch)
+	if err != nil {
+		return "", err
+	}
+	updated, changed, err := setFilePriorities(content, t.Format, priorities)
+	if err != nil {
+		return "", fmt.Errorf("cannot update %s: %w", t.Path, err)
+	}
+	if !changed {
+		slog.Info("restore priorities in git are up to date", "repo", t.Repo, "path", t.Path)
+		return "", nil
+	}
+
+	sum := sha256.Sum256([]byte(priorities))
+	branch := "whoisyourdaddyandwhatdoeshedo/restore-priorities-" + hex.EncodeToString(sum[:4])
+	exists, err := t.branchExists(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		// the branch can be created concurrently, it then exists too
+		if err := t.createBranch(ctx, branch); err != nil && !errors.Is(err, errBranchExists) {
+			return "", err
+		}
+	}
+	proposed, err := t.findPullRequest(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+	if proposed != "" {
+		slog.Info("restore priorities already proposed", "repo", t.Repo, "branch", branch, "pullRequest", proposed)
+		return "", nil
+	}
+
+	// the branch holds the commit already when opening the pull request failed
+	title := "Update Velero restore-resource-priorities"
+	current, sha, err := t.getFile(ctx, branch)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(current, updated) {
+		if err := t.commitFile(ctx, branch, sha, updated, title); err != nil {
+			return "", err
+		}
+	}
+	body := "The restore order computed from the owner references between the custom resources of the cluster changed.\n\n```diff\n"
+	diff := &strings.Builder{}
+	Diff(diff, "current", "computed", currentPriorities(content, t.Format), priorities)
+	body += diff.String() + "```\n"
+	return t.openPullRequest(ctx, branch, title, body)
+}
+
+// helmPrioritiesPath is where the velero chart takes the priorities from
+var helmPrioritiesPath = []string{"configuration", "restoreResourcePriorities"}
+
+// flagPattern matches the restore-resource-priorities flag and its value in a manifest
+var flagPattern = regexp.MustCompile(regexp.QuoteMeta(RestoreFlag) + `=[^\s"']*`)
+
+// setFilePriorities returns content with the priorities set to value and
+// whether they changed. helm values are patched in place, only the text of
+// the priorities changes, so GitOps diffs hold nothing else
+func setFilePriorities(content []byte, format, value string) ([]byte, bool, error) {
+	if format == "kustomize-patch" {
+		if !flagPattern.Match(content) {
+			return nil, false, fmt.Errorf("no %s flag found", RestoreFlag)
+		}
+		updated := flagPattern.ReplaceAllLiteral(content, []byte(RestoreFlag+"="+value))
+		return updated, !bytes.Equal(updated, content), nil
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(content, doc); err != nil {
+		return nil, false, fmt.Errorf("cannot parse values: %w", err)
+	}
+	if node := helmValue(doc); node != nil && node.Kind == yaml.ScalarNode && node.Tag != "!!null" && node.Value == value {
+		return content, false, nil
+	}
+	if patched, ok := patchHelmValues(content, doc, value); ok {
+		return patched, true, nil
+	}
+	return encodeHelmValues(doc, value)
+}
+
+// helmValue returns the node of the priorities in the values doc, nil when absent
+func helmValue(doc *yaml.Node) *yaml.Node {
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	node := doc.Content[0]
+	for _, key := range helmPrioritiesPath {
+		_, value := mappingEntry(node, key)
+		if value == nil {
+			return nil
+		}
+		node = value
+	}
+	return node
+}
+
+// mappingEntry returns the key and value nodes of key in mapping, nil when absent
+func mappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// patchHelmValues sets the priorities in the text of content, parsed as
+// doc, replacing the text of their value or inserting the missing keys.
+// it reports false for layouts it does not patch, such as flow mappings or
+// block scalars, and when the patched values do not parse back to value
+func patchHelmValues(content []byte, doc *yaml.Node, value string) ([]byte, bool) {
+	quoted := strconv.Quote(value)
+	key := helmPrioritiesPath[1] + ": " + quoted
+	var patched []byte
+	switch {
+	case len(doc.Content) == 0:
+		patched = appendLines(content, helmPrioritiesPath[0]+":", "  "+key)
+	case doc.Content[0].Kind != yaml.MappingNode || doc.Content[0].Style&yaml.FlowStyle != 0:
+		return nil, false
+	default:
+		configKey, configuration := mappingEntry(doc.Content[0], helmPrioritiesPath[0])
+		switch {
+		case configuration == nil:
+			patched = appendLines(content, helmPrioritiesPath[0]+":", "  "+key)
+		case configuration.Kind == yaml.ScalarNode && configuration.Tag == "!!null" && configuration.Value == "":
+			// a bare configuration:, the key goes on the next line
+			end := lineEnd(content, configKey.Line)
+			indent := strings.Repeat(" ", configKey.Column+1)
+			patched = splice(content, end, end, "\n"+indent+key)
+		case configuration.Kind != yaml.MappingNode || configuration.Style&yaml.FlowStyle != 0 || len(configuration.Content) == 0:
+			return nil, false
+		default:
+			_, node := mappingEntry(configuration, helmPrioritiesPath[1])
+			if node == nil {
+				// the key goes first, indented as the other keys
+				first := configuration.Content[0]
+				start := lineStart(content, first.Line)
+				patched = splice(content, start, start, strings.Repeat(" ", first.Column-1)+key+"\n")
+				break
+			}
+			start, end, ok := scalarRange(content, node)
+			if !ok {
+				return nil, false
+			}
+			if start == end {
+				// an empty value, right after the colon
+				quoted = " " + quoted
+			}
+			patched = splice(content, start, end, quoted)
+		}
+	}
+
+	check := &yaml.Node{}
+	if err := yaml.Unmarshal(patched, check); err != nil {
+		return nil, false
+	}
+	if node := helmValue(check); node == nil || node.Value != value {
+		return nil, false
+	}
+	return patched, true
+}
+
+// scalarRange returns the offsets of the text of the single line scalar node in content
+func scalarRange(content []byte, node *yaml.Node) (int, int, bool) {
+	if node.Kind != yaml.ScalarNode {
+		return 0, 0, false
+	}
+	start, ok := offset(content, node.Line, node.Column)
+	if !ok {
+		return 0, 0, false
+	}
+	line := content[start:lineEnd(content, node.Line)]
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, start + i + 1, true
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start, start + i + 1, true
+			}
+		}
+	case 0:
+		// a plain scalar ends at a comment or the end of the line
+		if i := bytes.Index(line, []byte(" #")); i >= 0 {
+			line = line[:i]
+		}
+		if bytes.HasPrefix(line, []byte("#")) {
+			line = nil
+		}
+		return start, start + len(bytes.TrimRight(line, " \t\r")), true
+	}
+	return 0, 0, false
+}
+
+// offset returns the offset of the 1-based line and column in content
+func offset(content []byte, line, column int) (int, bool) {
+	start := lineStart(content, line)
+	// columns count characters rather than bytes
+	for i := range string(content[start:lineEnd(content, line)]) {
+		if column--; column == 0 {
+			return start + i, true
+		}
+	}
+	if column == 1 {
+		return lineEnd(content, line), true
+	}
+	return 0, false
+}
+
+// lineStart returns the offset of the 1-based line in content
+func lineStart(content []byte, line int) int {
+	start := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(content[start:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		start += i + 1
+	}
+	return start
+}
+
+// lineEnd returns the offset of the end of the 1-based line in content, before its newline
+func lineEnd(content []byte, line int) int {
+	start := lineStart(content, line)
+	if i := bytes.IndexByte(content[start:], '\n'); i >= 0 {
+		return start + i
+	}
+	return len(content)
+}
+
+// splice returns content with its bytes from start to end replaced by text
+func splice(content []byte, start, end int, text string) []byte {
+	return slices.Concat(content[:start], []byte(text), content[end:])
+}
+
+// appendLines returns content with lines added at its end
+func appendLines(content []byte, lines ...string) []byte {
+	out := slices.Clone(content)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	for _, line := range lines {
+		out = append(append(out, line...), '\n')
+	}
+	return out
+}
+
+// encodeHelmValues sets the priorities in doc and encodes it, for the values
+// patchHelmValues cannot patch in place
+func encodeHelmValues(doc *yaml.Node, value string) ([]byte, bool, error) {
+	if len(doc.Content) == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	node := doc.Content[0]
+	for _, key := range helmPrioritiesPath {
+		// a key without a value, such as a bare configuration:, is null
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			*node = yaml.Node{Kind: yaml.MappingNode}
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, false, fmt.Errorf("%s is not a mapping", strings.Join(helmPrioritiesPath, "."))
+		}
+		node = mappingValue(node, key)
+	}
+	*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle}
+
+	out := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, false, fmt.Errorf("cannot encode values: %w", err)
+	}
+	return out.Bytes(), true, nil
+}
+
+// mappingValue returns the value of key in mapping, adding an empty mapping when absent
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// currentPriorities returns the priorities content holds, empty when it holds none
+func currentPriorities(content []byte, format string) string {
+	if format == "kustomize-patch" {
+		return strings.TrimPrefix(string(flagPattern.Find(content)), RestoreFlag+"=")
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return ""
+	}
+	configuration, _ := values[helmPrioritiesPath[0]].(map[string]interface{})
+	value, _ := configuration[helmPrioritiesPath[1]].(string)
+	return value
+}
+
+// getFile returns the content of the target file on ref and its blob SHA,
+// which GitHub needs to update it
+func (t *GitTarget) getFile(ctx context.Context, ref string) ([]byte, string, error) {
+	var file struct {
+		Content string `json:"content"`
+		SHA     string `json:"sha"`
+	}
+	path := fmt.Sprintf("/repos/%s/contents/%s?ref=%s", t.Repo, t.Path, url.QueryEscape(ref))
+	if t.Provider == "gitlab" {
+		path = fmt.Sprintf("%s/repository/files/%s?ref=%s", t.project(), url.PathEscape(t.Path), url.QueryEscape(ref))
+	}
+	if _, err := t.call(ctx, http.MethodGet, path, nil, &file); err != nil {
+		return nil, "", fmt.Errorf("cannot get %s from %s: %w", t.Path, t.Repo, err)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot decode %s: %w", t.Path, err)
+	}
+	return content, file.SHA, nil
+}
+
+// errBranchExists is returned creating a branch that already exists
+var errBranchExists = errors.New("branch already exists")
+
+// branchExists reports whether branch exists
+func (t *GitTarget) branchExists(ctx context.Context, branch string) (bool, error) {
+	path := fmt.Sprintf("/repos/%s/git/ref/heads/%s", t.Repo, branch)
+	if t.Provider == "gitlab" {
+		path = fmt.Sprintf("%s/repository/branches/%s", t.project(), url.PathEscape(branch))
+	}
+	status, err := t.call(ctx, http.MethodGet, path, nil, nil)
+	if status == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot get branch %s of %s: %w", branch, t.Repo, err)
+	}
+	return true, nil
+}
+
+// createBranch creates branch from the target branch, failing with
+// errBranchExists when it already exists
+func (t *GitTarget) createBranch(ctx context.Context, branch string) error {
+	var status int
+	var err error
+	// the messages the APIs refuse existing branches with, other
+	// unprocessable or bad requests, such as invalid names, are errors
+	exists := "Reference already exists"
+	if t.Provider == "gitlab" {
+		path := fmt.Sprintf("%s/repository/branches?branch=%s&ref=%s", t.project(), url.QueryEscape(branch), url.QueryEscape(t.Branch))
+		status, err = t.call(ctx, http.MethodPost, path, nil, nil)
+		exists = "Branch already exists"
+	} else {
+		var ref struct {
+			Object struct {
+				SHA string `json:"sha"`
+			} `json:"object"`
+		}
+		if _, err := t.call(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/git/ref/heads/%s", t.Repo, t.Branch), nil, &ref); err != nil {
+			return fmt.Errorf("cannot get branch %s of %s: %w", t.Branch, t.Repo, err)
+		}
+		status, err = t.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/git/refs", t.Repo), map[string]string{
+			"ref": "refs/heads/" + branch,
+			"sha": ref.Object.SHA,
+		}, nil)
+	}
+	if err != nil {
+		if (status == http.StatusUnprocessableEntity || status == http.StatusBadRequest) && strings.Contains(err.Error(), exists) {
+			err = errBranchExists
+		}
+		return fmt.Errorf("cannot create branch %s in %s: %w", branch, t.Repo, err)
+	}
+	return nil
+}
+
+// findPullRequest returns the URL of the open pull request, or merge request
+// on GitLab, from branch into the target branch, empty when there is none
+func (t *GitTarget) findPullRequest(ctx context.Context, branch string) (string, error) {
+	var prs []struct {
+		HTMLURL string `json:"html_url"`
+		WebURL  string `json:"web_url"`
+	}
+	var err error
+	if t.Provider == "gitlab" {
+		_, err = t.call(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests?state=opened&source_branch=%s&target_branch=%s",
+			t.project(), url.QueryEscape(branch), url.QueryEscape(t.Branch)), nil, &prs)
+	} else {
+		// GitHub takes the head branch as owner:branch
+		owner, _, _ := strings.Cut(t.Repo, "/")
+		_, err = t.call(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls?state=open&head=%s&base=%s",
+			t.Repo, url.QueryEscape(owner+":"+branch), url.QueryEscape(t.Branch)), nil, &prs)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot list pull requests of %s: %w", t.Repo, err)
+	}
+	for _, pr := range prs {
+		if pr.WebURL != "" {
+			return pr.WebURL, nil
+		}
+		if pr.HTMLURL != "" {
+			return pr.HTMLURL, nil
+		}
+	}
+	return "", nil
+}
+
+// commitFile commits content as the target file on branch
+func (t *GitTarget) commitFile(ctx context.Context, branch, sha string, content []byte, message string) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	var err error
+	if t.Provider == "gitlab" {
+		_, err = t.call(ctx, http.MethodPut, fmt.Sprintf("%s/repository/files/%s", t.project(), url.PathEscape(t.Path)), map[string]string{
+			"branch":         branch,
+			"content":        encoded,
+			"encoding":       "base64",
+			"commit_message": message,
+		}, nil)
+	} else {
+		_, err = t.call(ctx, http.MethodPut, fmt.Sprintf("/repos/%s/contents/%s", t.Repo, t.Path), map[string]string{
+			"branch":  branch,
+			"content": encoded,
+			"sha":     sha,
+			"message": message,
+		}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot commit %s to %s: %w", t.Path, t.Repo, err)
+	}
+	return nil
+}
+
+// openPullRequest opens a pull request, a merge request on GitLab, from
+// branch into the target branch and returns its URL
+func (t *GitTarget) openPullRequest(ctx context.Context, branch, title, body string) (string, error) {
+	var pr struct {
+		HTMLURL string `json:"html_url"`
+		WebURL  string `json:"web_url"`
+	}
+	var err error
+	if t.Provider == "gitlab" {
+		_, err = t.call(ctx, http.MethodPost, t.project()+"/merge_requests", map[string]string{
+			"source_branch": branch,
+			"target_branch": t.Branch,
+			"title":         title,
+			"description":   body,
+		}, &pr)
+	} else {
+		_, err = t.call(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/pulls", t.Repo), map[string]string{
+			"head":  branch,
+			"base":  t.Branch,
+			"title": title,
+			"body":  body,
+		}, &pr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot open pull request in %s: %w", t.Repo, err)
+	}
+	if pr.WebURL != "" {
+		return pr.WebURL, nil
+	}
+	return pr.HTMLURL, nil
+}
+
+// project returns the API path of the GitLab project
+func (t *GitTarget) project() string {
+	return "/projects/" + url.PathEscape(t.Repo)
+}
+
+// call calls the API with body encoded as JSON, decoding the response into
+// out when set, and returns the response status
+func (t *GitTarget) call(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, t.APIURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.Token != "" {
+		if t.Provider == "gitlab" {
+			req.Header.Set("PRIVATE-TOKEN", t.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+t.Token)
+			req.Header.Set("Accept", "application/vnd.github+json")
+		}
+	}
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("cannot decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
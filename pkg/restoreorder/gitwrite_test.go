@@ -0,0 +1,351 @@
+package restoreorder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSetFilePriorities(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		changed bool
+	}{
+		{
+			name:    "replaces the value only",
+			content: "# velero values\nimage:\n  tag:   v1.15 # pinned\nconfiguration:\n  restoreResourcePriorities: \"a,b\" # managed\n  uploaderType: kopia\n",
+			want:    "# velero values\nimage:\n  tag:   v1.15 # pinned\nconfiguration:\n  restoreResourcePriorities: \"c,d\" # managed\n  uploaderType: kopia\n",
+			changed: true,
+		},
+		{
+			name:    "plain value",
+			content: "configuration:\n    restoreResourcePriorities: a,b\n    uploaderType: kopia\n",
+			want:    "configuration:\n    restoreResourcePriorities: \"c,d\"\n    uploaderType: kopia\n",
+			changed: true,
+		},
+		{
+			name:    "single quoted value",
+			content: "configuration:\n  restoreResourcePriorities: 'a,b'\n",
+			want:    "configuration:\n  restoreResourcePriorities: \"c,d\"\n",
+			changed: true,
+		},
+		{
+			name:    "empty value",
+			content: "configuration:\n  restoreResourcePriorities:\n  uploaderType: kopia\n",
+			want:    "configuration:\n  restoreResourcePriorities: \"c,d\"\n  uploaderType: kopia\n",
+			changed: true,
+		},
+		{
+			name:    "missing key",
+			content: "configuration:\n    uploaderType:   kopia\n",
+			want:    "configuration:\n    restoreResourcePriorities: \"c,d\"\n    uploaderType:   kopia\n",
+			changed: true,
+		},
+		{
+			name:    "bare configuration",
+			content: "configuration: # set below\nimage: {}\n",
+			want:    "configuration: # set below\n  restoreResourcePriorities: \"c,d\"\nimage: {}\n",
+			changed: true,
+		},
+		{
+			name:    "missing configuration",
+			content: "image:\n  tag: v1.15",
+			want:    "image:\n  tag: v1.15\nconfiguration:\n  restoreResourcePriorities: \"c,d\"\n",
+			changed: true,
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    "configuration:\n  restoreResourcePriorities: \"c,d\"\n",
+			changed: true,
+		},
+		{
+			name:    "flow mapping is encoded again",
+			content: "configuration: {restoreResourcePriorities: a}\n",
+			want:    "configuration: {restoreResourcePriorities: \"c,d\"}\n",
+			changed: true,
+		},
+		{
+			name:    "up to date",
+			content: "configuration:\n  restoreResourcePriorities: c,d # managed\n",
+			want:    "configuration:\n  restoreResourcePriorities: c,d # managed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := setFilePriorities([]byte(tt.content), "helm-values", "c,d")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.changed {
+				t.Errorf("got changed %t, want %t", changed, tt.changed)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeGit serves the parts of the GitHub or GitLab API a GitTarget calls,
+// for the repository org/gitops holding values.yaml
+type fakeGit struct {
+	t        *testing.T
+	provider string
+
+	mu sync.Mutex
+	// files are the content of values.yaml on every branch
+	files map[string]string
+	// pulls are the source branches of the open pull requests
+	pulls []string
+	// commits are the branches committed to
+	commits []string
+	// failPulls fails opening pull requests
+	failPulls bool
+}
+
+func newFakeGit(t *testing.T, provider, values string) (*fakeGit, *GitTarget) {
+	f := &fakeGit{t: t, provider: provider, files: map[string]string{"main": values}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	target, err := ParseGitTarget(fmt.Sprintf("provider=%s,repo=org/gitops,path=values.yaml,url=%s", provider, srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, target
+}
+
+func (f *fakeGit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reply := func(status int, v interface{}) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	body := map[string]string{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	query := r.URL.Query()
+
+	prefix := "/repos/org/gitops"
+	if f.provider == "gitlab" {
+		prefix = "/projects/" + url.PathEscape("org/gitops")
+	}
+	path, ok := strings.CutPrefix(r.URL.EscapedPath(), prefix)
+	if !ok {
+		f.t.Errorf("unexpected call %s %s", r.Method, r.URL)
+		reply(http.StatusNotFound, nil)
+		return
+	}
+	route := r.Method + " " + path
+
+	switch {
+	case route == "GET /contents/values.yaml", route == "GET /repository/files/values.yaml":
+		content, ok := f.files[query.Get("ref")]
+		if !ok {
+			reply(http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		reply(http.StatusOK, map[string]string{"content": base64.StdEncoding.EncodeToString([]byte(content)), "sha": "sha-" + content})
+
+	case route == "PUT /contents/values.yaml", route == "PUT /repository/files/values.yaml":
+		branch := body["branch"]
+		current, ok := f.files[branch]
+		if !ok || (f.provider == "github" && body["sha"] != "sha-"+current) {
+			reply(http.StatusConflict, map[string]string{"message": "sha does not match"})
+			return
+		}
+		content, _ := base64.StdEncoding.DecodeString(body["content"])
+		f.files[branch] = string(content)
+		f.commits = append(f.commits, branch)
+		reply(http.StatusOK, map[string]string{})
+
+	case r.Method == http.MethodGet && (strings.HasPrefix(path, "/git/ref/heads/") || strings.HasPrefix(path, "/repository/branches/")):
+		branch, _ := url.PathUnescape(path[strings.LastIndex(path, "/heads/")+len("/heads/"):])
+		if f.provider == "gitlab" {
+			branch, _ = url.PathUnescape(strings.TrimPrefix(path, "/repository/branches/"))
+		}
+		if _, ok := f.files[branch]; !ok {
+			reply(http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{"object": map[string]string{"sha": "commit-" + branch}})
+
+	case route == "POST /git/refs", route == "POST /repository/branches":
+		branch := strings.TrimPrefix(body["ref"], "refs/heads/")
+		status, exists := http.StatusUnprocessableEntity, "Reference already exists"
+		if f.provider == "gitlab" {
+			branch = query.Get("branch")
+			status, exists = http.StatusBadRequest, "Branch already exists"
+		}
+		if strings.Contains(branch, "..") {
+			reply(status, map[string]string{"message": "Invalid branch name"})
+			return
+		}
+		if _, ok := f.files[branch]; ok {
+			reply(status, map[string]string{"message": exists})
+			return
+		}
+		f.files[branch] = f.files["main"]
+		reply(http.StatusCreated, map[string]string{})
+
+	case route == "GET /pulls", route == "GET /merge_requests":
+		branch := strings.TrimPrefix(query.Get("head"), "org:")
+		if f.provider == "gitlab" {
+			branch = query.Get("source_branch")
+		}
+		pulls := []map[string]string{}
+		for _, pull := range f.pulls {
+			if pull == branch {
+				pulls = append(pulls, map[string]string{"html_url": "https://git/pulls/" + pull, "web_url": "https://git/mr/" + pull})
+			}
+		}
+		reply(http.StatusOK, pulls)
+
+	case route == "POST /pulls", route == "POST /merge_requests":
+		if f.failPulls {
+			reply(http.StatusInternalServerError, map[string]string{"message": "unavailable"})
+			return
+		}
+		branch := body["head"]
+		if f.provider == "gitlab" {
+			branch = body["source_branch"]
+		}
+		f.pulls = append(f.pulls, branch)
+		reply(http.StatusCreated, map[string]string{"html_url": "https://git/pulls/" + branch, "web_url": "https://git/mr/" + branch})
+
+	default:
+		f.t.Errorf("unexpected call %s %s", r.Method, r.URL)
+		reply(http.StatusNotFound, nil)
+	}
+}
+
+// prioritiesBranch is the branch Write proposes priorities on
+func prioritiesBranch(t *testing.T, f *fakeGit) string {
+	t.Helper()
+	for branch := range f.files {
+		if branch != "main" {
+			return branch
+		}
+	}
+	t.Fatal("no branch was created")
+	return ""
+}
+
+func TestGitTargetWrite(t *testing.T) {
+	const values = "configuration:\n  restoreResourcePriorities: a,b # managed\n"
+	const want = "configuration:\n  restoreResourcePriorities: \"c,d\" # managed\n"
+
+	for _, provider := range []string{"github", "gitlab"} {
+		t.Run(provider, func(t *testing.T) {
+			t.Run("proposes drift", func(t *testing.T) {
+				f, target := newFakeGit(t, provider, values)
+				got, err := target.Write(context.Background(), "c,d")
+				if err != nil {
+					t.Fatal(err)
+				}
+				branch := prioritiesBranch(t, f)
+				if got == "" || !strings.HasSuffix(got, branch) {
+					t.Errorf("got pull request %q, want one from %s", got, branch)
+				}
+				if f.files[branch] != want {
+					t.Errorf("got values\n%s\nwant\n%s", f.files[branch], want)
+				}
+				if f.files["main"] != values {
+					t.Errorf("target branch changed to\n%s", f.files["main"])
+				}
+			})
+
+			t.Run("up to date", func(t *testing.T) {
+				f, target := newFakeGit(t, provider, values)
+				got, err := target.Write(context.Background(), "a,b")
+				if err != nil || got != "" {
+					t.Fatalf("got pull request %q and error %v, want none", got, err)
+				}
+				if len(f.files) != 1 {
+					t.Errorf("got branches %v, want only main", f.files)
+				}
+			})
+
+			t.Run("already proposed", func(t *testing.T) {
+				f, target := newFakeGit(t, provider, values)
+				if _, err := target.Write(context.Background(), "c,d"); err != nil {
+					t.Fatal(err)
+				}
+				got, err := target.Write(context.Background(), "c,d")
+				if err != nil || got != "" {
+					t.Fatalf("got pull request %q and error %v, want none", got, err)
+				}
+				if len(f.commits) != 1 || len(f.pulls) != 1 {
+					t.Errorf("got commits %v and pull requests %v, want one of each", f.commits, f.pulls)
+				}
+			})
+
+			t.Run("resumes a branch without the commit", func(t *testing.T) {
+				f, target := newFakeGit(t, provider, values)
+				// an earlier run created the branch and failed to commit
+				if err := target.createBranch(context.Background(), "whoisyourdaddyandwhatdoeshedo/restore-priorities-"+prioritiesHash("c,d")); err != nil {
+					t.Fatal(err)
+				}
+				got, err := target.Write(context.Background(), "c,d")
+				if err != nil || got == "" {
+					t.Fatalf("got pull request %q and error %v, want one", got, err)
+				}
+				if branch := prioritiesBranch(t, f); f.files[branch] != want {
+					t.Errorf("got values\n%s\nwant\n%s", f.files[branch], want)
+				}
+			})
+
+			t.Run("resumes a branch without the pull request", func(t *testing.T) {
+				f, target := newFakeGit(t, provider, values)
+				f.failPulls = true
+				if _, err := target.Write(context.Background(), "c,d"); err == nil {
+					t.Fatal("opening the pull request did not fail")
+				}
+				f.failPulls = false
+				got, err := target.Write(context.Background(), "c,d")
+				if err != nil || got == "" {
+					t.Fatalf("got pull request %q and error %v, want one", got, err)
+				}
+				if len(f.commits) != 1 {
+					t.Errorf("got commits %v, want the first one only", f.commits)
+				}
+			})
+
+			t.Run("existing branch", func(t *testing.T) {
+				_, target := newFakeGit(t, provider, values)
+				if err := target.createBranch(context.Background(), "main"); !errors.Is(err, errBranchExists) {
+					t.Errorf("got error %v, want %v", err, errBranchExists)
+				}
+			})
+
+			t.Run("invalid branch", func(t *testing.T) {
+				_, target := newFakeGit(t, provider, values)
+				err := target.createBranch(context.Background(), "bad..name")
+				if err == nil || errors.Is(err, errBranchExists) {
+					t.Errorf("got error %v, want the invalid branch reported", err)
+				}
+			})
+		})
+	}
+}
+
+// prioritiesHash is the suffix of the branch Write proposes priorities on
+func prioritiesHash(priorities string) string {
+	sum := sha256.Sum256([]byte(priorities))
+	return hex.EncodeToString(sum[:4])
+}
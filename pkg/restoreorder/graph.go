@@ -0,0 +1,486 @@
+package restoreorder
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Graph is the ownership graph between custom resource kinds
+type Graph struct {
+	// Owners maps every kind to the kinds that own it
+	Owners map[schema.GroupKind]map[schema.GroupKind]any
+	// Resources maps every kind served by a CRD to the name of that CRD,
+	// and built-in kinds owned by custom resources to their resource name
+	// (required because owner references are in the form of kind and need to be mapped to CRD names)
+	// the group is part of the key as the same kind can be served by more than one group
+	// e.g. Foo.bar.com -> foos.bar.com
+	Resources map[schema.GroupKind]string
+	// Namespaced records which of the kinds in Resources are namespaced
+	Namespaced map[schema.GroupKind]bool
+	// Counts are the number of scanned resources of every kind
+	Counts map[schema.GroupKind]int
+	// Orphans are the scanned resources whose owners will not exist after a restore
+	Orphans []Orphan
+	// CrossScope are the owner references of the scanned resources crossing
+	// a scope boundary, left out of the graph unless asked for
+	CrossScope []CrossScopeOwner
+	// Remapped are the scanned kinds with resources owned by scanned
+	// resources, whose owner references a restore leaves pointing at old UIDs
+	Remapped []RemappedOwners
+	// SyncWaves maps kinds to the earliest ArgoCD sync wave of their resources,
+	// kinds at the same depth are ordered by wave
+	SyncWaves map[schema.GroupKind]int
+	// Skipped are the resources that could not be listed in a best effort scan,
+	// the order may be missing their dependencies
+	Skipped ListErrors
+	// NotEstablished are the CRDs left out of the scan as they were not
+	// established or being deleted, the order is missing their resources
+	NotEstablished []string
+	// Removed are the CRDs deleted while they were scanned, left out of the graph
+	Removed []string
+	// Webhooks are the scanned resources that depend on a webhook backend to be restored
+	Webhooks []WebhookDependency
+	// Operators are the Deployments found serving the scanned CRDs, when asked for
+	Operators []Operator
+	// FluxDependsOn are the spec.dependsOn entries of the scanned Flux
+	// objects naming an object missing from the scan, with the flux detector
+	FluxDependsOn []FluxDependency
+	// DefaultOrder is the order the computed order is added to,
+	// the package DefaultOrder when nil
+	DefaultOrder []string
+	// LowPriority are resources restored after every other resource, listed
+	// after the "-" delimiter
+	LowPriority []string
+	// UnrelatedLowPriority also makes the scanned kinds without any owners
+	// or owned kinds low priority, rather than leaving them unlisted
+	UnrelatedLowPriority bool
+	// Qualify is how the entries of scanned kinds are written, one of
+	// QualifyAlways (the default when empty), QualifyAmbiguous or QualifyNever
+	Qualify string
+	// Tiebreak is how kinds at the same depth and sync wave are ordered,
+	// one of TiebreakAlpha (the default when empty), TiebreakCount or TiebreakCountAscending
+	Tiebreak string
+	// Compact leaves out the entries of kinds that own nothing, which velero
+	// restores after every listed kind anyway, to shorten long values
+	Compact bool
+	// Minimal only lists the kinds whose position is required: on top of
+	// Compact, soft edges are not kept and owners whose owned kinds are all
+	// low priority are left out, as velero restores them before those unlisted
+	Minimal bool
+	// Soft are preferred orderings, from a kind to the kinds it should be
+	// restored after to the weight of the preference. they are followed
+	// where they do not contradict Owners, the hard edges, or heavier soft
+	// edges, so they never create a cycle
+	Soft map[schema.GroupKind]map[schema.GroupKind]int
+	// Namespaces are the graphs of every namespace, built from its resources
+	// and the cluster-scoped ones, when the scan was asked for them
+	Namespaces map[string]*Graph
+	// First and Last are kinds forced to the start or end of the order
+	First []schema.GroupKind
+	Last  []schema.GroupKind
+}
+
+// NewGraph returns an empty graph
+func NewGraph() *Graph {
+	return &Graph{
+		Owners:     map[schema.GroupKind]map[schema.GroupKind]any{},
+		Resources:  map[schema.GroupKind]string{},
+		Namespaced: map[schema.GroupKind]bool{},
+		SyncWaves:  map[schema.GroupKind]int{},
+		Counts:     map[schema.GroupKind]int{},
+		Soft:       map[schema.GroupKind]map[schema.GroupKind]int{},
+	}
+}
+
+// AddEdge records that kind is owned by owner
+func (g *Graph) AddEdge(kind, owner schema.GroupKind) {
+	if g.Owners[kind] == nil {
+		g.Owners[kind] = map[schema.GroupKind]any{}
+	}
+	g.Owners[kind][owner] = nil
+}
+
+// AddSoftEdge records that kind is preferably restored after owner, keeping
+// the heaviest weight when the preference is recorded more than once
+func (g *Graph) AddSoftEdge(kind, owner schema.GroupKind, weight int) {
+	if kind == owner {
+		return
+	}
+	if g.Soft[kind] == nil {
+		g.Soft[kind] = map[schema.GroupKind]int{}
+	}
+	if current, ok := g.Soft[kind][owner]; !ok || weight > current {
+		g.Soft[kind][owner] = weight
+	}
+}
+
+// orderingEdges returns the hard edges of g with the soft edges added that
+// do not close a cycle with the edges taken before them, heaviest first
+func (g *Graph) orderingEdges() map[schema.GroupKind]map[schema.GroupKind]any {
+	if len(g.Soft) == 0 {
+		return g.Owners
+	}
+
+	edges := map[schema.GroupKind]map[schema.GroupKind]any{}
+	for kind, owners := range g.Owners {
+		edges[kind] = maps.Clone(owners)
+	}
+	soft := []Edge{}
+	for _, kind := range sortedKinds(g.Soft) {
+		for _, owner := range sortedKinds(g.Soft[kind]) {
+			soft = append(soft, Edge{Kind: kind, Owner: owner, Soft: true, Weight: g.Soft[kind][owner]})
+		}
+	}
+	// the sort is stable so edges of the same weight keep their sorted order
+	slices.SortStableFunc(soft, func(a, b Edge) int {
+		return cmp.Compare(b.Weight, a.Weight)
+	})
+	for _, edge := range soft {
+		if reaches(edges, edge.Owner, edge.Kind) {
+			slog.Debug("dropping soft edge contradicting the graph", "kind", edge.Kind.String(), "owner", edge.Owner.String(), "weight", edge.Weight)
+			continue
+		}
+		if edges[edge.Kind] == nil {
+			edges[edge.Kind] = map[schema.GroupKind]any{}
+		}
+		edges[edge.Kind][edge.Owner] = nil
+	}
+	return edges
+}
+
+// Name returns the name kind is emitted as, the CRD name when known
+func (g *Graph) Name(kind schema.GroupKind) string {
+	if name, ok := g.Resources[kind]; ok {
+		return name
+	}
+	return kind.String()
+}
+
+// Order returns the CRD names of every kind in the graph ordered so
+// resources with no owners are at the top and resources that are owned
+// by other resources are at the bottom
+// e.g. IAMRoles are owned by Nodegroups which are in turn owned by NodegroupDeployments
+// so the order should be NodegroupDeployments -> Nodegroups -> IAMRoles
+func (g *Graph) Order() []string {
+	pinned := slices.Concat(g.First, g.Last)
+	kinds := slices.Concat(g.First, orderDependencies(g.orderingEdges(), g.tiebreak), g.Last)
+
+	final := []string{}
+	for i, depend := range kinds {
+		// kinds forced to the start or end are only emitted there
+		if i >= len(g.First) && i < len(kinds)-len(g.Last) && slices.Contains(pinned, depend) {
+			continue
+		}
+		// kinds without a CRD (e.g. owners served by the core API) are skipped
+		if name, ok := g.Resources[depend]; ok && !slices.Contains(final, name) {
+			final = append(final, name)
+		}
+	}
+	return final
+}
+
+// Priorities returns the full restore-resource-priorities value for the graph
+func (g *Graph) Priorities() string {
+	kept, added := g.entries()
+	return Priorities(kept, added)
+}
+
+// Additions returns only the part of the priorities value the graph adds
+// after its default order, for values that already hold the default order
+func (g *Graph) Additions() string {
+	_, added := g.entries()
+	return strings.Join(added, ",")
+}
+
+// entries returns the entries of the default order kept in the priorities
+// value and the entries added after them, including the low priority section
+func (g *Graph) entries() (kept, added []string) {
+	defaults := g.DefaultOrder
+	if defaults == nil {
+		defaults = DefaultOrder
+	}
+
+	low := g.lowPriority()
+	isLow := func(res string) bool {
+		return slices.Contains(low, res)
+	}
+	kept, added = g.mergeDefaults(defaults, g.Order())
+	kept = slices.DeleteFunc(kept, isLow)
+	added = slices.DeleteFunc(added, isLow)
+	if g.Compact || g.Minimal {
+		added = g.compacted(added, low)
+	}
+	if len(low) > 0 {
+		added = slices.Concat(added, []string{LowPriorityDelimiter}, low)
+	}
+	return g.qualified(kept, defaults), g.qualified(added, defaults)
+}
+
+// the forms the entries of scanned kinds are written in, velero accepts
+// both a plural and a plural.group entry
+const (
+	// QualifyAlways writes every entry as plural.group
+	QualifyAlways = "always"
+	// QualifyAmbiguous only writes the group when another entry has the same plural
+	QualifyAmbiguous = "ambiguous"
+	// QualifyNever writes the plural alone
+	QualifyNever = "never"
+)
+
+// QualifyModes are the values Graph.Qualify accepts
+var QualifyModes = []string{QualifyAlways, QualifyAmbiguous, QualifyNever}
+
+// the ways kinds at the same depth are ordered
+const (
+	// TiebreakAlpha orders them by name
+	TiebreakAlpha = "alpha"
+	// TiebreakCount orders the kinds with the most resources first, so
+	// their controllers start on the most work early in the restore
+	TiebreakCount = "count"
+	// TiebreakCountAscending orders the kinds with the fewest resources first
+	TiebreakCountAscending = "count-asc"
+)
+
+// TiebreakModes are the values Graph.Tiebreak accepts
+var TiebreakModes = []string{TiebreakAlpha, TiebreakCount, TiebreakCountAscending}
+
+// tiebreak compares two kinds at the same depth: by sync wave, then as
+// g.Tiebreak asks and then by name
+func (g *Graph) tiebreak(a, b schema.GroupKind) int {
+	byCount := 0
+	switch g.Tiebreak {
+	case TiebreakCount:
+		byCount = cmp.Compare(g.Counts[b], g.Counts[a])
+	case TiebreakCountAscending:
+		byCount = cmp.Compare(g.Counts[a], g.Counts[b])
+	}
+	return cmp.Or(cmp.Compare(g.SyncWaves[a], g.SyncWaves[b]), byCount, cmp.Compare(g.Name(a), g.Name(b)))
+}
+
+// qualified writes the entries of scanned kinds the way g.Qualify asks, a
+// plural is ambiguous when a scanned kind or an entry of defaults of
+// another group has the same plural
+func (g *Graph) qualified(entries, defaults []string) []string {
+	if g.Qualify == "" || g.Qualify == QualifyAlways {
+		return entries
+	}
+
+	scanned := maps.Values(g.Resources)
+	groups := map[string][]string{}
+	for _, name := range slices.Concat(scanned, defaults) {
+		gr := schema.ParseGroupResource(name)
+		if !slices.Contains(groups[gr.Resource], gr.Group) {
+			groups[gr.Resource] = append(groups[gr.Resource], gr.Group)
+		}
+	}
+
+	out := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		gr := schema.ParseGroupResource(entry)
+		if slices.Contains(scanned, entry) && (g.Qualify == QualifyNever || len(groups[gr.Resource]) == 1) {
+			entry = gr.Resource
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// mergeDefaults splits defaults followed by computed into the default entries
+// and the computed entries added after them, with every resource listed once.
+// a resource in both keeps its default position when all of its owners are
+// listed before it, otherwise the default position contradicts the graph and
+// the computed position is kept instead
+func (g *Graph) mergeDefaults(defaults, computed []string) (kept, added []string) {
+	kept = []string{}
+	for _, res := range ParseDefaultOrder(strings.Join(defaults, ",")) {
+		if slices.Contains(kept, res) {
+			continue
+		}
+		if kind, ok := g.Kind(res); ok && slices.Contains(computed, res) {
+			missing := ""
+			for _, owner := range sortedKinds(g.Owners[kind]) {
+				if name, ok := g.Resources[owner]; ok && !slices.Contains(kept, name) {
+					missing = name
+				}
+			}
+			if missing != "" {
+				slog.Warn("default order lists a resource before its owner, using the computed position", "resource", res, "owner", missing)
+				continue
+			}
+		}
+		kept = append(kept, res)
+	}
+
+	added = []string{}
+	for _, res := range computed {
+		if !slices.Contains(kept, res) {
+			added = append(added, res)
+		}
+	}
+	return kept, added
+}
+
+// compacted leaves the entries of scanned kinds that own no other kind out
+// of added, or with g.Minimal no other kind restored before the low
+// priority ones. velero restores the resources it is not given after every
+// listed one and before the low priority ones, which is still after their
+// owners. kinds forced to the start or end of the order keep their entries
+func (g *Graph) compacted(added, low []string) []string {
+	owners := map[schema.GroupKind]bool{}
+	for _, kind := range slices.Concat(g.First, g.Last) {
+		owners[kind] = true
+	}
+	edges := g.orderingEdges()
+	if g.Minimal {
+		edges = g.Owners
+	}
+	for kind, kindOwners := range edges {
+		if g.Minimal && slices.Contains(low, g.Name(kind)) {
+			continue
+		}
+		for owner := range kindOwners {
+			owners[owner] = true
+		}
+	}
+
+	compact := slices.DeleteFunc(slices.Clone(added), func(res string) bool {
+		kind, ok := g.Kind(res)
+		return ok && !owners[kind]
+	})
+	if dropped := len(added) - len(compact); dropped > 0 {
+		slog.Debug("leaving out kinds that own nothing", "dropped", dropped)
+	}
+	return compact
+}
+
+// lowPriority returns the low priority resources of the graph, warning
+// about those that own other resources as those are restored after them
+func (g *Graph) lowPriority() []string {
+	low := slices.Clone(g.LowPriority)
+	if g.UnrelatedLowPriority {
+		related := map[schema.GroupKind]bool{}
+		for kind, owners := range g.Owners {
+			related[kind] = true
+			for owner := range owners {
+				related[owner] = true
+			}
+		}
+		unrelated := []string{}
+		for kind, name := range g.Resources {
+			if !related[kind] && !slices.Contains(low, name) {
+				unrelated = append(unrelated, name)
+			}
+		}
+		slices.Sort(unrelated)
+		low = append(low, unrelated...)
+	}
+
+	for kind, owners := range g.Owners {
+		for owner := range owners {
+			if slices.Contains(low, g.Name(owner)) && !slices.Contains(low, g.Name(kind)) {
+				slog.Warn("low priority resource owns a resource restored before it", "resource", g.Name(owner), "owned", g.Name(kind))
+			}
+		}
+	}
+	return low
+}
+
+// WriteDOT writes the graph in the graphviz DOT format with an edge
+// from every owner to the kinds it owns
+func (g *Graph) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph owners {"); err != nil {
+		return err
+	}
+	for _, kind := range sortedKinds(g.Owners) {
+		for _, owner := range sortedKinds(g.Owners[kind]) {
+			if _, err := fmt.Fprintf(w, "\t%q -> %q;\n", g.Name(owner), g.Name(kind)); err != nil {
+				return err
+			}
+		}
+	}
+	// soft edges are dashed, they only order kinds where nothing contradicts them
+	for _, kind := range sortedKinds(g.Soft) {
+		for _, owner := range sortedKinds(g.Soft[kind]) {
+			if _, err := fmt.Fprintf(w, "\t%q -> %q [style=dashed];\n", g.Name(owner), g.Name(kind)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// orderDependencies orders kinds by their depth in the ownership graph
+// so kinds with no owners come first and every kind comes after all of its owners,
+// kinds at the same depth are ordered by tiebreak and then by their Kind.group
+// form so the same graph always gives the same order
+func orderDependencies(data map[schema.GroupKind]map[schema.GroupKind]any, tiebreak func(a, b schema.GroupKind) int) []schema.GroupKind {
+	all := map[schema.GroupKind]int{}
+
+	// get all keys, in a fixed order so ownership cycles are always broken at the same kind
+	for _, key := range sortedKinds(data) {
+		depth(data, key, all, map[schema.GroupKind]bool{})
+		for _, k := range sortedKinds(data[key]) {
+			depth(data, k, all, map[schema.GroupKind]bool{})
+		}
+	}
+
+	// flip the map
+	flipped := map[int][]schema.GroupKind{}
+	for key, value := range all {
+		if _, ok := flipped[value]; !ok {
+			flipped[value] = []schema.GroupKind{}
+		}
+		flipped[value] = append(flipped[value], key)
+	}
+
+	order := maps.Keys(flipped)
+	slices.Sort(order)
+
+	result := []schema.GroupKind{}
+	for _, idx := range order {
+		slices.SortFunc(flipped[idx], func(a, b schema.GroupKind) int {
+			return cmp.Or(tiebreak(a, b), cmp.Compare(a.String(), b.String()))
+		})
+		result = append(result, flipped[idx]...)
+	}
+
+	return result
+}
+
+// depth returns the length of the longest owner chain above kind,
+// memoizing the results in depths
+func depth(data map[schema.GroupKind]map[schema.GroupKind]any, kind schema.GroupKind, depths map[schema.GroupKind]int, visiting map[schema.GroupKind]bool) int {
+	if d, ok := depths[kind]; ok {
+		return d
+	}
+	if visiting[kind] {
+		// an ownership cycle, stop here rather than recursing forever
+		slog.Warn("ownership cycle detected", "kind", kind.String())
+		return 0
+	}
+	visiting[kind] = true
+
+	d := 0
+	for _, owner := range sortedKinds(data[kind]) {
+		d = max(d, depth(data, owner, depths, visiting)+1)
+	}
+	depths[kind] = d
+	return d
+}
+
+// sortedKinds returns the keys of m sorted by their Kind.group form
+func sortedKinds[V any](m map[schema.GroupKind]V) []schema.GroupKind {
+	kinds := maps.Keys(m)
+	slices.SortFunc(kinds, func(a, b schema.GroupKind) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	return kinds
+}
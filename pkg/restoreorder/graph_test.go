@@ -0,0 +1,161 @@
+package restoreorder_test
+
+import (
+	"context"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+	restoreordertesting "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder/testing"
+)
+
+// TestPrioritiesStable discovers the fixtures many times, served in a
+// different order every time, and checks every run computes the priorities
+// of the golden file. write it with UPDATE_GOLDEN=1
+func TestPrioritiesStable(t *testing.T) {
+	const runs = 20
+	manifests, err := restoreorder.ReadManifestDir("testdata/stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := restoreordertesting.Discover(t, "testdata/stable", restoreorder.Options{}).Priorities()
+	restoreordertesting.AssertGolden(t, "testdata/stable.golden", want)
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < runs; i++ {
+		random.Shuffle(len(manifests), func(a, b int) {
+			manifests[a], manifests[b] = manifests[b], manifests[a]
+		})
+		dynamicClient, metadataClient, err := restoreorder.ManifestClients(manifests)
+		if err != nil {
+			t.Fatal(err)
+		}
+		graph, err := restoreorder.Discover(context.Background(), dynamicClient, metadataClient, restoreorder.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := graph.Priorities(); got != want {
+			t.Fatalf("run %d computed\n%s\nwant\n%s", i, got, want)
+		}
+	}
+}
+
+// testGraph returns a graph of three ownership chains, kinds with the
+// number of resources they have:
+// Cluster (1) -> NodePool (3) -> Node (9), Account (5) -> Role (2) -> Policy (4)
+// and Volume (7) -> Snapshot (20). the default order is left empty so the
+// priorities only hold the computed entries
+func testGraph() *restoreorder.Graph {
+	g := restoreorder.NewGraph()
+	g.DefaultOrder = []string{}
+	for _, kind := range []struct {
+		name, plural string
+		count        int
+	}{
+		{"Cluster", "clusters", 1}, {"NodePool", "nodepools", 3}, {"Node", "nodes", 9},
+		{"Account", "accounts", 5}, {"Role", "roles", 2}, {"Policy", "policies", 4},
+		{"Volume", "volumes", 7}, {"Snapshot", "snapshots", 20},
+	} {
+		gk := testKind(kind.name)
+		g.Resources[gk] = kind.plural + ".example.io"
+		g.Namespaced[gk] = true
+		g.Counts[gk] = kind.count
+	}
+	g.AddEdge(testKind("NodePool"), testKind("Cluster"))
+	g.AddEdge(testKind("Node"), testKind("NodePool"))
+	g.AddEdge(testKind("Role"), testKind("Account"))
+	g.AddEdge(testKind("Policy"), testKind("Role"))
+	g.AddEdge(testKind("Snapshot"), testKind("Volume"))
+	return g
+}
+
+func testKind(kind string) schema.GroupKind {
+	return schema.GroupKind{Group: "example.io", Kind: kind}
+}
+
+// TestPrioritiesGolden checks the priorities computed from testGraph with
+// soft edges and the tiebreak, compact and minimal modes against the
+// golden files under testdata/priorities. write them with UPDATE_GOLDEN=1
+func TestPrioritiesGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph func(g *restoreorder.Graph)
+	}{
+		{name: "default", graph: func(*restoreorder.Graph) {}},
+		{
+			// volumes are restored after nodes, and snapshots still after volumes
+			name: "soft",
+			graph: func(g *restoreorder.Graph) {
+				g.AddSoftEdge(testKind("Volume"), testKind("Node"), 1)
+			},
+		},
+		{
+			// clusters after nodes contradicts the hard edges and accounts before
+			// volumes a heavier soft edge, both are dropped
+			name: "soft-conflict",
+			graph: func(g *restoreorder.Graph) {
+				g.AddSoftEdge(testKind("Cluster"), testKind("Node"), 10)
+				g.AddSoftEdge(testKind("Account"), testKind("Volume"), 3)
+				g.AddSoftEdge(testKind("Volume"), testKind("Account"), 1)
+			},
+		},
+		{
+			name: "soft-heaviest-weight",
+			graph: func(g *restoreorder.Graph) {
+				g.AddSoftEdge(testKind("Account"), testKind("Volume"), 1)
+				g.AddSoftEdge(testKind("Volume"), testKind("Account"), 2)
+				g.AddSoftEdge(testKind("Account"), testKind("Volume"), 3)
+			},
+		},
+		{
+			name:  "tiebreak-count",
+			graph: func(g *restoreorder.Graph) { g.Tiebreak = restoreorder.TiebreakCount },
+		},
+		{
+			name:  "tiebreak-count-asc",
+			graph: func(g *restoreorder.Graph) { g.Tiebreak = restoreorder.TiebreakCountAscending },
+		},
+		{
+			// sync waves come before the counts
+			name: "tiebreak-count-sync-waves",
+			graph: func(g *restoreorder.Graph) {
+				g.Tiebreak = restoreorder.TiebreakCount
+				g.SyncWaves[testKind("Cluster")] = -1
+			},
+		},
+		{
+			// nodes, policies and snapshots own nothing
+			name:  "compact",
+			graph: func(g *restoreorder.Graph) { g.Compact = true },
+		},
+		{
+			// nodes own volumes through the soft edge and are kept
+			name: "compact-soft",
+			graph: func(g *restoreorder.Graph) {
+				g.Compact = true
+				g.AddSoftEdge(testKind("Volume"), testKind("Node"), 1)
+			},
+		},
+		{
+			// the soft edge does not keep nodes and roles only own low
+			// priority policies, both are left out
+			name: "minimal",
+			graph: func(g *restoreorder.Graph) {
+				g.Minimal = true
+				g.LowPriority = []string{"policies.example.io"}
+				g.AddSoftEdge(testKind("Volume"), testKind("Node"), 1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testGraph()
+			tt.graph(g)
+			restoreordertesting.AssertGolden(t, filepath.Join("testdata", "priorities", tt.name+".golden"), g.Priorities())
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package restoreorder
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Hints are extra edges and forced positions merged into a discovered graph,
+// for relationships that cannot be discovered or annotated
+//
+//	edges:
+//	- from: iamroles.iam.example.com
+//	  to: nodegroups.eks.example.com
+//	- from: dashboards.grafana.example.com
+//	  to: datasources.grafana.example.com
+//	  soft: true
+//	  weight: 10
+//	first:
+//	- nodegroupdeployments.eks.example.com
+//	last:
+//	- iamroles.iam.example.com
+type Hints struct {
+	// Edges order the resource From after the resource To, both CRD names
+	Edges []HintEdge `json:"edges,omitempty"`
+	// First are resources put at the start of the computed order, in this order
+	First []string `json:"first,omitempty"`
+	// Last are resources put at the end of the computed order, in this order
+	Last []string `json:"last,omitempty"`
+}
+
+// HintEdge records that From is restored after To
+type HintEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Soft only prefers the order, see Graph.Soft
+	Soft bool `json:"soft,omitempty"`
+	// Weight ranks soft edges, heavier ones win conflicts between them
+	Weight int `json:"weight,omitempty"`
+}
+
+// LoadHints reads hints from a YAML or JSON file
+func LoadHints(path string) (*Hints, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read hints: %w", err)
+	}
+	hints := &Hints{}
+	if err := yaml.UnmarshalStrict(data, hints); err != nil {
+		return nil, fmt.Errorf("cannot parse hints %s: %w", path, err)
+	}
+	return hints, nil
+}
+
+// ApplyHints merges hints into the graph, hints naming resources that
+// are not in the graph are logged and skipped
+func (g *Graph) ApplyHints(hints *Hints) {
+	for _, edge := range hints.Edges {
+		from, ok := g.hintKind(edge.From)
+		if !ok {
+			continue
+		}
+		to, ok := g.hintKind(edge.To)
+		if !ok {
+			continue
+		}
+		if edge.Soft {
+			g.AddSoftEdge(from, to, edge.Weight)
+			continue
+		}
+		g.AddEdge(from, to)
+	}
+	for _, name := range hints.First {
+		if kind, ok := g.hintKind(name); ok {
+			g.First = append(g.First, kind)
+		}
+	}
+	for _, name := range hints.Last {
+		if kind, ok := g.hintKind(name); ok {
+			g.Last = append(g.Last, kind)
+		}
+	}
+}
+
+// hintKind returns the kind of the resource named in a hint
+func (g *Graph) hintKind(name string) (schema.GroupKind, bool) {
+	kind, ok := g.Kind(name)
+	if !ok {
+		slog.Warn("skipping hint for a resource that is not in the graph", "resource", name)
+	}
+	return kind, ok
+}
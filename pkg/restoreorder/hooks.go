@@ -0,0 +1,87 @@
+package restoreorder
+
+import (
+	"slices"
+	"strings"
+)
+
+// RestoreHooksTimeout is how long the generated restore hooks wait for the
+// webhook backends to be ready
+const RestoreHooksTimeout = "10m"
+
+// RestoreHooks are the hooks of a Velero Restore spec
+type RestoreHooks struct {
+	Resources []RestoreResourceHookSpec `json:"resources"`
+}
+
+// RestoreResourceHookSpec runs hooks in the restored pods it selects
+type RestoreResourceHookSpec struct {
+	Name               string                `json:"name"`
+	IncludedNamespaces []string              `json:"includedNamespaces"`
+	IncludedResources  []string              `json:"includedResources"`
+	PostHooks          []RestoreResourceHook `json:"postHooks"`
+}
+
+// RestoreResourceHook is a hook run in a restored pod
+type RestoreResourceHook struct {
+	Exec *RestoreExecHook `json:"exec"`
+}
+
+// RestoreExecHook runs a command in a container of a restored pod once it is ready
+type RestoreExecHook struct {
+	Command      []string `json:"command"`
+	WaitTimeout  string   `json:"waitTimeout"`
+	WaitForReady bool     `json:"waitForReady"`
+	OnError      string   `json:"onError"`
+}
+
+// RestoreHooks returns restore hooks waiting for the pods in the namespace of
+// every service backing a webhook the scanned resources need to be ready.
+// velero only runs hooks in pods, so the hooks cannot hold back the restore
+// of the resources, they make the restore wait for the webhook backends
+// restored with the earlier pods before it completes. webhooks served from
+// a URL rather than a service are left out
+func (g *Graph) RestoreHooks() RestoreHooks {
+	hooks := RestoreHooks{Resources: []RestoreResourceHookSpec{}}
+	for _, service := range g.WebhookServices() {
+		namespace, name, _ := strings.Cut(service, "/")
+		hooks.Resources = append(hooks.Resources, RestoreResourceHookSpec{
+			Name:               "wait-for-" + namespace + "-" + name,
+			IncludedNamespaces: []string{namespace},
+			IncludedResources:  []string{"pods"},
+			PostHooks: []RestoreResourceHook{{Exec: &RestoreExecHook{
+				Command:      []string{"true"},
+				WaitTimeout:  RestoreHooksTimeout,
+				WaitForReady: true,
+				OnError:      "Continue",
+			}}},
+		})
+	}
+	return hooks
+}
+
+// WebhookServices returns the namespace/name of the services backing the
+// webhooks the scanned resources need, sorted
+func (g *Graph) WebhookServices() []string {
+	services := []string{}
+	for _, dep := range g.Webhooks {
+		if dep.Service != "" && !slices.Contains(services, dep.Service) {
+			services = append(services, dep.Service)
+		}
+	}
+	slices.Sort(services)
+	return services
+}
+
+// WebhookDependents returns the resources depending on the webhook served by
+// service, sorted
+func (g *Graph) WebhookDependents(service string) []string {
+	dependents := []string{}
+	for _, dep := range g.Webhooks {
+		if dep.Service == service && !slices.Contains(dependents, dep.Resource) {
+			dependents = append(dependents, dep.Resource)
+		}
+	}
+	slices.Sort(dependents)
+	return dependents
+}
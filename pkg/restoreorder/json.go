@@ -0,0 +1,261 @@
+package restoreorder
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// graphJSON is the serialized form of a Graph
+type graphJSON struct {
+	Resources []resourceJSON `json:"resources"`
+	Edges     []edgeJSON     `json:"edges"`
+	// First and Last are the kinds forced to the start or end, in Kind.group form
+	First                []string `json:"first,omitempty"`
+	Last                 []string `json:"last,omitempty"`
+	LowPriority          []string `json:"lowPriority,omitempty"`
+	UnrelatedLowPriority bool     `json:"unrelatedLowPriority,omitempty"`
+	Qualify              string   `json:"qualify,omitempty"`
+	Tiebreak             string   `json:"tiebreak,omitempty"`
+	Compact              bool     `json:"compact,omitempty"`
+	Minimal              bool     `json:"minimal,omitempty"`
+	// DefaultOrder is null when the package default is used
+	DefaultOrder []string `json:"defaultOrder"`
+	// the problems of the scan, kept so an imported graph reports them too
+	Orphans        []orphanJSON         `json:"orphans,omitempty"`
+	CrossScope     []crossScopeJSON     `json:"crossScope,omitempty"`
+	Skipped        []listErrorJSON      `json:"skipped,omitempty"`
+	NotEstablished []string             `json:"notEstablished,omitempty"`
+	Removed        []string             `json:"removed,omitempty"`
+	Webhooks       []webhookJSON        `json:"webhooks,omitempty"`
+	FluxDependsOn  []fluxDependencyJSON `json:"fluxDependsOn,omitempty"`
+}
+
+type resourceJSON struct {
+	Group      string `json:"group"`
+	Kind       string `json:"kind"`
+	Resource   string `json:"resource"`
+	Namespaced bool   `json:"namespaced"`
+	SyncWave   *int   `json:"syncWave,omitempty"`
+	Count      int    `json:"count,omitempty"`
+}
+
+// edgeJSON records that Kind is owned by Owner, both in Kind.group form
+type edgeJSON struct {
+	Kind   string `json:"kind"`
+	Owner  string `json:"owner"`
+	Soft   bool   `json:"soft,omitempty"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// orphanJSON is an Orphan, its kind in Kind.group form
+type orphanJSON struct {
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name"`
+	Owner     v1.OwnerReference `json:"owner"`
+	Reason    string            `json:"reason"`
+}
+
+// crossScopeJSON is a CrossScopeOwner, its kind in Kind.group form
+type crossScopeJSON struct {
+	Kind           string            `json:"kind"`
+	Namespace      string            `json:"namespace,omitempty"`
+	Name           string            `json:"name"`
+	UID            types.UID         `json:"uid,omitempty"`
+	Owner          v1.OwnerReference `json:"owner"`
+	OwnerNamespace string            `json:"ownerNamespace,omitempty"`
+}
+
+// listErrorJSON is a ListError, the reason and code of an API error are
+// kept so it is still told apart, e.g. as forbidden, once decoded
+type listErrorJSON struct {
+	Resource string          `json:"resource"`
+	Error    string          `json:"error"`
+	Reason   v1.StatusReason `json:"reason,omitempty"`
+	Code     int32           `json:"code,omitempty"`
+}
+
+type webhookJSON struct {
+	Resource string `json:"resource"`
+	Webhook  string `json:"webhook,omitempty"`
+	Service  string `json:"service,omitempty"`
+}
+
+// fluxDependencyJSON is a FluxDependency, its kind in Kind.group form
+type fluxDependencyJSON struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	DependsOn string `json:"dependsOn"`
+}
+
+// MarshalJSON encodes the graph as its resources and ownership edges,
+// sorted so the same graph always encodes the same way, and the problems
+// found scanning it
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	out := graphJSON{
+		Resources: []resourceJSON{},
+		Edges:     []edgeJSON{},
+	}
+
+	for kind, name := range g.Resources {
+		res := resourceJSON{
+			Group:      kind.Group,
+			Kind:       kind.Kind,
+			Resource:   name,
+			Namespaced: g.Namespaced[kind],
+			Count:      g.Counts[kind],
+		}
+		if wave, ok := g.SyncWaves[kind]; ok {
+			res.SyncWave = &wave
+		}
+		out.Resources = append(out.Resources, res)
+	}
+	slices.SortFunc(out.Resources, func(a, b resourceJSON) int {
+		return strings.Compare(a.Resource, b.Resource)
+	})
+
+	for kind, owners := range g.Owners {
+		for owner := range owners {
+			out.Edges = append(out.Edges, edgeJSON{Kind: kind.String(), Owner: owner.String()})
+		}
+	}
+	for kind, owners := range g.Soft {
+		for owner, weight := range owners {
+			out.Edges = append(out.Edges, edgeJSON{Kind: kind.String(), Owner: owner.String(), Soft: true, Weight: weight})
+		}
+	}
+	slices.SortFunc(out.Edges, func(a, b edgeJSON) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Owner, b.Owner); c != 0 {
+			return c
+		}
+		// a hard edge comes before a soft edge between the same kinds
+		if a.Soft != b.Soft {
+			if b.Soft {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	})
+
+	for _, kind := range g.First {
+		out.First = append(out.First, kind.String())
+	}
+	for _, kind := range g.Last {
+		out.Last = append(out.Last, kind.String())
+	}
+
+	out.DefaultOrder = g.DefaultOrder
+	out.LowPriority = g.LowPriority
+	out.UnrelatedLowPriority = g.UnrelatedLowPriority
+	out.Qualify = g.Qualify
+	out.Tiebreak = g.Tiebreak
+	out.Compact = g.Compact
+	out.Minimal = g.Minimal
+
+	for _, o := range g.Orphans {
+		out.Orphans = append(out.Orphans, orphanJSON{Kind: o.Kind.String(), Namespace: o.Namespace, Name: o.Name, Owner: o.Owner, Reason: o.Reason})
+	}
+	for _, c := range g.CrossScope {
+		out.CrossScope = append(out.CrossScope, crossScopeJSON{Kind: c.Kind.String(), Namespace: c.Namespace, Name: c.Name, UID: c.UID, Owner: c.Owner, OwnerNamespace: c.OwnerNamespace})
+	}
+	for _, err := range g.Skipped {
+		skipped := listErrorJSON{Resource: err.Resource, Error: err.Err.Error()}
+		var status apierrors.APIStatus
+		if errors.As(err.Err, &status) {
+			skipped.Reason = status.Status().Reason
+			skipped.Code = status.Status().Code
+		}
+		out.Skipped = append(out.Skipped, skipped)
+	}
+	out.NotEstablished = g.NotEstablished
+	out.Removed = g.Removed
+	for _, dep := range g.Webhooks {
+		out.Webhooks = append(out.Webhooks, webhookJSON{Resource: dep.Resource, Webhook: dep.Webhook, Service: dep.Service})
+	}
+	for _, dep := range g.FluxDependsOn {
+		out.FluxDependsOn = append(out.FluxDependsOn, fluxDependencyJSON{Kind: dep.Kind.String(), Namespace: dep.Namespace, Name: dep.Name, DependsOn: dep.DependsOn})
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a graph encoded by MarshalJSON
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	in := graphJSON{}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*g = *NewGraph()
+	for _, res := range in.Resources {
+		kind := schema.GroupKind{Group: res.Group, Kind: res.Kind}
+		g.Resources[kind] = res.Resource
+		g.Namespaced[kind] = res.Namespaced
+		if res.Count > 0 {
+			g.Counts[kind] = res.Count
+		}
+		if res.SyncWave != nil {
+			g.SyncWaves[kind] = *res.SyncWave
+		}
+	}
+	for _, edge := range in.Edges {
+		if edge.Soft {
+			g.AddSoftEdge(schema.ParseGroupKind(edge.Kind), schema.ParseGroupKind(edge.Owner), edge.Weight)
+			continue
+		}
+		g.AddEdge(schema.ParseGroupKind(edge.Kind), schema.ParseGroupKind(edge.Owner))
+	}
+	g.DefaultOrder = in.DefaultOrder
+	g.LowPriority = in.LowPriority
+	g.UnrelatedLowPriority = in.UnrelatedLowPriority
+	g.Qualify = in.Qualify
+	g.Tiebreak = in.Tiebreak
+	g.Compact = in.Compact
+	g.Minimal = in.Minimal
+	for _, kind := range in.First {
+		g.First = append(g.First, schema.ParseGroupKind(kind))
+	}
+	for _, kind := range in.Last {
+		g.Last = append(g.Last, schema.ParseGroupKind(kind))
+	}
+
+	for _, o := range in.Orphans {
+		g.Orphans = append(g.Orphans, Orphan{Kind: schema.ParseGroupKind(o.Kind), Namespace: o.Namespace, Name: o.Name, Owner: o.Owner, Reason: o.Reason})
+	}
+	for _, c := range in.CrossScope {
+		g.CrossScope = append(g.CrossScope, CrossScopeOwner{Kind: schema.ParseGroupKind(c.Kind), Namespace: c.Namespace, Name: c.Name, UID: c.UID, Owner: c.Owner, OwnerNamespace: c.OwnerNamespace})
+	}
+	for _, skipped := range in.Skipped {
+		var err error = errors.New(skipped.Error)
+		if skipped.Reason != "" || skipped.Code != 0 {
+			err = &apierrors.StatusError{ErrStatus: v1.Status{
+				Status:  v1.StatusFailure,
+				Message: skipped.Error,
+				Reason:  skipped.Reason,
+				Code:    skipped.Code,
+			}}
+		}
+		g.Skipped = append(g.Skipped, ListError{Resource: skipped.Resource, Err: err})
+	}
+	g.NotEstablished = in.NotEstablished
+	g.Removed = in.Removed
+	for _, dep := range in.Webhooks {
+		g.Webhooks = append(g.Webhooks, WebhookDependency{Resource: dep.Resource, Webhook: dep.Webhook, Service: dep.Service})
+	}
+	for _, dep := range in.FluxDependsOn {
+		g.FluxDependsOn = append(g.FluxDependsOn, FluxDependency{Kind: schema.ParseGroupKind(dep.Kind), Namespace: dep.Namespace, Name: dep.Name, DependsOn: dep.DependsOn})
+	}
+	return nil
+}
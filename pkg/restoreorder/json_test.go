@@ -0,0 +1,88 @@
+package restoreorder
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TestGraphJSONProblems checks the problems of a scan survive encoding the
+// graph, so an imported graph reports and exits the way the scan did
+func TestGraphJSONProblems(t *testing.T) {
+	app := schema.GroupKind{Group: "x.io", Kind: "App"}
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+	databases := schema.GroupResource{Group: "x.io", Resource: "databases"}
+
+	graph := NewGraph()
+	graph.Resources[app] = "apps.x.io"
+	graph.Resources[database] = "databases.x.io"
+	graph.Namespaced[app] = true
+	graph.Namespaced[database] = true
+	graph.AddEdge(database, app)
+	graph.Orphans = []Orphan{{Kind: database, Namespace: "default", Name: "d", Owner: testOwner(app, "gone", true), Reason: OrphanOwnerNotFound}}
+	graph.CrossScope = []CrossScopeOwner{{Kind: database, Name: "c", UID: "c", Owner: testOwner(app, "a", true), OwnerNamespace: "default"}}
+	graph.Skipped = ListErrors{
+		{Resource: "databases.x.io", Err: apierrors.NewForbidden(databases, "", errors.New("denied"))},
+		{Resource: "caches.x.io", Err: errors.New("connection refused")},
+	}
+	graph.NotEstablished = []string{"pending.x.io"}
+	graph.Removed = []string{"deleted.x.io"}
+	graph.Webhooks = []WebhookDependency{{Resource: "apps.x.io", Webhook: "apps", Service: "x-system/webhook"}}
+	graph.FluxDependsOn = []FluxDependency{{Kind: app, Namespace: "default", Name: "a", DependsOn: "default/gone"}}
+
+	data, err := json.Marshal(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewGraph()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.Orphans, graph.Orphans) {
+		t.Errorf("got orphans %v, want %v", decoded.Orphans, graph.Orphans)
+	}
+	if !reflect.DeepEqual(decoded.CrossScope, graph.CrossScope) {
+		t.Errorf("got cross scope owners %v, want %v", decoded.CrossScope, graph.CrossScope)
+	}
+	if !reflect.DeepEqual(decoded.NotEstablished, graph.NotEstablished) {
+		t.Errorf("got not established CRDs %v, want %v", decoded.NotEstablished, graph.NotEstablished)
+	}
+	if !reflect.DeepEqual(decoded.Removed, graph.Removed) {
+		t.Errorf("got removed CRDs %v, want %v", decoded.Removed, graph.Removed)
+	}
+	if !reflect.DeepEqual(decoded.Webhooks, graph.Webhooks) {
+		t.Errorf("got webhooks %v, want %v", decoded.Webhooks, graph.Webhooks)
+	}
+	if !reflect.DeepEqual(decoded.FluxDependsOn, graph.FluxDependsOn) {
+		t.Errorf("got flux dependencies %v, want %v", decoded.FluxDependsOn, graph.FluxDependsOn)
+	}
+	if got, want := decoded.Skipped.Error(), graph.Skipped.Error(); got != want {
+		t.Errorf("got skipped %q, want %q", got, want)
+	}
+	if !apierrors.IsForbidden(decoded.Skipped[0].Err) {
+		t.Errorf("decoded %v is not forbidden", decoded.Skipped[0].Err)
+	}
+	if got, want := decoded.Report(), graph.Report(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got report %+v, want %+v", got, want)
+	}
+}
+
+// TestGraphJSONNoProblems checks a graph without problems decodes without any
+func TestGraphJSONNoProblems(t *testing.T) {
+	data, err := json.Marshal(NewGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewGraph()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if report := decoded.Report(); len(report.Entries) > 0 {
+		t.Errorf("got report entries %v, want none", report.Entries)
+	}
+}
@@ -0,0 +1,102 @@
+package restoreorder
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// the reasons Velero cannot resolve a priorities entry
+const (
+	// LintMissingGroup is an entry of an API group the cluster does not serve
+	LintMissingGroup = "missing-group"
+	// LintUnknown is an entry no served resource is named after
+	LintUnknown = "unknown"
+	// LintAmbiguous is a name without a group served by several groups
+	LintAmbiguous = "ambiguous"
+)
+
+// LintIssue is a priorities entry Velero cannot resolve to a single resource
+// at restore time, which it silently ignores
+type LintIssue struct {
+	Entry string
+	// Position is the index of the entry in the priorities value
+	Position int
+	Reason   string
+	// Candidates are the resources an ambiguous entry could mean, in resource.group form
+	Candidates []string
+}
+
+// String describes the issue
+func (i LintIssue) String() string {
+	switch i.Reason {
+	case LintMissingGroup:
+		return fmt.Sprintf("%s (position %d): group %s is not served", i.Entry, i.Position+1, schema.ParseGroupResource(i.Entry).Group)
+	case LintAmbiguous:
+		return fmt.Sprintf("%s (position %d): served by several groups, qualify it as one of %s", i.Entry, i.Position+1, strings.Join(i.Candidates, ", "))
+	}
+	return fmt.Sprintf("%s (position %d): no such resource is served", i.Entry, i.Position+1)
+}
+
+// Lint checks every entry of priorities against the resources the cluster
+// serves, as returned by discovery, and returns those Velero cannot resolve.
+// entries name resources by plural or singular, with or without a group
+func Lint(priorities []string, served []*v1.APIResourceList) []LintIssue {
+	// the groups serving every resource by plural and singular name, "" for the core group
+	groups := map[string][]string{}
+	servedGroups := map[string]bool{}
+	for _, list := range served {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		servedGroups[gv.Group] = true
+		for _, res := range list.APIResources {
+			// subresources cannot be restored on their own
+			if strings.Contains(res.Name, "/") {
+				continue
+			}
+			// velero resolves singulars as well as plurals, see entryIndex
+			singular := res.SingularName
+			if singular == "" {
+				singular = strings.ToLower(res.Kind)
+			}
+			for _, name := range []string{res.Name, singular} {
+				if name != "" && !slices.Contains(groups[name], gv.Group) {
+					groups[name] = append(groups[name], gv.Group)
+				}
+			}
+		}
+	}
+
+	issues := []LintIssue{}
+	for pos, entry := range priorities {
+		if entry == LowPriorityDelimiter {
+			continue
+		}
+		gr := schema.ParseGroupResource(entry)
+		issue := LintIssue{Entry: entry, Position: pos}
+		switch {
+		case gr.Group != "" && !servedGroups[gr.Group]:
+			issue.Reason = LintMissingGroup
+		case gr.Group != "" && !slices.Contains(groups[gr.Resource], gr.Group):
+			issue.Reason = LintUnknown
+		case len(groups[gr.Resource]) == 0:
+			issue.Reason = LintUnknown
+		case gr.Group == "" && len(groups[gr.Resource]) > 1 && !slices.Contains(groups[gr.Resource], ""):
+			// a core resource of the same name is the one Velero picks
+			issue.Reason = LintAmbiguous
+			for _, group := range groups[gr.Resource] {
+				issue.Candidates = append(issue.Candidates, schema.GroupResource{Group: group, Resource: gr.Resource}.String())
+			}
+			slices.Sort(issue.Candidates)
+		default:
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
@@ -0,0 +1,100 @@
+package restoreorder
+
+import (
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// servedResources returns the resources of the default order, and those of
+// graph when set, as discovery serves them
+func servedResources(graph *Graph) []*v1.APIResourceList {
+	lists := map[string]*v1.APIResourceList{}
+	serve := func(groupVersion, name, singular, kind string) {
+		if lists[groupVersion] == nil {
+			lists[groupVersion] = &v1.APIResourceList{GroupVersion: groupVersion}
+		}
+		list := lists[groupVersion]
+		list.APIResources = append(list.APIResources, v1.APIResource{Name: name, SingularName: singular, Kind: kind})
+	}
+	serve("v1", "namespaces", "namespace", "Namespace")
+	serve("v1", "persistentvolumes", "persistentvolume", "PersistentVolume")
+	serve("v1", "persistentvolumeclaims", "persistentvolumeclaim", "PersistentVolumeClaim")
+	serve("v1", "secrets", "secret", "Secret")
+	serve("v1", "configmaps", "configmap", "ConfigMap")
+	serve("v1", "serviceaccounts", "serviceaccount", "ServiceAccount")
+	serve("v1", "limitranges", "limitrange", "LimitRange")
+	serve("v1", "pods", "pod", "Pod")
+	serve("v1", "pods/log", "", "Pod")
+	serve("apiextensions.k8s.io/v1", "customresourcedefinitions", "customresourcedefinition", "CustomResourceDefinition")
+	serve("storage.k8s.io/v1", "storageclasses", "storageclass", "StorageClass")
+	serve("snapshot.storage.k8s.io/v1", "volumesnapshotclasses", "volumesnapshotclass", "VolumeSnapshotClass")
+	serve("snapshot.storage.k8s.io/v1", "volumesnapshotcontents", "volumesnapshotcontent", "VolumeSnapshotContent")
+	serve("snapshot.storage.k8s.io/v1", "volumesnapshots", "volumesnapshot", "VolumeSnapshot")
+	serve("apps/v1", "replicasets", "replicaset", "ReplicaSet")
+	serve("cluster.x-k8s.io/v1beta1", "clusters", "cluster", "Cluster")
+	serve("addons.cluster.x-k8s.io/v1beta1", "clusterresourcesets", "clusterresourceset", "ClusterResourceSet")
+	if graph != nil {
+		for kind, resource := range graph.Resources {
+			gr := schema.ParseGroupResource(resource)
+			// CRDs leave the singular name empty for discovery to default
+			serve(kind.Group+"/v1", gr.Resource, "", kind.Kind)
+		}
+	}
+
+	served := []*v1.APIResourceList{}
+	for _, list := range lists {
+		served = append(served, list)
+	}
+	return served
+}
+
+func TestLint(t *testing.T) {
+	served := servedResources(nil)
+	served = append(served, &v1.APIResourceList{
+		GroupVersion: "other.io/v1",
+		APIResources: []v1.APIResource{{Name: "clusters", SingularName: "cluster", Kind: "Cluster"}},
+	})
+
+	tests := []struct {
+		name       string
+		priorities string
+		want       []string
+	}{
+		{name: "plural", priorities: "pods,replicasets"},
+		{name: "singular", priorities: "pod,replicaset.apps,volumesnapshotclass.snapshot.storage.k8s.io"},
+		{name: "core resource of the same name", priorities: "secrets"},
+		{name: "subresource", priorities: "pods/log", want: []string{LintUnknown}},
+		{name: "missing group", priorities: "widgets.x.io", want: []string{LintMissingGroup}},
+		{name: "unknown", priorities: "widgets,replicaset.storage.k8s.io", want: []string{LintUnknown, LintUnknown}},
+		{name: "ambiguous plural", priorities: "clusters", want: []string{LintAmbiguous}},
+		{name: "ambiguous singular", priorities: "cluster,-,cluster.other.io", want: []string{LintAmbiguous}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := Lint(ParsePriorities(tt.priorities), served)
+			got := []string{}
+			for _, issue := range issues {
+				got = append(got, issue.Reason)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Lint(%q) = %v, want %v", tt.priorities, issues, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Lint(%q) = %v, want %v", tt.priorities, issues, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestLintPriorities checks the computed priorities, with the default
+// order, only hold entries velero resolves
+func TestLintPriorities(t *testing.T) {
+	graph := discoverManifests(t, chainManifests(3), Options{})
+	if issues := Lint(ParsePriorities(graph.Priorities()), servedResources(graph)); len(issues) > 0 {
+		t.Errorf("got issues %v linting %s", issues, graph.Priorities())
+	}
+}
@@ -0,0 +1,147 @@
+package restoreorder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/metadata"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+// manifestExtensions are the extensions of the files ReadManifestDir reads
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// ReadManifests decodes every object in the YAML or JSON documents of r,
+// the items of v1 Lists are returned as objects of their own
+func ReadManifests(r io.Reader) ([]unstructured.Unstructured, error) {
+	objects := []unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		obj := unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		// empty documents e.g. between two ---
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if !obj.IsList() {
+			objects = append(objects, obj)
+			continue
+		}
+		list, err := obj.ToList()
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, list.Items...)
+	}
+}
+
+// ReadManifestDir reads the objects of every YAML and JSON file under dir
+func ReadManifestDir(dir string) ([]unstructured.Unstructured, error) {
+	objects := []unstructured.Unstructured{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !slices.Contains(manifestExtensions, strings.ToLower(filepath.Ext(path))) {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		found, err := ReadManifests(f)
+		if err != nil {
+			return fmt.Errorf("cannot parse %s: %w", path, err)
+		}
+		objects = append(objects, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read manifests: %w", err)
+	}
+	return objects, nil
+}
+
+// ManifestClients returns in memory clients serving the CRDs, webhook
+// configurations and objects in manifests, so a graph can be discovered
+// from rendered manifests before anything is deployed.
+// objects are served by the CRDs among the manifests or, for built-in
+// kinds, by BuiltinChildResources, objects of other kinds are left out.
+// objects without a UID are given one so detectors can read them in full
+func ManifestClients(manifests []unstructured.Unstructured) (dynamic.Interface, metadata.Interface, error) {
+	listKinds := map[schema.GroupVersionResource]string{
+		CRDResource:               "CustomResourceDefinitionList",
+		ValidatingWebhookResource: "ValidatingWebhookConfigurationList",
+		MutatingWebhookResource:   "MutatingWebhookConfigurationList",
+	}
+	resources := map[schema.GroupKind]schema.GroupVersionResource{
+		{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:               CRDResource,
+		{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: ValidatingWebhookResource,
+		{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   MutatingWebhookResource,
+	}
+	for _, res := range BuiltinChildResources {
+		listKinds[res.GVR] = res.Kind + "List"
+		resources[res.GroupKind()] = res.GVR
+	}
+	for _, obj := range manifests {
+		if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) {
+			continue
+		}
+		res, _, err := GetRes(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read CRD %s: %w", obj.GetName(), err)
+		}
+		listKinds[res.GVR] = res.Kind + "List"
+		resources[res.GroupKind()] = res.GVR
+	}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	scheme := metadatafake.NewTestScheme()
+	if err := v1.AddMetaToScheme(scheme); err != nil {
+		return nil, nil, err
+	}
+	metadataClient := metadatafake.NewSimpleMetadataClient(scheme)
+
+	for i, obj := range manifests {
+		gvr, ok := resources[obj.GroupVersionKind().GroupKind()]
+		if !ok {
+			continue
+		}
+		obj = *obj.DeepCopy()
+		if obj.GetUID() == "" {
+			obj.SetUID(types.UID(fmt.Sprintf("manifest-%d", i)))
+		}
+		if err := dynamicClient.Tracker().Create(gvr, &obj, obj.GetNamespace()); err != nil {
+			return nil, nil, fmt.Errorf("cannot load %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		meta := &v1.PartialObjectMetadata{
+			TypeMeta: v1.TypeMeta{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind()},
+		}
+		fields, _, _ := unstructured.NestedMap(obj.Object, "metadata")
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, &meta.ObjectMeta); err != nil {
+			return nil, nil, fmt.Errorf("cannot load %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		if err := metadataClient.Tracker().Create(gvr, meta, obj.GetNamespace()); err != nil {
+			return nil, nil, fmt.Errorf("cannot load %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	return dynamicClient, metadataClient, nil
+}
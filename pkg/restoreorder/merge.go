@@ -0,0 +1,168 @@
+package restoreorder
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"golang.org/x/exp/maps"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// MergeGraphs merges the graphs of several sources, e.g. clusters, keyed by
+// a name for the source, into one graph whose order is valid for every one of them.
+// it fails when two sources order two kinds the opposite way round, as no
+// single priorities value can restore both correctly. the options shared by
+// the graphs (default order, low priority, tiebreak and pinned kinds) are taken from
+// the first source by name
+func MergeGraphs(graphs map[string]*Graph) (*Graph, error) {
+	sources := maps.Keys(graphs)
+	slices.Sort(sources)
+	if len(sources) == 0 {
+		return NewGraph(), nil
+	}
+
+	merged := NewGraph()
+	first := graphs[sources[0]]
+	merged.DefaultOrder = first.DefaultOrder
+	merged.LowPriority = first.LowPriority
+	merged.UnrelatedLowPriority = first.UnrelatedLowPriority
+	merged.Qualify = first.Qualify
+	merged.Tiebreak = first.Tiebreak
+	merged.Compact = first.Compact
+	merged.Minimal = first.Minimal
+	merged.First = first.First
+	merged.Last = first.Last
+
+	for _, source := range sources {
+		g := graphs[source]
+		for kind, owners := range g.Owners {
+			for owner := range owners {
+				merged.AddEdge(kind, owner)
+			}
+		}
+		for kind, owners := range g.Soft {
+			for owner, weight := range owners {
+				merged.AddSoftEdge(kind, owner, weight)
+			}
+		}
+		maps.Copy(merged.Resources, g.Resources)
+		maps.Copy(merged.Namespaced, g.Namespaced)
+		for kind, count := range g.Counts {
+			merged.Counts[kind] += count
+		}
+		for kind, wave := range g.SyncWaves {
+			if current, ok := merged.SyncWaves[kind]; !ok || wave < current {
+				merged.SyncWaves[kind] = wave
+			}
+		}
+		merged.Orphans = append(merged.Orphans, g.Orphans...)
+		merged.CrossScope = append(merged.CrossScope, g.CrossScope...)
+		merged.FluxDependsOn = append(merged.FluxDependsOn, g.FluxDependsOn...)
+		merged.Remapped = mergeRemappedOwners(slices.Concat(merged.Remapped, g.Remapped))
+		merged.Skipped = append(merged.Skipped, g.Skipped...)
+		for _, name := range g.NotEstablished {
+			if !slices.Contains(merged.NotEstablished, name) {
+				merged.NotEstablished = append(merged.NotEstablished, name)
+			}
+		}
+		for _, name := range g.Removed {
+			if !slices.Contains(merged.Removed, name) {
+				merged.Removed = append(merged.Removed, name)
+			}
+		}
+		for _, dep := range g.Webhooks {
+			if !slices.Contains(merged.Webhooks, dep) {
+				merged.Webhooks = append(merged.Webhooks, dep)
+			}
+		}
+		for _, op := range g.Operators {
+			if !slices.Contains(merged.Operators, op) {
+				merged.Operators = append(merged.Operators, op)
+			}
+		}
+	}
+	merged.Skipped = merged.Skipped.sorted()
+	slices.Sort(merged.NotEstablished)
+	slices.Sort(merged.Removed)
+	slices.SortFunc(merged.Webhooks, func(a, b WebhookDependency) int {
+		return cmp.Or(cmp.Compare(a.Resource, b.Resource), cmp.Compare(a.Webhook, b.Webhook))
+	})
+	slices.SortFunc(merged.Operators, func(a, b Operator) int {
+		return cmp.Or(cmp.Compare(a.Resource, b.Resource), cmp.Compare(a.Deployment, b.Deployment))
+	})
+
+	// an edge closing a cycle that no single source has is a conflict
+	for _, kind := range sortedKinds(merged.Owners) {
+		for _, owner := range sortedKinds(merged.Owners[kind]) {
+			if kind == owner || !ownedBy(merged, owner, kind) {
+				continue
+			}
+			// cycles within a single source are broken the same way as without merging
+			if slices.ContainsFunc(sources, func(c string) bool {
+				return ownedBy(graphs[c], kind, owner) && ownedBy(graphs[c], owner, kind)
+			}) {
+				continue
+			}
+
+			after := slices.IndexFunc(sources, func(c string) bool {
+				_, ok := graphs[c].Owners[kind][owner]
+				return ok
+			})
+			before := slices.IndexFunc(sources, func(c string) bool {
+				return ownedBy(graphs[c], owner, kind)
+			})
+			if before < 0 {
+				return nil, fmt.Errorf("%s restores %s after %s, which the other sources order the opposite way round", sources[after], merged.Name(kind), merged.Name(owner))
+			}
+			return nil, fmt.Errorf("%s restores %s after %s but %s orders them the opposite way round", sources[after], merged.Name(kind), merged.Name(owner), sources[before])
+		}
+	}
+
+	// the graphs of a namespace are merged across the sources holding it
+	namespaces := map[string]map[string]*Graph{}
+	for _, source := range sources {
+		for namespace, g := range graphs[source].Namespaces {
+			if namespaces[namespace] == nil {
+				namespaces[namespace] = map[string]*Graph{}
+			}
+			namespaces[namespace][source] = g
+		}
+	}
+	for namespace, sourced := range namespaces {
+		g, err := MergeGraphs(sourced)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+		if merged.Namespaces == nil {
+			merged.Namespaces = map[string]*Graph{}
+		}
+		merged.Namespaces[namespace] = g
+	}
+	return merged, nil
+}
+
+// ownedBy reports whether kind is owned by owner, directly or through other kinds
+func ownedBy(g *Graph, kind, owner schema.GroupKind) bool {
+	return reaches(g.Owners, kind, owner)
+}
+
+// reaches reports whether edges lead from kind to owner, directly or through other kinds
+func reaches(edges map[schema.GroupKind]map[schema.GroupKind]any, kind, owner schema.GroupKind) bool {
+	seen := map[schema.GroupKind]bool{}
+	queue := []schema.GroupKind{kind}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for o := range edges[current] {
+			if o == owner {
+				return true
+			}
+			if !seen[o] {
+				seen[o] = true
+				queue = append(queue, o)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package restoreorder
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// edgeGraph returns a graph with an edge from every kind to its owner, given
+// as kind, owner pairs of the example.io group
+func edgeGraph(pairs ...string) *Graph {
+	g := NewGraph()
+	for i := 0; i+1 < len(pairs); i += 2 {
+		kind := schema.GroupKind{Group: "example.io", Kind: pairs[i]}
+		owner := schema.GroupKind{Group: "example.io", Kind: pairs[i+1]}
+		g.AddEdge(kind, owner)
+		for _, k := range []schema.GroupKind{kind, owner} {
+			g.Resources[k] = strings.ToLower(k.Kind) + "s.example.io"
+			g.Namespaced[k] = true
+		}
+	}
+	return g
+}
+
+func TestMergeGraphs(t *testing.T) {
+	tests := []struct {
+		name    string
+		graphs  map[string]*Graph
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "no sources",
+			graphs: map[string]*Graph{},
+		},
+		{
+			name: "compatible sources",
+			graphs: map[string]*Graph{
+				"a": edgeGraph("NodePool", "Cluster"),
+				"b": edgeGraph("Node", "NodePool", "Role", "Account"),
+			},
+			want: "accounts.example.io,clusters.example.io,nodepools.example.io,roles.example.io,nodes.example.io",
+		},
+		{
+			name: "opposite edges",
+			graphs: map[string]*Graph{
+				"a": edgeGraph("NodePool", "Cluster"),
+				"b": edgeGraph("Cluster", "NodePool"),
+			},
+			wantErr: "b restores clusters.example.io after nodepools.example.io but a orders them the opposite way round",
+		},
+		{
+			name: "opposite edges across sources",
+			graphs: map[string]*Graph{
+				"a": edgeGraph("NodePool", "Cluster"),
+				"b": edgeGraph("Node", "NodePool"),
+				"c": edgeGraph("Cluster", "Node"),
+			},
+			wantErr: "which the other sources order the opposite way round",
+		},
+		{
+			// the cycle is broken at the same kind as without merging
+			name: "cycle within a source",
+			graphs: map[string]*Graph{
+				"a": edgeGraph("NodePool", "Cluster", "Cluster", "NodePool"),
+				"b": edgeGraph("Node", "NodePool"),
+			},
+			want: "nodepools.example.io,clusters.example.io,nodes.example.io",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, g := range tt.graphs {
+				g.DefaultOrder = []string{}
+			}
+			merged, err := MergeGraphs(tt.graphs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			merged.DefaultOrder = []string{}
+			if got := merged.Priorities(); got != tt.want {
+				t.Errorf("got priorities %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeGraphsShared checks the counts, sync waves and options of the
+// sources are merged
+func TestMergeGraphsShared(t *testing.T) {
+	cluster := schema.GroupKind{Group: "example.io", Kind: "Cluster"}
+	a := edgeGraph("NodePool", "Cluster")
+	a.Counts[cluster] = 2
+	a.SyncWaves[cluster] = 1
+	a.Tiebreak = TiebreakCount
+	b := edgeGraph("NodePool", "Cluster")
+	b.Counts[cluster] = 3
+	b.SyncWaves[cluster] = -1
+	b.Tiebreak = TiebreakAlpha
+
+	merged, err := MergeGraphs(map[string]*Graph{"b": b, "a": a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := merged.Counts[cluster]; got != 5 {
+		t.Errorf("got %d clusters, want 5", got)
+	}
+	if got := merged.SyncWaves[cluster]; got != -1 {
+		t.Errorf("got sync wave %d, want the lowest -1", got)
+	}
+	if merged.Tiebreak != TiebreakCount {
+		t.Errorf("got tiebreak %s, want %s of the first source", merged.Tiebreak, TiebreakCount)
+	}
+}
+
+// TestMergeGraphsNamespaces checks the graphs of a namespace are merged
+// across the sources holding it and conflict within the namespace only
+func TestMergeGraphsNamespaces(t *testing.T) {
+	a := edgeGraph("NodePool", "Cluster")
+	a.Namespaces = map[string]*Graph{
+		"shop": edgeGraph("NodePool", "Cluster"),
+		"ops":  edgeGraph("Role", "Account"),
+	}
+	b := edgeGraph("NodePool", "Cluster")
+	b.Namespaces = map[string]*Graph{
+		"shop": edgeGraph("Node", "NodePool"),
+	}
+
+	merged, err := MergeGraphs(map[string]*Graph{"a": a, "b": b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"shop": "clusters.example.io,nodepools.example.io,nodes.example.io",
+		"ops":  "accounts.example.io,roles.example.io",
+	}
+	if len(merged.Namespaces) != len(want) {
+		t.Fatalf("got namespaces %v, want %v", merged.Namespaces, want)
+	}
+	for namespace, order := range want {
+		g := merged.Namespaces[namespace]
+		if g == nil {
+			t.Fatalf("namespace %s is missing", namespace)
+		}
+		if got := strings.Join(g.Order(), ","); got != order {
+			t.Errorf("got order %s in namespace %s, want %s", got, namespace, order)
+		}
+	}
+
+	b.Namespaces["ops"] = edgeGraph("Account", "Role")
+	if _, err := MergeGraphs(map[string]*Graph{"a": a, "b": b}); err == nil || !strings.HasPrefix(err.Error(), "namespace ops: ") {
+		t.Errorf("got error %v, want a conflict in namespace ops", err)
+	}
+}
@@ -0,0 +1,99 @@
+package restoreorder
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// ResourceModifiersKey is the ConfigMap key the resource modifiers are written to
+const ResourceModifiersKey = "resource-modifiers.yaml"
+
+// ResourceModifiers is a Velero resource modifiers document, the rules a
+// restore given --resource-modifier-configmap patches the restored objects with
+type ResourceModifiers struct {
+	Version string                 `json:"version"`
+	Rules   []ResourceModifierRule `json:"resourceModifierRules"`
+}
+
+// ResourceModifierRule patches the restored objects of a resource
+type ResourceModifierRule struct {
+	Conditions ModifierConditions `json:"conditions"`
+	Patches    []ModifierPatch    `json:"patches"`
+}
+
+// ModifierConditions select the objects a rule patches
+type ModifierConditions struct {
+	// GroupResource is the resource in plural.group form
+	GroupResource string `json:"groupResource"`
+}
+
+// ModifierPatch is a JSON patch operation as Velero takes it, the value is
+// the JSON text of the value
+type ModifierPatch struct {
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	Value     string `json:"value,omitempty"`
+}
+
+// ResourceModifiers returns the resource modifiers that make the restored
+// custom resources reconcile: the status is reset, as it describes objects
+// of the backed up cluster, and owned kinds lose their owner references,
+// whose UIDs do not exist after a restore, so their controllers adopt them.
+// the patches add empty values rather than remove fields, as removing a
+// missing field fails the restore of the object
+func (g *Graph) ResourceModifiers() ResourceModifiers {
+	builtin := builtinKinds()
+	modifiers := ResourceModifiers{Version: "v1", Rules: []ResourceModifierRule{}}
+	for _, kind := range sortedKinds(g.Resources) {
+		if slices.Contains(builtin, kind) {
+			continue
+		}
+		rule := ResourceModifierRule{
+			Conditions: ModifierConditions{GroupResource: g.Resources[kind]},
+			Patches:    []ModifierPatch{{Operation: "add", Path: "/status", Value: "{}"}},
+		}
+		if len(g.Owners[kind]) > 0 {
+			rule.Patches = append(rule.Patches, ModifierPatch{Operation: "add", Path: "/metadata/ownerReferences", Value: "[]"})
+		}
+		modifiers.Rules = append(modifiers.Rules, rule)
+	}
+	return modifiers
+}
+
+// ResourceModifiersConfigMap returns the ConfigMap namespace/name holding the
+// resource modifiers of g, annotated with provenance
+func (g *Graph) ResourceModifiersConfigMap(namespace, name string, provenance Provenance) (*unstructured.Unstructured, error) {
+	data, err := yaml.Marshal(g.ResourceModifiers())
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode resource modifiers: %w", err)
+	}
+
+	cm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"data": map[string]interface{}{
+			ResourceModifiersKey: string(data),
+		},
+	}}
+	provenance.Annotate(cm)
+	return cm, nil
+}
+
+// ApplyResourceModifiers creates or server-side applies the ConfigMap
+// namespace/name holding the resource modifiers of g
+func (g *Graph) ApplyResourceModifiers(ctx context.Context, client dynamic.Interface, namespace, name string, provenance Provenance) error {
+	data, err := yaml.Marshal(g.ResourceModifiers())
+	if err != nil {
+		return fmt.Errorf("cannot encode resource modifiers: %w", err)
+	}
+	return ApplyConfigMap(ctx, client, namespace, name, map[string]string{ResourceModifiersKey: string(data)}, provenance)
+}
@@ -0,0 +1,146 @@
+package restoreorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OrderChange is the payload posted to a notification URL when the computed
+// order changes
+type OrderChange struct {
+	// Old and New are the entries of the restore-resource-priorities value
+	// before and after the change
+	Old []string `json:"old"`
+	New []string `json:"new"`
+	// Diff is a unified diff from Old to New, one entry per line
+	Diff string `json:"diff"`
+	// Trigger is what changed the order, such as the CRD whose definition or
+	// resources changed, empty when it is not known
+	Trigger string `json:"trigger,omitempty"`
+	// Text summarizes the change, it is what Slack incoming webhooks display
+	Text string `json:"text"`
+}
+
+// NewOrderChange returns the change from the old to the new priorities
+func NewOrderChange(old, new, trigger string) OrderChange {
+	change := OrderChange{
+		Old:     ParsePriorities(old),
+		New:     ParsePriorities(new),
+		Trigger: trigger,
+	}
+	diff := &strings.Builder{}
+	// writing to a strings.Builder cannot fail
+	Diff(diff, "old", "new", old, new)
+	change.Diff = diff.String()
+
+	// the "-" low priority delimiter is not a resource
+	added, removed := []string{}, []string{}
+	for _, entry := range change.New {
+		if entry != "-" && !slices.Contains(change.Old, entry) {
+			added = append(added, entry)
+		}
+	}
+	for _, entry := range change.Old {
+		if entry != "-" && !slices.Contains(change.New, entry) {
+			removed = append(removed, entry)
+		}
+	}
+	change.Text = "restore order changed"
+	if trigger != "" {
+		change.Text += " (" + trigger + ")"
+	}
+	if len(added) > 0 {
+		change.Text += ", added " + strings.Join(added, ", ")
+	}
+	if len(removed) > 0 {
+		change.Text += ", removed " + strings.Join(removed, ", ")
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		change.Text += ", entries reordered"
+	}
+	change.Text += "\n```\n" + change.Diff + "```"
+	return change
+}
+
+// Notifier posts order changes as JSON to a webhook, such as a Slack incoming webhook
+type Notifier struct {
+	URL string
+	// Client posts the changes, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Notify posts change to the webhook
+func (n *Notifier) Notify(ctx context.Context, change OrderChange) error {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("cannot encode order change: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create notification: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("cannot send notification: %s", resp.Status)
+	}
+	return nil
+}
+
+// triggerSource is a GraphSource that knows what last changed its graph
+type triggerSource interface {
+	// Trigger returns what last changed the graph
+	Trigger() string
+}
+
+// NotifyChanges checks the order served by source every interval until ctx
+// is done and notifies n whenever it changes. the first order seen is
+// only recorded, as there is nothing to compare it to
+func NotifyChanges(ctx context.Context, source GraphSource, n *Notifier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		graph, _, _ := source.Latest()
+		if graph == nil {
+			continue
+		}
+		priorities := graph.Priorities()
+		if last != "" && priorities != last {
+			trigger := ""
+			if t, ok := source.(triggerSource); ok {
+				trigger = t.Trigger()
+			}
+			// a failed notification is sent again on the next check
+			if err := n.Notify(ctx, NewOrderChange(last, priorities, trigger)); err != nil {
+				slog.Error("cannot notify order change", "error", err)
+				continue
+			}
+			slog.Info("notified order change", "trigger", trigger)
+		}
+		last = priorities
+	}
+}
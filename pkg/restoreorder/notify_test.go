@@ -0,0 +1,195 @@
+package restoreorder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewOrderChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		trigger  string
+		wantText string
+		wantDiff string
+	}{
+		{
+			name:     "added",
+			old:      "clusters.x.io",
+			new:      "clusters.x.io,databases.x.io",
+			wantText: "restore order changed, added databases.x.io",
+			wantDiff: "--- old\n+++ new\n@@ -1,1 +1,2 @@\n clusters.x.io\n+databases.x.io\n",
+		},
+		{
+			name:     "removed",
+			old:      "clusters.x.io,databases.x.io",
+			new:      "clusters.x.io",
+			trigger:  "databases.x.io",
+			wantText: "restore order changed (databases.x.io), removed databases.x.io",
+			wantDiff: "--- old\n+++ new\n@@ -1,2 +1,1 @@\n clusters.x.io\n-databases.x.io\n",
+		},
+		{
+			name:     "reordered",
+			old:      "clusters.x.io,databases.x.io",
+			new:      "databases.x.io,clusters.x.io",
+			wantText: "restore order changed, entries reordered",
+			wantDiff: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-clusters.x.io\n databases.x.io\n+clusters.x.io\n",
+		},
+		{
+			// the low priority delimiter is not reported as a resource
+			name:     "low priority",
+			old:      RestoreFlag + "=clusters.x.io",
+			new:      "clusters.x.io,-,backups.x.io",
+			wantText: "restore order changed, added backups.x.io",
+			wantDiff: "--- old\n+++ new\n@@ -1,1 +1,3 @@\n clusters.x.io\n+-\n+backups.x.io\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := NewOrderChange(tt.old, tt.new, tt.trigger)
+			if !slices.Equal(change.Old, ParsePriorities(tt.old)) || !slices.Equal(change.New, ParsePriorities(tt.new)) {
+				t.Errorf("got entries %v to %v, want %s to %s", change.Old, change.New, tt.old, tt.new)
+			}
+			if change.Trigger != tt.trigger {
+				t.Errorf("got trigger %q, want %q", change.Trigger, tt.trigger)
+			}
+			if change.Diff != tt.wantDiff {
+				t.Errorf("got diff\n%s\nwant\n%s", change.Diff, tt.wantDiff)
+			}
+			if want := tt.wantText + "\n```\n" + tt.wantDiff + "```"; change.Text != want {
+				t.Errorf("got text\n%s\nwant\n%s", change.Text, want)
+			}
+		})
+	}
+}
+
+// notifyServer records the order changes posted to it
+type notifyServer struct {
+	mu      sync.Mutex
+	changes []OrderChange
+	status  int
+}
+
+func (s *notifyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "want a json post", http.StatusBadRequest)
+		return
+	}
+	change := OrderChange{}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	s.changes = append(s.changes, change)
+}
+
+func (s *notifyServer) received() []OrderChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.changes)
+}
+
+func TestNotifierNotify(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "sent"},
+		{name: "rejected", status: http.StatusForbidden, wantErr: "cannot send notification: 403 Forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &notifyServer{status: tt.status}
+			server := httptest.NewServer(s)
+			defer server.Close()
+
+			change := NewOrderChange("clusters.x.io", "clusters.x.io,databases.x.io", "databases.x.io")
+			err := (&Notifier{URL: server.URL}).Notify(context.Background(), change)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.received(); len(got) != 1 || got[0].Text != change.Text || got[0].Trigger != change.Trigger {
+				t.Errorf("got changes %v, want %v", got, change)
+			}
+		})
+	}
+}
+
+// sequenceSource serves its graphs one per call, then the last one forever
+type sequenceSource struct {
+	mu      sync.Mutex
+	graphs  []*Graph
+	trigger string
+}
+
+func (s *sequenceSource) Latest() (*Graph, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	graph := s.graphs[0]
+	if len(s.graphs) > 1 {
+		s.graphs = s.graphs[1:]
+	}
+	return graph, time.Time{}, nil
+}
+
+func (s *sequenceSource) Trigger() string {
+	return s.trigger
+}
+
+func TestNotifyChanges(t *testing.T) {
+	owned, reversed := edgeGraph("Database", "Cluster"), edgeGraph("Cluster", "Database")
+	owned.DefaultOrder, reversed.DefaultOrder = []string{}, []string{}
+	// nothing is notified before the first graph, nor when the order is unchanged
+	source := &sequenceSource{graphs: []*Graph{nil, owned, owned, reversed}, trigger: "databases.example.io"}
+
+	s := &notifyServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NotifyChanges(ctx, source, &Notifier{URL: server.URL}, time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	want := NewOrderChange(owned.Priorities(), reversed.Priorities(), "databases.example.io")
+	deadline := time.Now().Add(10 * time.Second)
+	for len(s.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// the last graph is served on every later check, so it is only notified once
+	time.Sleep(20 * time.Millisecond)
+	got := s.received()
+	if len(got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(got))
+	}
+	if !strings.HasPrefix(got[0].Text, "restore order changed (databases.example.io), entries reordered") || got[0].Diff != want.Diff {
+		t.Errorf("got change %v, want %v", got[0], want)
+	}
+}
@@ -0,0 +1,81 @@
+package restoreorder
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// OLM is the name of the Operator Lifecycle Manager detector
+const OLM = "olm"
+
+// OLMGroup is the group of OperatorGroups, Subscriptions, CatalogSources and ClusterServiceVersions
+const OLMGroup = "operators.coreos.com"
+
+func init() {
+	RegisterDetector(OLM, OLMDetector{})
+}
+
+// the OLM kinds ordered by the detector
+var (
+	olmOperatorGroup = schema.GroupKind{Group: OLMGroup, Kind: "OperatorGroup"}
+	olmCatalogSource = schema.GroupKind{Group: OLMGroup, Kind: "CatalogSource"}
+	olmSubscription  = schema.GroupKind{Group: OLMGroup, Kind: "Subscription"}
+	olmCSV           = schema.GroupKind{Group: OLMGroup, Kind: "ClusterServiceVersion"}
+)
+
+// OLMDetector orders the objects OLM installs operators from:
+// OperatorGroups and CatalogSources before the Subscriptions and
+// ClusterServiceVersions that need them, and the resources of the CRDs a
+// ClusterServiceVersion owns after it, as nothing reconciles them before
+// the operator it installs runs
+type OLMDetector struct{}
+
+// WantsObjects reports whether crd serves an OLM kind
+func (OLMDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return group == OLMGroup
+}
+
+// Detect returns the edges between obj and the OLM objects it needs
+func (OLMDetector) Detect(obj unstructured.Unstructured) []Edge {
+	switch obj.GroupVersionKind().GroupKind() {
+	case olmSubscription:
+		edges := []Edge{{Kind: olmSubscription, Owner: olmOperatorGroup}}
+		if source, _, _ := unstructured.NestedString(obj.Object, "spec", "source"); source != "" {
+			edges = append(edges, Edge{Kind: olmSubscription, Owner: olmCatalogSource})
+		}
+		return edges
+	case olmCSV:
+		// copies of the CSV of an operator watching several namespaces own nothing
+		if _, ok := obj.GetLabels()["olm.copiedFrom"]; ok {
+			return nil
+		}
+		edges := []Edge{{Kind: olmCSV, Owner: olmOperatorGroup}}
+		owned, _, _ := unstructured.NestedSlice(obj.Object, "spec", "customresourcedefinitions", "owned")
+		for _, crd := range owned {
+			crd, ok := crd.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if kind, ok := ownedCRDKind(crd); ok && kind != olmCSV {
+				edges = append(edges, Edge{Kind: kind, Owner: olmCSV})
+			}
+		}
+		return edges
+	}
+	return nil
+}
+
+// ownedCRDKind returns the kind of an owned CRD entry of a ClusterServiceVersion,
+// whose name is the CRD name, plural.group
+func ownedCRDKind(crd map[string]interface{}) (schema.GroupKind, bool) {
+	name, _, _ := unstructured.NestedString(crd, "name")
+	kind, _, _ := unstructured.NestedString(crd, "kind")
+	_, group, ok := strings.Cut(name, ".")
+	if !ok || kind == "" {
+		return schema.GroupKind{}, false
+	}
+	return schema.GroupKind{Group: group, Kind: kind}, true
+}
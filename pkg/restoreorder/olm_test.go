@@ -0,0 +1,106 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestOLMDetector(t *testing.T) {
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+	cluster := schema.GroupKind{Group: "x.io", Kind: "Cluster"}
+
+	testDetector(t, OLMDetector{}, []detectorTest{
+		{
+			name: "subscription",
+			manifest: `
+apiVersion: operators.coreos.com/v1alpha1
+kind: Subscription
+metadata:
+  name: x-operator
+  namespace: operators
+spec:
+  channel: stable
+  name: x-operator
+  source: operatorhubio-catalog
+  sourceNamespace: olm
+`,
+			want: []Edge{
+				{Kind: olmSubscription, Owner: olmOperatorGroup},
+				{Kind: olmSubscription, Owner: olmCatalogSource},
+			},
+		},
+		{
+			name: "subscription without source",
+			manifest: `
+apiVersion: operators.coreos.com/v1alpha1
+kind: Subscription
+metadata:
+  name: x-operator
+  namespace: operators
+spec:
+  name: x-operator
+`,
+			want: []Edge{{Kind: olmSubscription, Owner: olmOperatorGroup}},
+		},
+		{
+			// an entry without a group or kind names nothing to order
+			name: "cluster service version",
+			manifest: `
+apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: x-operator.v1.2.0
+  namespace: operators
+spec:
+  customresourcedefinitions:
+    owned:
+    - name: databases.x.io
+      kind: Database
+      version: v1
+    - name: clusters.x.io
+      kind: Cluster
+      version: v1
+    - name: widgets
+      kind: Widget
+    - name: caches.x.io
+`,
+			want: []Edge{
+				{Kind: olmCSV, Owner: olmOperatorGroup},
+				{Kind: database, Owner: olmCSV},
+				{Kind: cluster, Owner: olmCSV},
+			},
+		},
+		{
+			name: "copied cluster service version",
+			manifest: `
+apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: x-operator.v1.2.0
+  namespace: shop
+  labels:
+    olm.copiedFrom: operators
+spec:
+  customresourcedefinitions:
+    owned:
+    - name: databases.x.io
+      kind: Database
+      version: v1
+`,
+		},
+		{
+			name: "catalog source",
+			manifest: `
+apiVersion: operators.coreos.com/v1alpha1
+kind: CatalogSource
+metadata:
+  name: operatorhubio-catalog
+  namespace: olm
+spec:
+  sourceType: grpc
+  image: quay.io/operatorhubio/catalog:latest
+`,
+		},
+	})
+}
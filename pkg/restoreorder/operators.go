@@ -0,0 +1,164 @@
+package restoreorder
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// ServiceResource is the resource webhook backends are served by
+var ServiceResource = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+
+// OLMLabelPrefix starts the operators.coreos.com/<package>.<namespace> label
+// OLM sets on the CRDs and Deployments of the operators it installs
+const OLMLabelPrefix = "operators.coreos.com/"
+
+// the ways an operator is found serving a CRD
+const (
+	// OperatorViaConversionWebhook is a Deployment selected by the service of the conversion webhook of the CRD
+	OperatorViaConversionWebhook = "conversion webhook"
+	// OperatorViaOLM is a Deployment sharing the OLM operator label of the CRD
+	OperatorViaOLM = "olm"
+	// OperatorViaLabels is a Deployment sharing the app.kubernetes.io/instance,
+	// or failing that app.kubernetes.io/part-of, label of the CRD, as Helm
+	// charts and most installers set them
+	OperatorViaLabels = "labels"
+)
+
+// Operator is a Deployment found serving the resources of a scanned CRD,
+// restoring its resources before it runs leaves them unreconciled
+type Operator struct {
+	// Resource is the CRD name
+	Resource string
+	// Deployment is the namespace/name of the Deployment
+	Deployment string
+	// Via is how the Deployment was found, one of the OperatorVia constants
+	Via string
+}
+
+// findOperators returns the Deployments serving the scanned CRDs, found
+// through their conversion webhook service, OLM labels or the common labels
+// of installers, in that order of preference
+func findOperators(ctx context.Context, client dynamic.Interface, crds []unstructured.Unstructured) []Operator {
+	deployments, err := client.Resource(DeploymentResource).List(ctx, v1.ListOptions{})
+	if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+		slog.Warn("cannot find operators", "resource", DeploymentResource.Resource, "error", err)
+		return nil
+	}
+	if err != nil {
+		slog.Error("cannot list deployments", "error", err)
+		return nil
+	}
+
+	operators := []Operator{}
+	for _, crd := range crds {
+		found := conversionWebhookOperators(ctx, client, crd, deployments.Items)
+		if len(found) == 0 {
+			found = olmOperators(crd, deployments.Items)
+		}
+		if len(found) == 0 {
+			found = labeledOperators(crd, deployments.Items)
+		}
+		operators = append(operators, found...)
+	}
+	slices.SortFunc(operators, func(a, b Operator) int {
+		return cmp.Or(cmp.Compare(a.Resource, b.Resource), cmp.Compare(a.Deployment, b.Deployment))
+	})
+	return operators
+}
+
+// conversionWebhookOperators returns the Deployments whose pods the service
+// of the conversion webhook of crd selects
+func conversionWebhookOperators(ctx context.Context, client dynamic.Interface, crd unstructured.Unstructured, deployments []unstructured.Unstructured) []Operator {
+	dep, ok := conversionWebhook(crd)
+	if !ok || dep.Service == "" {
+		return nil
+	}
+	namespace, name, _ := strings.Cut(dep.Service, "/")
+	service, err := client.Resource(ServiceResource).Namespace(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		slog.Warn("cannot get conversion webhook service", "crd", crd.GetName(), "service", dep.Service, "error", err)
+		return nil
+	}
+	selector, _, _ := unstructured.NestedStringMap(service.Object, "spec", "selector")
+	if len(selector) == 0 {
+		return nil
+	}
+
+	operators := []Operator{}
+	for _, deploy := range deployments {
+		podLabels, _, _ := unstructured.NestedStringMap(deploy.Object, "spec", "template", "metadata", "labels")
+		if deploy.GetNamespace() == namespace && labels.SelectorFromSet(selector).Matches(labels.Set(podLabels)) {
+			operators = append(operators, operator(crd, deploy, OperatorViaConversionWebhook))
+		}
+	}
+	return operators
+}
+
+// olmOperators returns the Deployments labeled with an OLM operator label of crd
+func olmOperators(crd unstructured.Unstructured, deployments []unstructured.Unstructured) []Operator {
+	operators := []Operator{}
+	for key := range crd.GetLabels() {
+		if !strings.HasPrefix(key, OLMLabelPrefix) {
+			continue
+		}
+		for _, deploy := range deployments {
+			if _, ok := deploy.GetLabels()[key]; ok {
+				operators = append(operators, operator(crd, deploy, OperatorViaOLM))
+			}
+		}
+	}
+	return operators
+}
+
+// labeledOperators returns the Deployments with the same installer label as crd
+func labeledOperators(crd unstructured.Unstructured, deployments []unstructured.Unstructured) []Operator {
+	for _, key := range []string{"app.kubernetes.io/instance", "app.kubernetes.io/part-of"} {
+		value := crd.GetLabels()[key]
+		if value == "" {
+			continue
+		}
+		operators := []Operator{}
+		for _, deploy := range deployments {
+			if deploy.GetLabels()[key] == value {
+				operators = append(operators, operator(crd, deploy, OperatorViaLabels))
+			}
+		}
+		if len(operators) > 0 {
+			return operators
+		}
+	}
+	return nil
+}
+
+// operator returns deploy as the operator of crd found via
+func operator(crd, deploy unstructured.Unstructured, via string) Operator {
+	return Operator{Resource: crd.GetName(), Deployment: deploy.GetNamespace() + "/" + deploy.GetName(), Via: via}
+}
+
+// addOperators records the operators of the scanned resources in graph and
+// orders Deployments before the kinds they serve. velero restores namespaces
+// before any of them already, so the Deployments have their namespace
+func addOperators(graph *Graph, operators []Operator) {
+	graph.Operators = append(graph.Operators, operators...)
+	deployments := slices.IndexFunc(BuiltinChildResources, func(res GVK) bool {
+		return res.GVR == DeploymentResource
+	})
+	for _, op := range operators {
+		kind, ok := graph.Kind(op.Resource)
+		if !ok {
+			continue
+		}
+		addBuiltin(graph, BuiltinChildResources[deployments])
+		graph.AddEdge(kind, BuiltinChildResources[deployments].GroupKind())
+	}
+}
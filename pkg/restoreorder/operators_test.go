@@ -0,0 +1,165 @@
+package restoreorder
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// operatorManifests returns CRDs of x.io found serving through each way
+// findOperators knows, and the Deployments and Service serving them
+func operatorManifests(t *testing.T) []unstructured.Unstructured {
+	t.Helper()
+	// the conversion webhook is preferred over the OLM label of the CRD
+	converted := testCRD("x.io", "App")
+	converted.SetLabels(map[string]string{OLMLabelPrefix + "x-operator.operators": ""})
+	if err := unstructured.SetNestedField(converted.Object, map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"clientConfig": map[string]interface{}{
+				"service": map[string]interface{}{"namespace": "x-system", "name": "x-webhook"},
+			},
+		},
+	}, "spec", "conversion"); err != nil {
+		t.Fatal(err)
+	}
+	olm := testCRD("x.io", "Database")
+	olm.SetLabels(map[string]string{OLMLabelPrefix + "x-operator.operators": ""})
+	// the instance label is preferred over the part-of label
+	instance := testCRD("x.io", "Cache")
+	instance.SetLabels(map[string]string{"app.kubernetes.io/instance": "x-cache", "app.kubernetes.io/part-of": "x"})
+	partOf := testCRD("x.io", "Queue")
+	partOf.SetLabels(map[string]string{"app.kubernetes.io/instance": "x-queue", "app.kubernetes.io/part-of": "x"})
+
+	return []unstructured.Unstructured{
+		converted, olm, instance, partOf,
+		testCRD("x.io", "Widget"),
+		testManifest(t, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: x-webhook
+  namespace: x-system
+spec:
+  selector:
+    app: x-controller
+`),
+		testManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: x-controller
+  namespace: x-system
+spec:
+  template:
+    metadata:
+      labels:
+        app: x-controller
+        pod-template-hash: 5d8f7
+`),
+		// the service only selects pods in its namespace
+		testManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: x-controller
+  namespace: other
+spec:
+  template:
+    metadata:
+      labels:
+        app: x-controller
+`),
+		testManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: x-operator
+  namespace: operators
+  labels:
+    operators.coreos.com/x-operator.operators: ""
+`),
+		testManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: x-cache
+  namespace: x-system
+  labels:
+    app.kubernetes.io/instance: x-cache
+    app.kubernetes.io/part-of: x
+`),
+		testManifest(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: x-manager
+  namespace: x-system
+  labels:
+    app.kubernetes.io/part-of: x
+`),
+	}
+}
+
+func TestFindOperators(t *testing.T) {
+	manifests := operatorManifests(t)
+	client, _, err := ManifestClients(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crds := []unstructured.Unstructured{}
+	for _, obj := range manifests {
+		if obj.GetKind() == "CustomResourceDefinition" {
+			crds = append(crds, obj)
+		}
+	}
+
+	want := []Operator{
+		{Resource: "apps.x.io", Deployment: "x-system/x-controller", Via: OperatorViaConversionWebhook},
+		{Resource: "caches.x.io", Deployment: "x-system/x-cache", Via: OperatorViaLabels},
+		{Resource: "databases.x.io", Deployment: "operators/x-operator", Via: OperatorViaOLM},
+		{Resource: "queues.x.io", Deployment: "x-system/x-cache", Via: OperatorViaLabels},
+		{Resource: "queues.x.io", Deployment: "x-system/x-manager", Via: OperatorViaLabels},
+	}
+	if got := findOperators(context.Background(), client, crds); !slices.Equal(got, want) {
+		t.Errorf("got operators %v, want %v", got, want)
+	}
+}
+
+func TestAddOperators(t *testing.T) {
+	deployment := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	served := []schema.GroupKind{
+		{Group: "x.io", Kind: "App"},
+		{Group: "x.io", Kind: "Cache"},
+		{Group: "x.io", Kind: "Database"},
+		{Group: "x.io", Kind: "Queue"},
+	}
+	widget := schema.GroupKind{Group: "x.io", Kind: "Widget"}
+
+	tests := []struct {
+		name           string
+		operatorsFirst bool
+	}{
+		{name: "off"},
+		{name: "operators first", operatorsFirst: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := discoverManifests(t, operatorManifests(t), Options{OperatorsFirst: tt.operatorsFirst})
+			if got := len(graph.Operators) > 0; got != tt.operatorsFirst {
+				t.Errorf("got operators %v, want operators recorded %t", graph.Operators, tt.operatorsFirst)
+			}
+			for _, kind := range served {
+				if _, ok := graph.Owners[kind][deployment]; ok != tt.operatorsFirst {
+					t.Errorf("got %s ordered after %s %t, want %t", kind, deployment, ok, tt.operatorsFirst)
+				}
+			}
+			if _, ok := graph.Owners[widget][deployment]; ok {
+				t.Errorf("got %s ordered after %s, which no operator serves", widget, deployment)
+			}
+		})
+	}
+}
@@ -0,0 +1,621 @@
+// Package restoreorder computes a Velero restore order for custom resources
+// from the owner references between them.
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/maps"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+)
+
+// RestoreFlag is the Velero server flag the computed order is passed to
+const RestoreFlag = `--restore-resource-priorities`
+
+// DefaultIgnoreGroups are the API groups that are left out of the computation
+var DefaultIgnoreGroups = []string{
+	"iam.aws.zendesk.com",
+}
+
+// https://velero.io/docs/v1.15/restore-reference/#restore-order
+var DefaultOrder = []string{
+	"customresourcedefinitions",
+	"namespaces,storageclasses",
+	"volumesnapshotclass.snapshot.storage.k8s.io",
+	"volumesnapshotcontents.snapshot.storage.k8s.io",
+	"volumesnapshots.snapshot.storage.k8s.io",
+	"persistentvolumes,persistentvolumeclaims",
+	"secrets",
+	"configmaps",
+	"serviceaccounts",
+	"limitranges",
+	"pods",
+	"replicasets.apps",
+	"clusters.cluster.x-k8s.io",
+	"clusterresourcesets.addons.cluster.x-k8s.io",
+}
+
+// DefaultMaxPrioritiesLength is the length of a priorities value past which
+// it becomes hard to read in Helm values and to pass as a container argument
+const DefaultMaxPrioritiesLength = 4096
+
+// ExcludeFromBackupLabel marks resources and CRDs that velero leaves out of backups
+const ExcludeFromBackupLabel = "velero.io/exclude-from-backup"
+
+// IgnoreAnnotation set to "true" on a CRD or a custom resource leaves it out
+// of the scan, so teams can opt noisy kinds out of the ordering themselves
+const IgnoreAnnotation = "whoisyourdaddy.io/ignore"
+
+// includesCRD reports whether the group and categories of crd, serving res,
+// pass the group and category filters of o
+func (o Options) includesCRD(crd unstructured.Unstructured, res GVK) bool {
+	if matchesAny(o.IgnoreGroups, res.GVR.Group) {
+		return false
+	}
+	if len(o.IncludeGroups) > 0 && !matchesAny(o.IncludeGroups, res.GVR.Group) {
+		return false
+	}
+
+	categories, _, _ := unstructured.NestedStringSlice(crd.Object, "spec", "names", "categories")
+	if slices.ContainsFunc(categories, func(c string) bool { return slices.Contains(o.IgnoreCategories, c) }) {
+		return false
+	}
+	return len(o.IncludeCategories) == 0 ||
+		slices.ContainsFunc(categories, func(c string) bool { return slices.Contains(o.IncludeCategories, c) })
+}
+
+// ignored reports whether obj opted out of the scan with the IgnoreAnnotation
+func ignored(obj v1.Object) bool {
+	return obj.GetAnnotations()[IgnoreAnnotation] == "true"
+}
+
+// Options control how the cluster is scanned
+type Options struct {
+	// PageSize is the number of resources requested per list call, 0 disables pagination
+	PageSize int64
+	// IgnoreGroups are API groups whose CRDs are left out of the graph, globs allowed
+	IgnoreGroups []string
+	// IncludeGroups, when set, only scans the CRDs of these API groups, globs allowed
+	IncludeGroups []string
+	// IncludeCategories, when set, only scans the CRDs in one of these
+	// categories, e.g. crossplane or managed
+	IncludeCategories []string
+	// IgnoreCategories leaves out the CRDs in any of these categories
+	IgnoreCategories []string
+	// RespectVeleroLabels leaves out CRDs and resources labeled with
+	// velero.io/exclude-from-backup=true, as velero will never restore them
+	RespectVeleroLabels bool
+	// Scope restricts the scan to the resources a backup would contain
+	Scope Scope
+	// IncludeBuiltinChildren also orders the built-in resources in
+	// BuiltinChildResources that are owned by custom resources
+	IncludeBuiltinChildren bool
+	// Detectors find the dependencies between resources, DefaultDetectors when empty
+	Detectors []DependencyDetector
+	// SyncWaves orders kinds by the argocd.argoproj.io/sync-wave annotations
+	// of their resources through soft edges, owners still always come first
+	SyncWaves bool
+	// Hints are merged into the discovered graph when set
+	Hints *Hints
+	// IncludeNonControllerOwners has the OwnerReferenceDetectors order
+	// resources after every owner rather than only their controller
+	IncludeNonControllerOwners bool
+	// IncludeCrossScopeOwners keeps the edges of owner references crossing a
+	// scope boundary, which are left out and reported in Graph.CrossScope
+	IncludeCrossScopeOwners bool
+	// InferSpecRefs also runs a SpecRefDetector over the scanned resources
+	InferSpecRefs bool
+	// DefaultOrder replaces the package DefaultOrder the computed order is
+	// added to when not nil, an empty slice leaves it out altogether
+	DefaultOrder []string
+	// LowPriority are resources listed after the "-" delimiter, which velero
+	// restores after every other resource
+	LowPriority []string
+	// UnrelatedLowPriority makes every scanned kind without owners or owned kinds low priority
+	UnrelatedLowPriority bool
+	// Qualify is how the entries of scanned kinds are written, see Graph.Qualify
+	Qualify string
+	// Tiebreak is how kinds at the same depth are ordered, see Graph.Tiebreak
+	Tiebreak string
+	// Compact leaves the kinds that own nothing out of the order, see Graph.Compact
+	Compact bool
+	// Minimal only lists the kinds whose position is required, see Graph.Minimal
+	Minimal bool
+	// BestEffort skips the CRDs that cannot be parsed and the resources that
+	// cannot be listed, after retrying, rather than failing with ListErrors,
+	// at the cost of a possibly incomplete order, the skipped resources are
+	// recorded in Graph.Skipped
+	BestEffort bool
+	// WebhooksFirst orders Deployments and Services, which webhook backends
+	// run as, before the resources that depend on webhooks
+	WebhooksFirst bool
+	// OperatorsFirst finds the Deployments of the operators serving the
+	// scanned CRDs, recorded in Graph.Operators, and orders Deployments
+	// before the resources they serve
+	OperatorsFirst bool
+	// ListTimeout bounds listing every resource of a single CRD, including
+	// all of its pages and retries, 0 for no limit
+	ListTimeout time.Duration
+	// ListBackoff is the backoff between the attempts of a list call that
+	// failed with a transient error, 6 attempts from 500ms up to 30s when
+	// Steps is 0. a Steps of 1 makes a single attempt. throttling errors are
+	// left to the retries of the REST client
+	ListBackoff wait.Backoff
+	// Concurrency is the number of CRDs whose resources are listed at once,
+	// DefaultConcurrency when 0
+	Concurrency int
+	// Cache, when set, is used for the resources of the CRDs unchanged since
+	// they were cached and stores the resources of the others
+	Cache *Cache
+	// FieldSelector only scans the resources matching it, custom resources
+	// can only be selected by metadata.name and metadata.namespace
+	FieldSelector string
+	// ResourceVersion is set on the first list call of every resource, "0"
+	// serves the lists from the API server cache, which may be stale, rather than etcd
+	ResourceVersion string
+	// PerNamespace also builds a graph for every namespace from its resources
+	// and the cluster-scoped ones, recorded in Graph.Namespaces. the cache
+	// is not used, as it does not record the namespaces the edges come from
+	PerNamespace bool
+	// Progress, when set, is called every time the resources of a CRD are listed
+	Progress func(ScanProgress)
+	// Aggregated, when set, discovers the resources served by aggregated API
+	// servers with it, which are scanned as if they were defined by CRDs
+	Aggregated discovery.ServerResourcesInterface
+}
+
+// DefaultConcurrency is the number of CRDs whose resources are listed at once
+// unless Options.Concurrency says otherwise, so clusters with hundreds of
+// CRDs do not flood the API server with list calls
+const DefaultConcurrency = 16
+
+// concurrency returns the number of CRDs whose resources are listed at once
+func (o Options) concurrency() int {
+	if o.Concurrency <= 0 {
+		return DefaultConcurrency
+	}
+	return o.Concurrency
+}
+
+// listContext returns the context listing the resources of a single CRD runs in
+func (o Options) listContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.ListTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, o.ListTimeout)
+}
+
+// listOptions returns the options every custom resource list is made with
+func (o Options) listOptions() v1.ListOptions {
+	selectors := []string{}
+	if o.RespectVeleroLabels {
+		selectors = append(selectors, ExcludeFromBackupLabel+"!=true")
+	}
+	if o.Scope.LabelSelector != "" {
+		selectors = append(selectors, o.Scope.LabelSelector)
+	}
+	return v1.ListOptions{
+		Limit:           o.PageSize,
+		LabelSelector:   strings.Join(selectors, ","),
+		FieldSelector:   o.FieldSelector,
+		ResourceVersion: o.ResourceVersion,
+	}
+}
+
+// Discover lists every CRD and custom resource in the cluster and builds
+// the ownership graph between their kinds
+func Discover(ctx context.Context, client dynamic.Interface, metadataClient metadata.Interface, opts Options) (graph *Graph, err error) {
+	ctx, span := tracer().Start(ctx, "Discover")
+	defer func() { endSpan(span, err) }()
+
+	crds, err := listCRDs(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	// the resources that could not be listed, every one is reported
+	// before failing so they can all be fixed at once
+	skipped := ListErrors{}
+	if opts.Aggregated != nil {
+		aggregated, listErrs, err := aggregatedCRDs(ctx, client, opts.Aggregated)
+		if err != nil {
+			return nil, err
+		}
+		crds.Items = append(crds.Items, aggregated...)
+		skipped = append(skipped, listErrs...)
+	}
+	scan := newCRDScan(crds.Items, opts)
+	skipped = append(skipped, scan.invalid...)
+	// the processes of exec detectors are stopped with the scan
+	defer closeDetectors(scan.detectors)
+
+	// the CRDs whose resources are listed, the others are unchanged since
+	// they were cached and their resources and edges are taken from the cache
+	listed := scan.scanned
+	cached := []cacheEntry{}
+	cacheKey := ""
+	if opts.Cache != nil && !opts.PerNamespace {
+		cacheKey = cacheOptions(opts, scan.detectors, scan.known)
+		listed = &unstructured.UnstructuredList{}
+		for _, crd := range scan.scanned.Items {
+			if entry, ok := opts.Cache.lookup(crd, cacheKey); ok {
+				cached = append(cached, entry)
+				continue
+			}
+			listed.Items = append(listed.Items, crd)
+		}
+		slog.Info("using cached resources", "crds", len(cached), "listed", len(listed.Items))
+	}
+
+	// get every custom resource
+	all, removed, err := findAll(ctx, listed, metadataClient, opts)
+	if listErrs := (ListErrors{}); errors.As(err, &listErrs) {
+		skipped = append(skipped, listErrs...)
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot find resources: %w", err)
+	}
+	// the kinds of CRDs deleted since they were listed are dropped from the
+	// graph as if they were never listed
+	scan.remove(removed)
+	listed.Items = slices.DeleteFunc(listed.Items, func(crd unstructured.Unstructured) bool {
+		return slices.Contains(removed, crd.GetName())
+	})
+	if opts.IncludeBuiltinChildren {
+		children, listErrs := findBuiltinChildren(ctx, metadataClient, scan.allGroups, opts)
+		skipped = append(skipped, listErrs...)
+		all = append(all, children...)
+	}
+
+	// some detectors read more than the metadata of a resource
+	objects, listErrs := findObjects(ctx, client, listed, scan.detectors, opts)
+	skipped = append(skipped, listErrs...)
+
+	if len(skipped) > 0 && !opts.BestEffort {
+		return nil, skipped.sorted()
+	}
+	for _, err := range skipped {
+		slog.Error("skipping resources", "resource", err.Resource, "error", err.Err)
+	}
+
+	// get all resources that depend on others
+	// as these are the ones that need to be restored in a specific order
+	_, buildSpan := tracer().Start(ctx, "BuildGraph", trace.WithAttributes(attribute.Int("resources", len(all))))
+	edges := []Edge{}
+	detected := map[schema.GroupKind][]Edge{}
+	scanned := make([]scannedResource, 0, len(all))
+	crossScope := map[types.UID][]types.UID{}
+	if !opts.IncludeCrossScopeOwners {
+		owners := slices.Clone(all)
+		for _, entry := range cached {
+			owners = append(owners, entry.Resources...)
+		}
+		crossScope = crossScopeRefs(findCrossScopeOwners(owners, scan.namespaced))
+	}
+	for _, res := range all {
+		found, err := detect(scan.detectors, res, objects)
+		if err != nil {
+			endSpan(buildSpan, err)
+			return nil, err
+		}
+		found = withoutCrossScope(found, res, crossScope)
+		kind := res.GroupVersionKind().GroupKind()
+		detected[kind] = append(detected[kind], found...)
+		edges = append(edges, found...)
+		scanned = append(scanned, scannedResource{meta: res, edges: found})
+	}
+	// the edges of a detector that failed are incomplete, so they are not cached
+	failed := detectorErrors(scan.detectors)
+	if len(failed) > 0 && !opts.BestEffort {
+		endSpan(buildSpan, failed)
+		return nil, failed.sorted()
+	}
+	for _, err := range failed {
+		slog.Error("skipping edges", "detector", err.Resource, "error", err.Err)
+	}
+	skipped = append(skipped, failed...)
+	if cacheKey != "" && len(failed) == 0 {
+		storeListed(opts.Cache, cacheKey, listed, skipped, all, detected)
+	}
+	for _, entry := range cached {
+		edges = append(edges, entry.Edges...)
+		all = append(all, entry.Resources...)
+	}
+
+	webhooks := webhookDependencies(ctx, client, scan.scanned.Items)
+	if opts.OperatorsFirst {
+		scan.operators = findOperators(ctx, client, scan.scanned.Items)
+	}
+	graph = scan.build(all, edges, webhooks)
+	if slices.ContainsFunc(scan.detectors, func(d DependencyDetector) bool { _, ok := d.(FluxDetector); return ok }) {
+		graph.FluxDependsOn = findFluxDependsOn(objects)
+	}
+	if len(skipped) > 0 {
+		graph.Skipped = skipped.sorted()
+	}
+	if opts.PerNamespace {
+		graph.Namespaces = scan.buildPerNamespace(scanned, webhooks)
+	}
+	buildSpan.End()
+	return graph, nil
+}
+
+// crdScan is what the CRDs of a cluster contribute to its graph: the kinds
+// whose resources are scanned and the detectors finding the edges between them
+type crdScan struct {
+	opts Options
+	// scanned are the CRDs whose resources are scanned
+	scanned    *unstructured.UnstructuredList
+	resources  map[schema.GroupKind]string
+	namespaced map[schema.GroupKind]bool
+	// allGroups are the groups of the scanned CRDs
+	allGroups []string
+	// served are the kinds of every CRD, including those left out of the scan
+	served map[schema.GroupKind]bool
+	// declared are the edges declared with the depends-on annotation of the CRDs
+	declared  []Edge
+	detectors []DependencyDetector
+	// known are the kinds a SpecRefDetector looks for
+	known []schema.GroupKind
+	// notEstablished are the CRDs left out of the scan as they are not established
+	notEstablished []string
+	// removed are the CRDs deleted since they were listed
+	removed []string
+	// invalid are the CRDs whose resource cannot be read from their spec
+	invalid ListErrors
+	// operators are the Deployments serving the scanned CRDs, when asked for
+	operators []Operator
+}
+
+// newCRDScan picks the CRDs whose resources are scanned with opts out of
+// crds, those that cannot be parsed are recorded as invalid and left out
+func newCRDScan(crds []unstructured.Unstructured, opts Options) *crdScan {
+	scan := &crdScan{
+		opts:       opts,
+		scanned:    &unstructured.UnstructuredList{},
+		resources:  map[schema.GroupKind]string{},
+		namespaced: map[schema.GroupKind]bool{},
+		served:     map[schema.GroupKind]bool{},
+	}
+	for _, crd := range crds {
+		res, namespaced, err := GetRes(crd)
+		if err != nil {
+			slog.Warn("skipping CRD that cannot be parsed", "crd", crd.GetName(), "error", err)
+			scan.invalid = append(scan.invalid, ListError{Resource: crd.GetName(), Err: err})
+			continue
+		}
+		scan.served[res.GroupKind()] = true
+		if !opts.includesCRD(crd, res) {
+			continue
+		}
+		if opts.RespectVeleroLabels && crd.GetLabels()[ExcludeFromBackupLabel] == "true" {
+			slog.Info("skipping CRD excluded from backups", "crd", crd.GetName())
+			continue
+		}
+		if ignored(&crd) {
+			slog.Info("skipping CRD ignored by annotation", "crd", crd.GetName())
+			continue
+		}
+		if !opts.Scope.includesResource(res, namespaced) {
+			continue
+		}
+		if reason, ok := notEstablished(crd); ok {
+			slog.Warn("skipping CRD that is not established", "crd", crd.GetName(), "reason", reason)
+			scan.notEstablished = append(scan.notEstablished, crd.GetName())
+			continue
+		}
+		scan.scanned.Items = append(scan.scanned.Items, crd)
+		scan.declared = append(scan.declared, dependsOn(res.GroupKind(), crd.GetAnnotations())...)
+
+		scan.resources[res.GroupKind()] = crd.GetName()
+		scan.namespaced[res.GroupKind()] = namespaced
+
+		// all groups contained in CRDs
+		scan.allGroups = append(scan.allGroups, res.GVR.GroupResource().Group)
+	}
+
+	scan.detectors = opts.Detectors
+	if len(scan.detectors) == 0 {
+		scan.detectors = DefaultDetectors
+	}
+	if opts.IncludeNonControllerOwners {
+		scan.detectors = slices.Clone(scan.detectors)
+		for i, d := range scan.detectors {
+			if _, ok := d.(OwnerReferenceDetector); ok {
+				scan.detectors[i] = OwnerReferenceDetector{IncludeNonController: true}
+			}
+		}
+	}
+	scan.known = slices.Concat(maps.Keys(scan.resources), builtinKinds())
+	if opts.InferSpecRefs {
+		scan.detectors = append(slices.Clip(scan.detectors), NewSpecRefDetector(scan.scanned.Items, scan.known))
+	}
+	return scan
+}
+
+// remove drops the named CRDs, deleted since they were listed, from the scan
+func (s *crdScan) remove(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	s.scanned.Items = slices.DeleteFunc(s.scanned.Items, func(crd unstructured.Unstructured) bool {
+		if !slices.Contains(names, crd.GetName()) {
+			return false
+		}
+		s.operators = slices.DeleteFunc(s.operators, func(op Operator) bool {
+			return op.Resource == crd.GetName()
+		})
+		if res, _, err := GetRes(crd); err == nil {
+			delete(s.resources, res.GroupKind())
+			delete(s.namespaced, res.GroupKind())
+			delete(s.served, res.GroupKind())
+		}
+		return true
+	})
+	s.removed = append(s.removed, names...)
+}
+
+// scannedResource is a scanned resource and the edges detected from it
+type scannedResource struct {
+	meta  v1.PartialObjectMetadata
+	edges []Edge
+}
+
+// buildPerNamespace builds the graph of every namespace holding scanned
+// resources from its resources and the cluster-scoped ones
+func (s *crdScan) buildPerNamespace(resources []scannedResource, webhooks []WebhookDependency) map[string]*Graph {
+	namespaces := map[string]bool{}
+	for _, res := range resources {
+		if res.meta.Namespace != "" {
+			namespaces[res.meta.Namespace] = true
+		}
+	}
+
+	graphs := map[string]*Graph{}
+	for namespace := range namespaces {
+		all := []v1.PartialObjectMetadata{}
+		edges := []Edge{}
+		for _, res := range resources {
+			if res.meta.Namespace == namespace || res.meta.Namespace == "" {
+				all = append(all, res.meta)
+				edges = append(edges, res.edges...)
+			}
+		}
+		graphs[namespace] = s.build(all, edges, webhooks)
+	}
+	return graphs
+}
+
+// build builds the graph of the scanned resources in all from the edges
+// detected between them and the resources depending on webhooks
+func (s *crdScan) build(all []v1.PartialObjectMetadata, edges []Edge, webhooks []WebhookDependency) *Graph {
+	graph := NewGraph()
+	graph.DefaultOrder = s.opts.DefaultOrder
+	graph.LowPriority = s.opts.LowPriority
+	graph.UnrelatedLowPriority = s.opts.UnrelatedLowPriority
+	graph.Qualify = s.opts.Qualify
+	graph.Tiebreak = s.opts.Tiebreak
+	graph.Compact = s.opts.Compact
+	graph.Minimal = s.opts.Minimal
+	graph.NotEstablished = slices.Clone(s.notEstablished)
+	graph.Removed = slices.Clone(s.removed)
+	maps.Copy(graph.Resources, s.resources)
+	maps.Copy(graph.Namespaced, s.namespaced)
+
+	for _, edge := range edges {
+		// if group is contained in allGroups, then it is a CRD
+		if slices.Contains(s.allGroups, edge.Owner.Group) {
+			// add every dependency to the graph so we can track them
+			if edge.Soft {
+				graph.AddSoftEdge(edge.Kind, edge.Owner, edge.Weight)
+			} else {
+				graph.AddEdge(edge.Kind, edge.Owner)
+			}
+			if s.opts.IncludeBuiltinChildren {
+				addBuiltinKind(graph, edge.Kind)
+			}
+		}
+	}
+
+	declared := slices.Clone(s.declared)
+	for _, res := range all {
+		kind := res.GroupVersionKind().GroupKind()
+		graph.Counts[kind]++
+		declared = append(declared, dependsOn(kind, res.GetAnnotations())...)
+		if s.opts.IncludeBuiltinChildren {
+			addBuiltinKind(graph, kind)
+		}
+	}
+	addDeclaredEdges(graph, declared)
+
+	if s.opts.SyncWaves {
+		recordSyncWaves(graph, all)
+		addSyncWaveEdges(graph)
+	}
+
+	addWebhookDependencies(graph, webhooks, s.opts.WebhooksFirst)
+	addOperators(graph, s.operators)
+
+	if s.opts.Hints != nil {
+		graph.ApplyHints(s.opts.Hints)
+	}
+
+	graph.Orphans = findOrphans(all, s.served, graph.Resources)
+	graph.CrossScope = findCrossScopeOwners(all, s.namespaced)
+	graph.Remapped = findRemappedOwners(all, graph.Resources)
+	return graph
+}
+
+// storeListed caches the resources of every listed CRD, other than those
+// that could not be listed, and the edges detected from them
+func storeListed(cache *Cache, key string, listed *unstructured.UnstructuredList, skipped ListErrors, all []v1.PartialObjectMetadata, detected map[schema.GroupKind][]Edge) {
+	resources := map[schema.GroupKind][]v1.PartialObjectMetadata{}
+	for _, res := range all {
+		kind := res.GroupVersionKind().GroupKind()
+		resources[kind] = append(resources[kind], res)
+	}
+	for _, crd := range listed.Items {
+		if slices.ContainsFunc(skipped, func(e ListError) bool { return e.Resource == crd.GetName() }) {
+			continue
+		}
+		res, _, err := GetRes(crd)
+		if err != nil {
+			continue
+		}
+		cache.store(crd, key, resources[res.GroupKind()], detected[res.GroupKind()])
+	}
+}
+
+// listCRDs lists every CRD in the cluster
+func listCRDs(ctx context.Context, client dynamic.Interface) (crds *unstructured.UnstructuredList, err error) {
+	ctx, span := tracer().Start(ctx, "ListCRDs")
+	defer func() { endSpan(span, err) }()
+
+	crds, err = client.Resource(CRDResource).List(ctx, v1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		slog.Info("CRDs are not served as v1, listing them as v1beta1")
+		crds, err = client.Resource(CRDResourceV1beta1).List(ctx, v1.ListOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot list CRDs: %w", err)
+	}
+	span.SetAttributes(attribute.Int("crds", len(crds.Items)))
+	return crds, nil
+}
+
+// Priorities returns the full restore-resource-priorities value,
+// the computed order added to the end of defaults
+func Priorities(defaults, computed []string) string {
+	return strings.Join(slices.Concat(defaults, computed), ",")
+}
+
+// ParseDefaultOrder parses a default order given as comma or newline
+// separated resources, lines starting with # are comments
+func ParseDefaultOrder(value string) []string {
+	order := []string{}
+	for _, line := range strings.Split(value, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, res := range strings.Split(line, ",") {
+			if res = strings.TrimSpace(res); res != "" {
+				order = append(order, res)
+			}
+		}
+	}
+	return order
+}
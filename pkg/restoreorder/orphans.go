@@ -0,0 +1,73 @@
+package restoreorder
+
+import (
+	"cmp"
+	"slices"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// reasons a resource is orphaned
+const (
+	// OrphanOwnerKindMissing is used when no CRD or built-in API serves the owner kind
+	OrphanOwnerKindMissing = "OwnerKindMissing"
+	// OrphanOwnerNotFound is used when the owner kind is scanned but the owner object was not found
+	OrphanOwnerNotFound = "OwnerNotFound"
+)
+
+// Orphan is a resource whose owner will not exist after a restore
+type Orphan struct {
+	Kind      schema.GroupKind
+	Namespace string
+	Name      string
+	Owner     v1.OwnerReference
+	Reason    string
+}
+
+// findOrphans returns the resources whose owner kind is not served by the
+// cluster (served holds the kinds of every CRD) or whose owner object was
+// not among the scanned resources
+func findOrphans(all []v1.PartialObjectMetadata, served map[schema.GroupKind]bool, scanned map[schema.GroupKind]string) []Orphan {
+	uids := map[types.UID]bool{}
+	for _, res := range all {
+		uids[res.UID] = true
+	}
+
+	orphans := []Orphan{}
+	for _, res := range all {
+		for _, ref := range res.GetOwnerReferences() {
+			gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+
+			reason := ""
+			switch {
+			case !served[gvk.GroupKind()] && !scheme.Scheme.Recognizes(gvk):
+				reason = OrphanOwnerKindMissing
+			case scanned[gvk.GroupKind()] != "" && !uids[ref.UID]:
+				reason = OrphanOwnerNotFound
+			default:
+				continue
+			}
+
+			orphans = append(orphans, Orphan{
+				Kind:      res.GroupVersionKind().GroupKind(),
+				Namespace: res.Namespace,
+				Name:      res.Name,
+				Owner:     ref,
+				Reason:    reason,
+			})
+		}
+	}
+
+	slices.SortFunc(orphans, func(a, b Orphan) int {
+		return cmp.Or(
+			cmp.Compare(a.Kind.String(), b.Kind.String()),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Owner.Name, b.Owner.Name),
+		)
+	})
+	return orphans
+}
@@ -0,0 +1,133 @@
+package restoreorder
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// the names of the policy engine detectors
+const (
+	Kyverno    = "kyverno"
+	Gatekeeper = "gatekeeper"
+)
+
+// the groups of the policy engine kinds
+const (
+	KyvernoGroup              = "kyverno.io"
+	GatekeeperTemplatesGroup  = "templates.gatekeeper.sh"
+	GatekeeperConstraintGroup = "constraints.gatekeeper.sh"
+)
+
+// the labels Kyverno sets on the resources generate rules create
+const (
+	KyvernoPolicyNameLabel      = "generate.kyverno.io/policy-name"
+	KyvernoPolicyNamespaceLabel = "generate.kyverno.io/policy-namespace"
+)
+
+func init() {
+	RegisterDetector(Kyverno, KyvernoDetector{})
+	RegisterDetector(Gatekeeper, GatekeeperDetector{})
+}
+
+// the policy engine kinds ordered by the detectors
+var (
+	kyvernoClusterPolicy         = schema.GroupKind{Group: KyvernoGroup, Kind: "ClusterPolicy"}
+	kyvernoPolicy                = schema.GroupKind{Group: KyvernoGroup, Kind: "Policy"}
+	gatekeeperConstraintTemplate = schema.GroupKind{Group: GatekeeperTemplatesGroup, Kind: "ConstraintTemplate"}
+)
+
+// KyvernoDetector orders the resources Kyverno generate rules create after
+// the ClusterPolicies and Policies generating them, so a restored policy
+// finds the resources it generated rather than racing to create them again.
+// the generated resources are found by the labels Kyverno sets on them and
+// by the kinds the generate rules of the policies name
+type KyvernoDetector struct{}
+
+// WantsObjects reports whether crd serves a Kyverno kind, whose rules are read
+func (KyvernoDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	return group == KyvernoGroup
+}
+
+// Detect returns the edge from a generated resource to the kind of its
+// policy, or the edges from the kinds a policy generates to its kind
+func (KyvernoDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	edges := []Edge{}
+	if _, ok := obj.GetLabels()[KyvernoPolicyNameLabel]; ok {
+		policy := kyvernoClusterPolicy
+		if obj.GetLabels()[KyvernoPolicyNamespaceLabel] != "" {
+			policy = kyvernoPolicy
+		}
+		if kind != policy {
+			edges = append(edges, Edge{Kind: kind, Owner: policy})
+		}
+	}
+	if kind != kyvernoClusterPolicy && kind != kyvernoPolicy {
+		return edges
+	}
+
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	for _, rule := range rules {
+		rule, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, generated := range generatedKinds(rule) {
+			if generated != kind {
+				edges = append(edges, Edge{Kind: generated, Owner: kind})
+			}
+		}
+	}
+	return edges
+}
+
+// generatedKinds returns the kinds the generate rule of a Kyverno rule creates
+func generatedKinds(rule map[string]interface{}) []schema.GroupKind {
+	generate, ok, _ := unstructured.NestedMap(rule, "generate")
+	if !ok {
+		return nil
+	}
+
+	kinds := []schema.GroupKind{}
+	targets := []map[string]interface{}{generate}
+	foreach, _, _ := unstructured.NestedSlice(generate, "foreach")
+	for _, target := range foreach {
+		if target, ok := target.(map[string]interface{}); ok {
+			targets = append(targets, target)
+		}
+	}
+	for _, target := range targets {
+		kind, _, _ := unstructured.NestedString(target, "kind")
+		apiVersion, _, _ := unstructured.NestedString(target, "apiVersion")
+		if kind != "" {
+			kinds = append(kinds, schema.FromAPIVersionAndKind(apiVersion, kind).GroupKind())
+		}
+	}
+	// clone lists name their kinds as apiVersion/kind, e.g. v1/Secret
+	cloned, _, _ := unstructured.NestedStringSlice(generate, "cloneList", "kinds")
+	for _, kind := range cloned {
+		i := strings.LastIndex(kind, "/")
+		if i < 0 {
+			continue
+		}
+		kinds = append(kinds, schema.FromAPIVersionAndKind(kind[:i], kind[i+1:]).GroupKind())
+	}
+	return kinds
+}
+
+// GatekeeperDetector orders Gatekeeper constraints after the
+// ConstraintTemplates they are instances of, which Gatekeeper serves their
+// kinds from
+type GatekeeperDetector struct{}
+
+// Detect returns the edge from a constraint to the ConstraintTemplate kind
+func (GatekeeperDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	if kind.Group != GatekeeperConstraintGroup {
+		return nil
+	}
+	return []Edge{{Kind: kind, Owner: gatekeeperConstraintTemplate}}
+}
@@ -0,0 +1,187 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestKyvernoDetector(t *testing.T) {
+	configMap := schema.GroupKind{Kind: "ConfigMap"}
+	secret := schema.GroupKind{Kind: "Secret"}
+	networkPolicy := schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}
+	role := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "Role"}
+	roleBinding := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}
+
+	testDetector(t, KyvernoDetector{}, []detectorTest{
+		{
+			name: "generated by a cluster policy",
+			manifest: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: zk-kafka-address
+  namespace: shop
+  labels:
+    generate.kyverno.io/policy-name: zk-kafka-address
+`,
+			want: []Edge{{Kind: configMap, Owner: kyvernoClusterPolicy}},
+		},
+		{
+			name: "generated by a policy",
+			manifest: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: zk-kafka-address
+  namespace: shop
+  labels:
+    generate.kyverno.io/policy-name: zk-kafka-address
+    generate.kyverno.io/policy-namespace: shop
+`,
+			want: []Edge{{Kind: configMap, Owner: kyvernoPolicy}},
+		},
+		{
+			name: "cluster policy",
+			manifest: `
+apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: add-networkpolicy
+spec:
+  rules:
+  - name: default-deny
+    match:
+      any:
+      - resources:
+          kinds: [Namespace]
+    generate:
+      apiVersion: networking.k8s.io/v1
+      kind: NetworkPolicy
+      name: default-deny
+      namespace: "{{request.object.metadata.name}}"
+  - name: validate-only
+    validate:
+      message: labels are required
+`,
+			want: []Edge{{Kind: networkPolicy, Owner: kyvernoClusterPolicy}},
+		},
+		{
+			name: "foreach",
+			manifest: `
+apiVersion: kyverno.io/v2beta1
+kind: Policy
+metadata:
+  name: tenant-rbac
+  namespace: shop
+spec:
+  rules:
+  - name: rbac
+    generate:
+      foreach:
+      - list: request.object.spec.teams
+        apiVersion: rbac.authorization.k8s.io/v1
+        kind: Role
+      - list: request.object.spec.teams
+        apiVersion: rbac.authorization.k8s.io/v1
+        kind: RoleBinding
+`,
+			want: []Edge{
+				{Kind: role, Owner: kyvernoPolicy},
+				{Kind: roleBinding, Owner: kyvernoPolicy},
+			},
+		},
+		{
+			// a kind without an apiVersion is not a clone list entry
+			name: "clone list",
+			manifest: `
+apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: sync-secrets
+spec:
+  rules:
+  - name: clone
+    generate:
+      namespace: "{{request.object.metadata.name}}"
+      cloneList:
+        namespace: default
+        kinds:
+        - v1/Secret
+        - v1/ConfigMap
+        - Role
+`,
+			want: []Edge{
+				{Kind: secret, Owner: kyvernoClusterPolicy},
+				{Kind: configMap, Owner: kyvernoClusterPolicy},
+			},
+		},
+		{
+			// a policy generating policies is not ordered after itself
+			name: "generated policy",
+			manifest: `
+apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: generated
+  labels:
+    generate.kyverno.io/policy-name: policy-factory
+spec:
+  rules:
+  - name: policies
+    generate:
+      apiVersion: kyverno.io/v1
+      kind: ClusterPolicy
+      name: child
+`,
+		},
+		{
+			name: "unlabeled",
+			manifest: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: shop
+`,
+		},
+	})
+}
+
+func TestGatekeeperDetector(t *testing.T) {
+	requiredLabels := schema.GroupKind{Group: GatekeeperConstraintGroup, Kind: "K8sRequiredLabels"}
+
+	testDetector(t, GatekeeperDetector{}, []detectorTest{
+		{
+			name: "constraint",
+			manifest: `
+apiVersion: constraints.gatekeeper.sh/v1beta1
+kind: K8sRequiredLabels
+metadata:
+  name: ns-must-have-owner
+spec:
+  match:
+    kinds:
+    - apiGroups: [""]
+      kinds: [Namespace]
+  parameters:
+    labels: [owner]
+`,
+			want: []Edge{{Kind: requiredLabels, Owner: gatekeeperConstraintTemplate}},
+		},
+		{
+			name: "constraint template",
+			manifest: `
+apiVersion: templates.gatekeeper.sh/v1
+kind: ConstraintTemplate
+metadata:
+  name: k8srequiredlabels
+spec:
+  crd:
+    spec:
+      names:
+        kind: K8sRequiredLabels
+`,
+		},
+	})
+}
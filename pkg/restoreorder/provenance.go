@@ -0,0 +1,86 @@
+package restoreorder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// the annotations recording the provenance of a written priorities value
+const (
+	ComputedAtAnnotation     = "whoisyourdaddy.io/computed-at"
+	ClusterAnnotation        = "whoisyourdaddy.io/cluster"
+	ToolVersionAnnotation    = "whoisyourdaddy.io/tool-version"
+	CRDCountAnnotation       = "whoisyourdaddy.io/crd-count"
+	PrioritiesHashAnnotation = "whoisyourdaddy.io/priorities-hash"
+)
+
+// Provenance records what produced a priorities value, so a value found in
+// a ConfigMap, file or Deployment can be told apart from a stale one
+type Provenance struct {
+	ComputedAt time.Time
+	// Cluster is the cluster or source the graph was discovered from
+	Cluster string
+	// Version is the version of the tool
+	Version string
+	// CRDs is the number of CRDs scanned
+	CRDs int
+	// Hash is the sha256 of the priorities value
+	Hash string
+}
+
+// NewProvenance returns the provenance of the priorities of graph, discovered from cluster
+func NewProvenance(graph *Graph, cluster string) Provenance {
+	sum := sha256.Sum256([]byte(graph.Priorities()))
+	return Provenance{
+		ComputedAt: time.Now().UTC(),
+		Cluster:    cluster,
+		Version:    Version(),
+		CRDs:       graph.Summary().CRDs,
+		Hash:       "sha256:" + hex.EncodeToString(sum[:]),
+	}
+}
+
+// Annotations returns the provenance as annotations
+func (p Provenance) Annotations() map[string]string {
+	return map[string]string{
+		ComputedAtAnnotation:     p.ComputedAt.Format(time.RFC3339),
+		ClusterAnnotation:        p.Cluster,
+		ToolVersionAnnotation:    p.Version,
+		CRDCountAnnotation:       strconv.Itoa(p.CRDs),
+		PrioritiesHashAnnotation: p.Hash,
+	}
+}
+
+// Comment returns the provenance as a YAML comment line
+func (p Provenance) Comment() string {
+	return fmt.Sprintf("# computed by whoisyourdaddyandwhatdoeshedo %s at %s from %d CRDs of %s, %s\n",
+		p.Version, p.ComputedAt.Format(time.RFC3339), p.CRDs, p.Cluster, p.Hash)
+}
+
+// Annotate records the provenance in the annotations of obj
+func (p Provenance) Annotate(obj *unstructured.Unstructured) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for k, v := range p.Annotations() {
+		annotations[k] = v
+	}
+	obj.SetAnnotations(annotations)
+}
+
+// Version returns the version of the tool the binary was built from,
+// (devel) when built from a source tree
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
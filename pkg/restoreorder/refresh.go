@@ -0,0 +1,71 @@
+package restoreorder
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// GraphSource provides long running modes with the latest graph, a Refresher
+// rediscovering it on an interval or a Watcher keeping it up to date
+type GraphSource interface {
+	// Latest returns the latest graph, nil when there is none yet, when it
+	// was last updated and the error of the last attempt to update it
+	Latest() (*Graph, time.Time, error)
+}
+
+// Refresher rediscovers the graph on an interval and keeps the latest result,
+// so long running modes can answer requests without scanning the cluster each time
+type Refresher struct {
+	// Discover builds a fresh graph
+	Discover func(context.Context) (*Graph, error)
+	// Interval is the time between discoveries
+	Interval time.Duration
+
+	mu      sync.RWMutex
+	graph   *Graph
+	updated time.Time
+	err     error
+}
+
+// Run discovers the graph immediately and then every interval until ctx is done
+func (r *Refresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Refresher) refresh(ctx context.Context) {
+	graph, err := r.Discover(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.err = err
+	if err != nil {
+		// keep serving the last good graph
+		slog.Error("cannot refresh graph", "error", err)
+		return
+	}
+	r.graph = graph
+	r.updated = time.Now()
+}
+
+// Latest returns the last successfully discovered graph, when it was
+// discovered and the error of the most recent discovery.
+// the graph is nil until the first discovery succeeds
+func (r *Refresher) Latest() (*Graph, time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.graph, r.updated, r.err
+}
@@ -0,0 +1,77 @@
+package restoreorder
+
+import (
+	"cmp"
+	"slices"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RemappedOwners is a scanned kind whose resources are owned by scanned, and
+// so backed up, resources. a restore recreates the owners with new UIDs while
+// the owner references keep the old ones, so the controllers of the kind have
+// to tolerate or re-adopt the restored resources
+type RemappedOwners struct {
+	// Resource is the CRD name of the kind
+	Resource string
+	// Count is the number of its resources owned by scanned resources
+	Count int
+	// Owners are the CRD names of the kinds of the scanned owners
+	Owners []string
+}
+
+// findRemappedOwners returns every kind of all with resources owned by
+// other resources of all, resources names the scanned kinds
+func findRemappedOwners(all []v1.PartialObjectMetadata, resources map[schema.GroupKind]string) []RemappedOwners {
+	uids := map[types.UID]bool{}
+	for _, res := range all {
+		uids[res.UID] = true
+	}
+
+	owned := []RemappedOwners{}
+	for _, res := range all {
+		resource, ok := resources[res.GroupVersionKind().GroupKind()]
+		if !ok {
+			continue
+		}
+		r := RemappedOwners{Resource: resource, Count: 1}
+		for _, ref := range res.GetOwnerReferences() {
+			owner, ok := resources[schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()]
+			if ok && ref.UID != "" && uids[ref.UID] {
+				r.Owners = append(r.Owners, owner)
+			}
+		}
+		if len(r.Owners) > 0 {
+			owned = append(owned, r)
+		}
+	}
+	return mergeRemappedOwners(owned)
+}
+
+// mergeRemappedOwners merges the entries of the same resource, summing their counts
+func mergeRemappedOwners(remapped []RemappedOwners) []RemappedOwners {
+	merged := map[string]*RemappedOwners{}
+	for _, r := range remapped {
+		if merged[r.Resource] == nil {
+			merged[r.Resource] = &RemappedOwners{Resource: r.Resource}
+		}
+		merged[r.Resource].Count += r.Count
+		for _, owner := range r.Owners {
+			if !slices.Contains(merged[r.Resource].Owners, owner) {
+				merged[r.Resource].Owners = append(merged[r.Resource].Owners, owner)
+			}
+		}
+	}
+
+	found := []RemappedOwners{}
+	for _, r := range merged {
+		slices.Sort(r.Owners)
+		found = append(found, *r)
+	}
+	slices.SortFunc(found, func(a, b RemappedOwners) int {
+		return cmp.Compare(a.Resource, b.Resource)
+	})
+	return found
+}
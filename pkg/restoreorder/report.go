@@ -0,0 +1,222 @@
+package restoreorder
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// the codes of report entries, stable so pipelines can enforce policies on them
+const (
+	// ReportNotEstablished is a CRD left out as it is not established or being deleted
+	ReportNotEstablished = "crd-not-established"
+	// ReportRemoved is a CRD deleted while it was scanned
+	ReportRemoved = "crd-removed"
+	// ReportListForbidden is a resource RBAC did not allow to be listed
+	ReportListForbidden = "list-forbidden"
+	// ReportListFailed is a resource that could not be listed for another reason
+	ReportListFailed = "list-failed"
+	// ReportDetectorFailed is a detector that failed, the edges it found are incomplete
+	ReportDetectorFailed = "detector-failed"
+	// ReportOwnerKindMissing is a resource owned by a kind the cluster does not serve
+	ReportOwnerKindMissing = "orphan-owner-kind-missing"
+	// ReportOwnerNotFound is a resource whose owner was not among the scanned resources
+	ReportOwnerNotFound = "orphan-owner-not-found"
+	// ReportCrossScope is an owner reference crossing a scope boundary
+	ReportCrossScope = "cross-scope-owner"
+	// ReportCycle is a set of kinds owning each other, the order breaks the cycle arbitrarily
+	ReportCycle = "ownership-cycle"
+	// ReportAmbiguous is a scanned resource whose plural is served by several groups
+	ReportAmbiguous = "ambiguous-plural"
+	// ReportFluxDependsOnMissing is a Flux object depending on an object missing from the scan
+	ReportFluxDependsOnMissing = "flux-depends-on-missing"
+)
+
+// Report is every problem encountered building a graph
+type Report struct {
+	Entries []ReportEntry `json:"entries"`
+	// Counts are the number of entries of every code
+	Counts map[string]int `json:"counts"`
+}
+
+// ReportEntry is a problem encountered building a graph
+type ReportEntry struct {
+	Code string `json:"code"`
+	// Resource is the CRD name or resource.group the entry is about
+	Resource string `json:"resource,omitempty"`
+	// Object is the [namespace/]name of the resource the entry is about
+	Object string `json:"object,omitempty"`
+	// Owner is the owner of Object, as Kind.group/[namespace/]name
+	Owner string `json:"owner,omitempty"`
+	// Related are the other resources involved: the kinds of a cycle, the
+	// resources an ambiguous plural could mean or the [namespace/]name of
+	// the object a Flux object depends on
+	Related []string `json:"related,omitempty"`
+	Message string   `json:"message"`
+}
+
+// Report returns every CRD skipped, list error, orphaned or cross-scope
+// owner reference, missing Flux dependency, ownership cycle and ambiguous
+// plural of g
+func (g *Graph) Report() Report {
+	entries := []ReportEntry{}
+	for _, name := range g.NotEstablished {
+		entries = append(entries, ReportEntry{Code: ReportNotEstablished, Resource: name, Message: "CRD is not established or is being deleted"})
+	}
+	for _, name := range g.Removed {
+		entries = append(entries, ReportEntry{Code: ReportRemoved, Resource: name, Message: "CRD was deleted during the scan"})
+	}
+	for _, err := range g.Skipped {
+		code := ReportListFailed
+		switch {
+		case apierrors.IsForbidden(err.Err):
+			code = ReportListForbidden
+		case errors.Is(err.Err, errDetectorFailed):
+			code = ReportDetectorFailed
+		}
+		entries = append(entries, ReportEntry{Code: code, Resource: err.Resource, Message: err.Err.Error()})
+	}
+	for _, o := range g.Orphans {
+		entry := ReportEntry{
+			Code:     ReportOwnerNotFound,
+			Resource: g.Name(o.Kind),
+			Object:   objectName(o.Namespace, o.Name),
+			Owner:    ownerName(o.Owner.APIVersion, o.Owner.Kind, "", o.Owner.Name),
+			Message:  "owner was not found, the resource will dangle after a restore",
+		}
+		if o.Reason == OrphanOwnerKindMissing {
+			entry.Code = ReportOwnerKindMissing
+			entry.Message = "owner kind is not served by the cluster, the resource will dangle after a restore"
+		}
+		entries = append(entries, entry)
+	}
+	for _, c := range g.CrossScope {
+		entries = append(entries, ReportEntry{
+			Code:     ReportCrossScope,
+			Resource: g.Name(c.Kind),
+			Object:   objectName(c.Namespace, c.Name),
+			Owner:    ownerName(c.Owner.APIVersion, c.Owner.Kind, c.OwnerNamespace, c.Owner.Name),
+			Message:  "owner reference crosses a scope boundary and is ignored by the garbage collector",
+		})
+	}
+	for _, dep := range g.FluxDependsOn {
+		entries = append(entries, ReportEntry{
+			Code:     ReportFluxDependsOnMissing,
+			Resource: g.Name(dep.Kind),
+			Object:   objectName(dep.Namespace, dep.Name),
+			Related:  []string{dep.DependsOn},
+			Message:  "spec.dependsOn names an object missing from the scan, Flux will not reconcile the object after a restore",
+		})
+	}
+	for _, cycle := range g.Cycles() {
+		names := make([]string, 0, len(cycle))
+		for _, kind := range cycle {
+			names = append(names, g.Name(kind))
+		}
+		entries = append(entries, ReportEntry{Code: ReportCycle, Resource: names[0], Related: names, Message: "kinds own each other, the cycle is broken at an arbitrary kind"})
+	}
+	for name, candidates := range g.ambiguous() {
+		entries = append(entries, ReportEntry{Code: ReportAmbiguous, Resource: name, Related: candidates, Message: "plural is served by several groups, an entry without the group is ambiguous"})
+	}
+
+	slices.SortStableFunc(entries, func(a, b ReportEntry) int {
+		return cmp.Or(cmp.Compare(a.Code, b.Code), cmp.Compare(a.Resource, b.Resource))
+	})
+	counts := map[string]int{}
+	for _, entry := range entries {
+		counts[entry.Code]++
+	}
+	return Report{Entries: entries, Counts: counts}
+}
+
+// Cycles returns the sets of two or more kinds owning each other, each
+// sorted by name. a kind owning itself is not a cycle, its resources are
+// restored together
+func (g *Graph) Cycles() [][]schema.GroupKind {
+	// the owners every kind reaches, directly or through other owners
+	reached := map[schema.GroupKind]map[schema.GroupKind]bool{}
+	for _, kind := range sortedKinds(g.Owners) {
+		reached[kind] = map[schema.GroupKind]bool{}
+		queue := []schema.GroupKind{kind}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for owner := range g.Owners[current] {
+				if !reached[kind][owner] {
+					reached[kind][owner] = true
+					queue = append(queue, owner)
+				}
+			}
+		}
+	}
+
+	cycles := [][]schema.GroupKind{}
+	seen := map[schema.GroupKind]bool{}
+	for _, kind := range sortedKinds(g.Owners) {
+		if seen[kind] {
+			continue
+		}
+		cycle := []schema.GroupKind{kind}
+		for owner := range reached[kind] {
+			if owner != kind && reached[owner][kind] {
+				cycle = append(cycle, owner)
+				seen[owner] = true
+			}
+		}
+		if len(cycle) > 1 {
+			slices.SortFunc(cycle, func(a, b schema.GroupKind) int {
+				return cmp.Compare(g.Name(a), g.Name(b))
+			})
+			cycles = append(cycles, cycle)
+		}
+	}
+	return cycles
+}
+
+// ambiguous returns the scanned resources whose plural another scanned
+// resource or an entry of the default order of another group has, with
+// every resource.group it could mean
+func (g *Graph) ambiguous() map[string][]string {
+	defaults := g.DefaultOrder
+	if defaults == nil {
+		defaults = DefaultOrder
+	}
+
+	candidates := map[string][]string{}
+	for _, name := range slices.Concat(maps.Values(g.Resources), ParseDefaultOrder(strings.Join(defaults, ","))) {
+		gr := schema.ParseGroupResource(name)
+		if !slices.Contains(candidates[gr.Resource], name) {
+			candidates[gr.Resource] = append(candidates[gr.Resource], name)
+		}
+	}
+
+	ambiguous := map[string][]string{}
+	for _, name := range g.Resources {
+		if found := candidates[schema.ParseGroupResource(name).Resource]; len(found) > 1 {
+			found = slices.Clone(found)
+			slices.Sort(found)
+			ambiguous[name] = found
+		}
+	}
+	return ambiguous
+}
+
+// objectName returns namespace/name, or name for cluster-scoped resources
+func objectName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// ownerName returns an owner as Kind.group/[namespace/]name
+func ownerName(apiVersion, kind, namespace, name string) string {
+	gk := schema.FromAPIVersionAndKind(apiVersion, kind).GroupKind()
+	return fmt.Sprintf("%s/%s", gk, objectName(namespace, name))
+}
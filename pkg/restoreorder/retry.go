@@ -0,0 +1,80 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// defaultListBackoff is the backoff between the attempts of a list call
+// that failed with a transient error unless Options.ListBackoff says otherwise
+var defaultListBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    6,
+	Cap:      30 * time.Second,
+}
+
+// listBackoff returns the backoff between the attempts of a failed list call
+func (o Options) listBackoff() wait.Backoff {
+	if o.ListBackoff.Steps <= 0 {
+		return defaultListBackoff
+	}
+	return o.ListBackoff
+}
+
+// retryable reports whether err is worth retrying. errors the REST client
+// already retried are not, so a throttled server is not sent its retries
+// once more for every step of the backoff
+func retryable(err error) bool {
+	if retriedByClient(err) {
+		return false
+	}
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// retriedByClient reports whether the REST client retried err itself, as
+// it does for throttling errors and for the server errors with a
+// Retry-After header, up to 10 times
+func retriedByClient(err error) bool {
+	if apierrors.IsTooManyRequests(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+	return status.Status().Details.RetryAfterSeconds > 0
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not
+// retryable or backoff runs out
+func withRetry(ctx context.Context, backoff wait.Backoff, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil || !retryable(err) || backoff.Steps <= 1 {
+			return err
+		}
+
+		delay := backoff.Step()
+		slog.Warn("retrying failed list", "error", err, "after", delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+func TestWithRetry(t *testing.T) {
+	unavailable := apierrors.NewServiceUnavailable("down")
+	throttled := apierrors.NewTooManyRequests("slow down", 1)
+	retryAfter := apierrors.NewServerTimeout(schema.GroupResource{Group: "x.io", Resource: "apps"}, "list", 1)
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Group: "x.io", Resource: "apps"}, "", errors.New("denied"))
+
+	tests := []struct {
+		name         string
+		steps        int
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "succeeds", steps: 3, wantAttempts: 1},
+		{name: "retries", steps: 3, errs: []error{unavailable, unavailable}, wantAttempts: 3},
+		{name: "runs out", steps: 3, errs: []error{unavailable, unavailable, unavailable, unavailable}, wantAttempts: 3, wantErr: unavailable},
+		{name: "single attempt", steps: 1, errs: []error{unavailable}, wantAttempts: 1, wantErr: unavailable},
+		// the REST client has retried these already
+		{name: "throttled", steps: 3, errs: []error{throttled}, wantAttempts: 1, wantErr: throttled},
+		{name: "retry after", steps: 3, errs: []error{retryAfter}, wantAttempts: 1, wantErr: retryAfter},
+		{name: "not retryable", steps: 3, errs: []error{forbidden}, wantAttempts: 1, wantErr: forbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backoff := Options{ListBackoff: wait.Backoff{Duration: time.Millisecond, Steps: tt.steps}}.listBackoff()
+			attempts := 0
+			err := withRetry(context.Background(), backoff, func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestListBackoff(t *testing.T) {
+	if got := (Options{}).listBackoff(); got != defaultListBackoff {
+		t.Errorf("got backoff %+v, want the default %+v", got, defaultListBackoff)
+	}
+}
+
+func TestListPagesRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		// wantAttempts counts the requests the server sees, client-go's own
+		// retries included
+		wantAttempts int
+	}{
+		// client-go makes 1 attempt and retries 10 times, and the backoff adds none
+		{name: "throttled", status: http.StatusTooManyRequests, retryAfter: "0", wantAttempts: 11},
+		{name: "unavailable", status: http.StatusServiceUnavailable, wantAttempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client, err := metadata.NewForConfig(&rest.Config{Host: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			list := client.Resource(schema.GroupVersionResource{Group: "x.io", Version: "v1", Resource: "apps"}).Namespace("").List
+			backoff := wait.Backoff{Duration: time.Millisecond, Steps: 3}
+			if _, err := listPages(context.Background(), list, v1.ListOptions{}, backoff); err == nil {
+				t.Fatal("got no error, want the server's")
+			}
+			if got := int(attempts.Load()); got != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package restoreorder
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// BackupResource and ScheduleResource are the velero resources a Scope can be read from
+var (
+	BackupResource = schema.GroupVersionResource{
+		Group:    "velero.io",
+		Version:  "v1",
+		Resource: "backups",
+	}
+	ScheduleResource = schema.GroupVersionResource{
+		Group:    "velero.io",
+		Version:  "v1",
+		Resource: "schedules",
+	}
+)
+
+// Scope restricts discovery to the resources a velero backup would contain.
+// the zero value includes everything
+type Scope struct {
+	// IncludedNamespaces and ExcludedNamespaces may contain globs,
+	// an empty include list or "*" includes every namespace
+	IncludedNamespaces []string
+	ExcludedNamespaces []string
+	// IncludedResources and ExcludedResources are resource names in the
+	// form velero accepts (e.g. nodegroups or nodegroups.eks.example.com),
+	// an empty include list or "*" includes every resource
+	IncludedResources []string
+	ExcludedResources []string
+	// LabelSelector is passed to every list call
+	LabelSelector string
+	// IncludeClusterResources controls whether cluster scoped resources are scanned,
+	// when nil they are only scanned when every namespace is included
+	IncludeClusterResources *bool
+}
+
+// backupSpec is the part of the velero BackupSpec a Scope is read from
+type backupSpec struct {
+	IncludedNamespaces      []string           `json:"includedNamespaces,omitempty"`
+	ExcludedNamespaces      []string           `json:"excludedNamespaces,omitempty"`
+	IncludedResources       []string           `json:"includedResources,omitempty"`
+	ExcludedResources       []string           `json:"excludedResources,omitempty"`
+	LabelSelector           *v1.LabelSelector  `json:"labelSelector,omitempty"`
+	OrLabelSelectors        []v1.LabelSelector `json:"orLabelSelectors,omitempty"`
+	IncludeClusterResources *bool              `json:"includeClusterResources,omitempty"`
+}
+
+// ScopeForBackup reads the scope of the named velero Backup
+func ScopeForBackup(ctx context.Context, client dynamic.Interface, namespace, name string) (Scope, error) {
+	backup, err := client.Resource(BackupResource).Namespace(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return Scope{}, fmt.Errorf("cannot get backup %s/%s: %w", namespace, name, err)
+	}
+	return scopeFromSpec(backup, "spec")
+}
+
+// ScopeForSchedule reads the scope of the backups the named velero Schedule creates
+func ScopeForSchedule(ctx context.Context, client dynamic.Interface, namespace, name string) (Scope, error) {
+	schedule, err := client.Resource(ScheduleResource).Namespace(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return Scope{}, fmt.Errorf("cannot get schedule %s/%s: %w", namespace, name, err)
+	}
+	return scopeFromSpec(schedule, "spec", "template")
+}
+
+func scopeFromSpec(obj *unstructured.Unstructured, fields ...string) (Scope, error) {
+	raw, _, err := unstructured.NestedMap(obj.Object, fields...)
+	if err != nil {
+		return Scope{}, fmt.Errorf("cannot read %s of %s: %w", strings.Join(fields, "."), obj.GetName(), err)
+	}
+
+	spec := backupSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &spec); err != nil {
+		return Scope{}, fmt.Errorf("cannot read %s of %s: %w", strings.Join(fields, "."), obj.GetName(), err)
+	}
+
+	scope := Scope{
+		IncludedNamespaces:      spec.IncludedNamespaces,
+		ExcludedNamespaces:      spec.ExcludedNamespaces,
+		IncludedResources:       spec.IncludedResources,
+		ExcludedResources:       spec.ExcludedResources,
+		IncludeClusterResources: spec.IncludeClusterResources,
+	}
+	if spec.LabelSelector != nil {
+		selector, err := v1.LabelSelectorAsSelector(spec.LabelSelector)
+		if err != nil {
+			return Scope{}, fmt.Errorf("invalid label selector of %s: %w", obj.GetName(), err)
+		}
+		scope.LabelSelector = selector.String()
+	}
+	if len(spec.OrLabelSelectors) > 0 {
+		slog.Warn("orLabelSelectors cannot be expressed as a single selector and are ignored", "name", obj.GetName())
+	}
+	return scope, nil
+}
+
+// allNamespaces reports whether every namespace is included
+func (s Scope) allNamespaces() bool {
+	return (len(s.IncludedNamespaces) == 0 || slices.Contains(s.IncludedNamespaces, "*")) && len(s.ExcludedNamespaces) == 0
+}
+
+// includesNamespace reports whether resources in namespace are in scope
+func (s Scope) includesNamespace(namespace string) bool {
+	if matchesAny(s.ExcludedNamespaces, namespace) {
+		return false
+	}
+	return len(s.IncludedNamespaces) == 0 || matchesAny(s.IncludedNamespaces, namespace)
+}
+
+// includesResource reports whether the resources served by a CRD are in scope
+func (s Scope) includesResource(res GVK, namespaced bool) bool {
+	if !namespaced {
+		if s.IncludeClusterResources != nil && !*s.IncludeClusterResources {
+			return false
+		}
+		if s.IncludeClusterResources == nil && !s.allNamespaces() {
+			return false
+		}
+	}
+
+	names := []string{res.GVR.Resource, res.GVR.GroupResource().String(), strings.ToLower(res.Kind)}
+	for _, name := range names {
+		if slices.Contains(s.ExcludedResources, name) {
+			return false
+		}
+	}
+	if len(s.IncludedResources) == 0 || slices.Contains(s.IncludedResources, "*") {
+		return true
+	}
+	for _, name := range names {
+		if slices.Contains(s.IncludedResources, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether value matches any of the glob patterns
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
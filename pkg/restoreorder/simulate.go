@@ -0,0 +1,198 @@
+package restoreorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// DownloadRequestResource is the velero resource backup contents are downloaded through
+var DownloadRequestResource = schema.GroupVersionResource{
+	Group:    "velero.io",
+	Version:  "v1",
+	Resource: "downloadrequests",
+}
+
+// BackupResourceList is the list of the objects in a backup velero stores
+// alongside it, mapping group/version/Kind (v1/Kind for core kinds) to the
+// namespace/name (name for cluster scoped objects) of every object of that kind
+type BackupResourceList map[string][]string
+
+// ReadBackupResourceList reads a resource list, gzipped as velero stores it or plain JSON
+func ReadBackupResourceList(r io.Reader) (BackupResourceList, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read resource list: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	list := BackupResourceList{}
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, fmt.Errorf("cannot read resource list: %w", err)
+	}
+	return list, nil
+}
+
+// DownloadBackupResourceList fetches the resource list of the named backup
+// the way the velero CLI does, through a DownloadRequest for a signed URL
+// to the backup storage location
+func DownloadBackupResourceList(ctx context.Context, client dynamic.Interface, namespace, backup string) (BackupResourceList, error) {
+	requests := client.Resource(DownloadRequestResource).Namespace(namespace)
+	request := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": DownloadRequestResource.GroupVersion().String(),
+		"kind":       "DownloadRequest",
+		"metadata": map[string]interface{}{
+			"generateName": backup + "-",
+			"namespace":    namespace,
+		},
+		"spec": map[string]interface{}{
+			"target": map[string]interface{}{
+				"kind": "BackupResourceList",
+				"name": backup,
+			},
+		},
+	}}
+	request, err := requests.Create(ctx, request, v1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create download request for backup %s/%s: %w", namespace, backup, err)
+	}
+	defer func() {
+		if err := requests.Delete(context.WithoutCancel(ctx), request.GetName(), v1.DeleteOptions{}); err != nil {
+			slog.Warn("cannot delete download request", "name", request.GetName(), "error", err)
+		}
+	}()
+
+	url := ""
+	err = wait.PollUntilContextTimeout(ctx, time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		current, err := requests.Get(ctx, request.GetName(), v1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		url, _, _ = unstructured.NestedString(current.Object, "status", "downloadURL")
+		return url != "", nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get download URL for backup %s/%s: %w", namespace, backup, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download resource list of backup %s/%s: %w", namespace, backup, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download resource list of backup %s/%s: %w", namespace, backup, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot download resource list of backup %s/%s: %s", namespace, backup, resp.Status)
+	}
+	return ReadBackupResourceList(resp.Body)
+}
+
+// Objects returns the objects of the list by the resource names velero
+// prioritizes them by, e.g. deployments.apps, using mapper to find the resource
+// of every kind. kinds mapper does not know, e.g. of CRDs removed since the
+// backup, are guessed from their kind
+func (l BackupResourceList) Objects(mapper meta.RESTMapper) map[string][]string {
+	objects := map[string][]string{}
+	for key, items := range l {
+		gvk := parseListKey(key)
+		resource := ""
+		if mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			resource = mapping.Resource.GroupResource().String()
+		} else {
+			plural, _ := meta.UnsafeGuessKindToResource(gvk)
+			resource = plural.GroupResource().String()
+			slog.Warn("cannot find resource of kind, guessing it", "kind", key, "resource", resource)
+		}
+		objects[resource] = append(objects[resource], items...)
+	}
+	return objects
+}
+
+// parseListKey parses a group/version/Kind or version/Kind key of a BackupResourceList
+func parseListKey(key string) schema.GroupVersionKind {
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return schema.GroupVersionKind{Kind: key}
+	}
+	return schema.FromAPIVersionAndKind(key[:i], key[i+1:])
+}
+
+// RestoreStep is an object of a backup at the position velero restores it
+type RestoreStep struct {
+	// Resource is the resource name of the object, e.g. deployments.apps
+	Resource string
+	// Object is the namespace/name of the object, its name when cluster scoped
+	Object string
+	// BeforeOwners are the resources owning Resource that are restored after
+	// it. the resource list of a backup holds no owner references, so they are
+	// recorded for every object of Resource, whether it has an owner or not
+	BeforeOwners []string
+}
+
+// SimulateRestore returns the objects in the order velero restores them with
+// priorities: the listed resources in order, then every other resource
+// alphabetically and last the low priority resources listed after "-".
+// every object of a resource the graph finds restored before one of its
+// owners records those owners: the graph orders resources, not objects, so
+// the objects of that resource that own nothing, or whose owners are not in
+// the backup, are marked too
+func (g *Graph) SimulateRestore(priorities []string, objects map[string][]string) []RestoreStep {
+	before := map[string][]string{}
+	for _, v := range g.Validate(priorities) {
+		before[v.Resource] = append(before[v.Resource], v.Owner)
+	}
+
+	resources := make([]string, 0, len(objects))
+	for resource := range objects {
+		resources = append(resources, resource)
+	}
+	rank := func(resource string) (int, int) {
+		pos := slices.Index(priorities, resource)
+		return restoreRank(priorities, pos), pos
+	}
+	slices.SortFunc(resources, func(a, b string) int {
+		rankA, posA := rank(a)
+		rankB, posB := rank(b)
+		if rankA != rankB {
+			return rankA - rankB
+		}
+		if posA != posB {
+			return posA - posB
+		}
+		return strings.Compare(a, b)
+	})
+
+	steps := []RestoreStep{}
+	for _, resource := range resources {
+		items := slices.Clone(objects[resource])
+		slices.Sort(items)
+		for _, item := range slices.Compact(items) {
+			steps = append(steps, RestoreStep{Resource: resource, Object: item, BeforeOwners: before[resource]})
+		}
+	}
+	return steps
+}
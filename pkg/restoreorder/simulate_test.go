@@ -0,0 +1,148 @@
+package restoreorder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseListKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want schema.GroupVersionKind
+	}{
+		{key: "v1/Pod", want: schema.GroupVersionKind{Version: "v1", Kind: "Pod"}},
+		{key: "apps/v1/Deployment", want: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+		{key: "infra.example.io/v1alpha1/Cluster", want: schema.GroupVersionKind{Group: "infra.example.io", Version: "v1alpha1", Kind: "Cluster"}},
+		{key: "Pod", want: schema.GroupVersionKind{Kind: "Pod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := parseListKey(tt.key); got != tt.want {
+				t.Errorf("parseListKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBackupResourceList(t *testing.T) {
+	const list = `{"v1/Pod": ["shop/web-1"], "infra.example.io/v1/Cluster": ["main"]}`
+	gzipped := &bytes.Buffer{}
+	gz := gzip.NewWriter(gzipped)
+	if _, err := io.WriteString(gz, list); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr bool
+	}{
+		{name: "plain", content: []byte(list)},
+		{name: "gzip", content: gzipped.Bytes()},
+		{name: "truncated gzip", content: gzipped.Bytes()[:12], wantErr: true},
+		{name: "not json", content: []byte("v1/Pod: shop/web-1"), wantErr: true},
+	}
+	want := BackupResourceList{"v1/Pod": {"shop/web-1"}, "infra.example.io/v1/Cluster": {"main"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadBackupResourceList(bytes.NewReader(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !maps.EqualFunc(got, want, slices.Equal) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSimulateRestore(t *testing.T) {
+	cluster := schema.GroupKind{Group: "example.io", Kind: "Cluster"}
+	database := schema.GroupKind{Group: "example.io", Kind: "Database"}
+	graph := NewGraph()
+	graph.AddEdge(database, cluster)
+	graph.Resources[cluster] = "clusters.example.io"
+	graph.Resources[database] = "databases.example.io"
+
+	objects := map[string][]string{
+		"clusters.example.io":  {"shop/main"},
+		"databases.example.io": {"shop/orders", "shop/carts", "shop/orders"},
+		"pods":                 {"shop/web-1"},
+		"configmaps":           {"shop/settings"},
+		"secrets":              {"shop/credentials"},
+	}
+
+	tests := []struct {
+		name       string
+		priorities string
+		want       []string
+	}{
+		{
+			// unlisted resources are restored alphabetically, after the listed
+			// ones and before the low priority ones
+			name:       "owners first",
+			priorities: "clusters.example.io,-,configmaps",
+			want: []string{
+				"clusters.example.io shop/main",
+				"databases.example.io shop/carts",
+				"databases.example.io shop/orders",
+				"pods shop/web-1",
+				"secrets shop/credentials",
+				"configmaps shop/settings",
+			},
+		},
+		{
+			// every object of the databases is marked, the graph orders resources
+			name:       "owned first",
+			priorities: "secrets,databases.example.io,clusters.example.io",
+			want: []string{
+				"secrets shop/credentials",
+				"databases.example.io shop/carts before clusters.example.io",
+				"databases.example.io shop/orders before clusters.example.io",
+				"clusters.example.io shop/main",
+				"configmaps shop/settings",
+				"pods shop/web-1",
+			},
+		},
+		{
+			name:       "owner low priority",
+			priorities: "pods,-,clusters.example.io",
+			want: []string{
+				"pods shop/web-1",
+				"configmaps shop/settings",
+				"databases.example.io shop/carts before clusters.example.io",
+				"databases.example.io shop/orders before clusters.example.io",
+				"secrets shop/credentials",
+				"clusters.example.io shop/main",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, step := range graph.SimulateRestore(ParsePriorities(tt.priorities), objects) {
+				line := step.Resource + " " + step.Object
+				if len(step.BeforeOwners) > 0 {
+					line += " before " + strings.Join(step.BeforeOwners, ",")
+				}
+				got = append(got, line)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got steps\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
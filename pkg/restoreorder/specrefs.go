@@ -0,0 +1,171 @@
+package restoreorder
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// specRefSuffixes are the field name suffixes that mark a reference to another
+// object, e.g. secretRef, configMapRef, clusterName
+var specRefSuffixes = []string{"Refs", "Ref", "Names", "Name"}
+
+// SpecRefDetector infers dependencies from the fields of custom resources
+// named after the kind they reference, found in the OpenAPI schemas of their
+// CRDs, e.g. spec.clusterName orders the resource after Clusters
+type SpecRefDetector struct {
+	// fields maps every kind to the paths of its reference fields and the kind they name
+	fields map[schema.GroupKind][]specRef
+}
+
+// specRef is a reference field in a schema
+type specRef struct {
+	// path to the field, "[]" stands for the items of an array
+	path []string
+	// kind named by the field, empty if the referenced object carries its kind
+	kind schema.GroupKind
+}
+
+// NewSpecRefDetector returns a detector for the reference fields of the
+// resources served by crds, naming kinds in known
+func NewSpecRefDetector(crds []unstructured.Unstructured, known []schema.GroupKind) *SpecRefDetector {
+	d := &SpecRefDetector{fields: map[schema.GroupKind][]specRef{}}
+	for _, crd := range crds {
+		res, _, err := GetRes(crd)
+		if err != nil {
+			continue
+		}
+		versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		for _, v := range versions {
+			v, ok := v.(map[string]interface{})
+			if !ok || v["name"] != res.GVR.Version {
+				continue
+			}
+			spec, _, _ := unstructured.NestedMap(v, "schema", "openAPIV3Schema", "properties", "spec")
+			d.fields[res.GroupKind()] = schemaRefs(spec, []string{"spec"}, res.GroupKind(), known)
+		}
+	}
+	return d
+}
+
+// schemaRefs walks the schema of the object at path and returns its reference fields
+func schemaRefs(s map[string]interface{}, path []string, self schema.GroupKind, known []schema.GroupKind) []specRef {
+	refs := []specRef{}
+	if items, ok := s["items"].(map[string]interface{}); ok {
+		refs = append(refs, schemaRefs(items, append(copyPath(path), "[]"), self, known)...)
+	}
+	props, _ := s["properties"].(map[string]interface{})
+	for name, prop := range props {
+		prop, ok := prop.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		field := append(copyPath(path), name)
+
+		// a typed reference carries the kind it references
+		if fields, ok := prop["properties"].(map[string]interface{}); ok && fields["kind"] != nil && fields["name"] != nil {
+			refs = append(refs, specRef{path: field})
+			continue
+		}
+		if kind, ok := refKind(name, self, known); ok {
+			refs = append(refs, specRef{path: field, kind: kind})
+			continue
+		}
+		refs = append(refs, schemaRefs(prop, field, self, known)...)
+	}
+	return refs
+}
+
+// copyPath returns a copy of path so appending to it never changes another path
+func copyPath(path []string) []string {
+	return append([]string{}, path...)
+}
+
+// refKind returns the kind a field name references, if exactly one kind in
+// known (or one in the group of self) has the name of the field without its suffix
+func refKind(field string, self schema.GroupKind, known []schema.GroupKind) (schema.GroupKind, bool) {
+	prefix := ""
+	for _, suffix := range specRefSuffixes {
+		if p, ok := strings.CutSuffix(field, suffix); ok {
+			prefix = p
+			break
+		}
+	}
+	if prefix == "" {
+		return schema.GroupKind{}, false
+	}
+
+	matches := []schema.GroupKind{}
+	for _, kind := range known {
+		if strings.EqualFold(kind.Kind, prefix) && kind != self {
+			matches = append(matches, kind)
+		}
+	}
+	if len(matches) > 1 {
+		// prefer the kind in the group of the referencing resource
+		for _, kind := range matches {
+			if kind.Group == self.Group {
+				return kind, true
+			}
+		}
+		return schema.GroupKind{}, false
+	}
+	if len(matches) == 0 {
+		return schema.GroupKind{}, false
+	}
+	return matches[0], true
+}
+
+// WantsObjects reports whether the resources of crd have reference fields
+func (d *SpecRefDetector) WantsObjects(crd unstructured.Unstructured) bool {
+	res, _, err := GetRes(crd)
+	return err == nil && len(d.fields[res.GroupKind()]) > 0
+}
+
+// Detect returns an edge to the kind of every reference field set on obj
+func (d *SpecRefDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	edges := []Edge{}
+	for _, ref := range d.fields[kind] {
+		for _, value := range fieldValues(obj.Object, ref.path) {
+			owner := ref.kind
+			if owner.Empty() {
+				m, ok := value.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if owner, ok = typedRef(m); !ok {
+					continue
+				}
+			}
+			if owner != kind {
+				edges = append(edges, Edge{Kind: kind, Owner: owner})
+			}
+		}
+	}
+	return edges
+}
+
+// fieldValues returns the values set at path in obj, following arrays at "[]"
+func fieldValues(obj interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if obj == nil || obj == "" {
+			return nil
+		}
+		return []interface{}{obj}
+	}
+	if path[0] == "[]" {
+		values := []interface{}{}
+		items, _ := obj.([]interface{})
+		for _, item := range items {
+			values = append(values, fieldValues(item, path[1:])...)
+		}
+		return values
+	}
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return fieldValues(m[path[0]], path[1:])
+}
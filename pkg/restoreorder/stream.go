@@ -0,0 +1,217 @@
+package restoreorder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+// NewClients creates the clients a scan uses sharing one HTTP client.
+// the metadata client negotiates protobuf, which the API server serves
+// object metadata in for every kind including custom resources, and falls
+// back to JSON. custom resources are only served as JSON so the dynamic
+// client decodes lists as they are streamed, one item at a time, instead
+// of reading the whole response first
+func NewClients(config *rest.Config) (dynamic.Interface, metadata.Interface, error) {
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create HTTP client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create client: %w", err)
+	}
+	restConfig := dynamic.ConfigFor(config)
+	restConfig.GroupVersion = &schema.GroupVersion{}
+	restClient, err := rest.UnversionedRESTClientForConfigAndClient(restConfig, httpClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create client: %w", err)
+	}
+
+	metadataClient, err := metadata.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create metadata client: %w", err)
+	}
+	return &streamingClient{Interface: dynamicClient, rest: restClient}, metadataClient, nil
+}
+
+// JSONMetadataConfig returns a copy of config whose metadata clients are
+// served JSON rather than protobuf, to compare the two
+func JSONMetadataConfig(config *rest.Config) *rest.Config {
+	config = rest.CopyConfig(config)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return jsonOnly{rt}
+	})
+	return config
+}
+
+// jsonOnly drops protobuf from the content types a request accepts
+type jsonOnly struct {
+	http.RoundTripper
+}
+
+func (t jsonOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	accept := []string{}
+	for _, contentType := range strings.Split(req.Header.Get("Accept"), ",") {
+		if !strings.Contains(contentType, "protobuf") {
+			accept = append(accept, contentType)
+		}
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept", strings.Join(accept, ","))
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// streamingClient is a dynamic client whose lists are decoded as they are streamed
+type streamingClient struct {
+	dynamic.Interface
+	rest rest.Interface
+}
+
+func (c *streamingClient) Resource(res schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &streamingResource{NamespaceableResourceInterface: c.Interface.Resource(res), client: c, res: res}
+}
+
+type streamingResource struct {
+	dynamic.NamespaceableResourceInterface
+	client *streamingClient
+	res    schema.GroupVersionResource
+}
+
+func (r *streamingResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &streamingNamespacedResource{
+		ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace),
+		client:            r.client,
+		res:               r.res,
+		namespace:         namespace,
+	}
+}
+
+func (r *streamingResource) List(ctx context.Context, opts v1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return r.client.list(ctx, r.res, "", opts)
+}
+
+type streamingNamespacedResource struct {
+	dynamic.ResourceInterface
+	client    *streamingClient
+	res       schema.GroupVersionResource
+	namespace string
+}
+
+func (r *streamingNamespacedResource) List(ctx context.Context, opts v1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return r.client.list(ctx, r.res, r.namespace, opts)
+}
+
+// list lists res in namespace, every namespace when empty
+func (c *streamingClient) list(ctx context.Context, res schema.GroupVersionResource, namespace string, opts v1.ListOptions) (*unstructured.UnstructuredList, error) {
+	path := []string{"/apis", res.Group, res.Version}
+	if res.Group == "" {
+		path = []string{"/api", res.Version}
+	}
+	if namespace != "" {
+		path = append(path, "namespaces", namespace)
+	}
+	path = append(path, res.Resource)
+
+	body, err := c.rest.Get().AbsPath(path...).VersionedParams(&opts, v1.ParameterCodec).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	list, err := decodeList(body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode list of %s: %w", res.GroupResource(), err)
+	}
+	return list, nil
+}
+
+// decodeList decodes a JSON list from r an item at a time, so only the
+// item being decoded is held as raw JSON
+func decodeList(r io.Reader) (*unstructured.UnstructuredList, error) {
+	decoder := json.NewDecoder(r)
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		if key != "items" {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, err
+			}
+			var value interface{}
+			if err := utiljson.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+			list.Object[key] = value
+			continue
+		}
+
+		// items is null when the list is empty
+		if token, err := decoder.Token(); err != nil || token == nil {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		} else if token != json.Delim('[') {
+			return nil, fmt.Errorf("expected items to be an array, got %v", token)
+		}
+		for decoder.More() {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				return nil, err
+			}
+			item := unstructured.Unstructured{}
+			if err := utiljson.Unmarshal(raw, &item.Object); err != nil {
+				return nil, err
+			}
+			list.Items = append(list.Items, item)
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return nil, err
+		}
+	}
+
+	// items of built-in kinds are listed without their type, like the
+	// dynamic client set it from the type of the list
+	kind := strings.TrimSuffix(list.GetKind(), "List")
+	for i := range list.Items {
+		if list.Items[i].GetKind() == "" {
+			list.Items[i].SetKind(kind)
+		}
+		if list.Items[i].GetAPIVersion() == "" {
+			list.Items[i].SetAPIVersion(list.GetAPIVersion())
+		}
+	}
+	return list, nil
+}
+
+// expectDelim reads the next token of decoder and fails unless it is delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
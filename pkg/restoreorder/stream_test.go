@@ -0,0 +1,199 @@
+package restoreorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+)
+
+// benchmarkObjects is the number of custom resources the benchmarks list
+const benchmarkObjects = 2000
+
+// listServer serves the CRD of kind and n resources of it, every one
+// owned by the one before, the way the API server does: full objects as
+// JSON and their metadata as JSON or, when accepted, protobuf
+func listServer(tb testing.TB, kind schema.GroupKind, n int) *rest.Config {
+	tb.Helper()
+	crd := testCRD(kind.Group, kind.Kind)
+	plural := strings.ToLower(kind.Kind) + "s"
+
+	objects := []unstructured.Unstructured{}
+	metadataList := &v1.PartialObjectMetadataList{}
+	for i := 0; i < n; i++ {
+		owners := []v1.OwnerReference{}
+		if i > 0 {
+			owners = append(owners, testOwner(kind, fmt.Sprintf("r%05d", i-1), true))
+		}
+		obj := testObject(kind, fmt.Sprintf("r%05d", i), owners...)
+		obj.SetLabels(map[string]string{"app.kubernetes.io/name": "bench", "app.kubernetes.io/instance": obj.GetName()})
+		// the bulk of a typical object is its spec and status, which
+		// listing metadata leaves out
+		obj.Object["spec"] = map[string]interface{}{"config": strings.Repeat("x", 2048), "replicas": int64(3)}
+		obj.Object["status"] = map[string]interface{}{"message": strings.Repeat("y", 512)}
+		objects = append(objects, obj)
+
+		meta := v1.PartialObjectMetadata{TypeMeta: v1.TypeMeta{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind()}}
+		meta.Name, meta.Namespace, meta.UID = obj.GetName(), obj.GetNamespace(), obj.GetUID()
+		meta.Labels, meta.OwnerReferences = obj.GetLabels(), obj.GetOwnerReferences()
+		metadataList.Items = append(metadataList.Items, meta)
+	}
+	metadataList.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("PartialObjectMetadataList"))
+
+	full := mustJSON(tb, map[string]interface{}{"apiVersion": kind.Group + "/v1", "kind": kind.Kind + "List", "metadata": map[string]interface{}{}, "items": objects})
+	metadataJSON := mustJSON(tb, metadataList)
+	scheme := runtime.NewScheme()
+	if err := v1.AddMetaToScheme(scheme); err != nil {
+		tb.Fatal(err)
+	}
+	metadataProtobuf := &bytes.Buffer{}
+	if err := protobuf.NewSerializer(scheme, scheme).Encode(metadataList, metadataProtobuf); err != nil {
+		tb.Fatal(err)
+	}
+	crds := mustJSON(tb, map[string]interface{}{"apiVersion": CRDResource.GroupVersion().String(), "kind": "CustomResourceDefinitionList", "metadata": map[string]interface{}{}, "items": []unstructured.Unstructured{crd}})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		switch {
+		case r.URL.Path == "/apis/"+CRDResource.GroupVersion().String()+"/"+CRDResource.Resource:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(crds)
+		case strings.HasPrefix(r.URL.Path, "/apis/admissionregistration.k8s.io/v1/"):
+			// no webhook configurations
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"apiVersion":"admissionregistration.k8s.io/v1","kind":"List","metadata":{},"items":[]}`))
+		case r.URL.Path != "/apis/"+kind.Group+"/v1/"+plural:
+			http.NotFound(w, r)
+		case strings.Contains(accept, "protobuf"):
+			w.Header().Set("Content-Type", "application/vnd.kubernetes.protobuf")
+			w.Write(metadataProtobuf.Bytes())
+		case strings.Contains(accept, "as=PartialObjectMetadataList"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(metadataJSON)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(full)
+		}
+	}))
+	tb.Cleanup(server.Close)
+	return &rest.Config{Host: server.URL}
+}
+
+func mustJSON(tb testing.TB, v interface{}) []byte {
+	tb.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+// BenchmarkList compares listing the resources of a CRD through every
+// client a scan can use: full objects decoded at once or as they are
+// streamed, and their metadata as JSON or protobuf
+func BenchmarkList(b *testing.B) {
+	kind := schema.GroupKind{Group: "x.io", Kind: "App"}
+	res := kind.WithVersion("v1").GroupVersion().WithResource("apps")
+	config := listServer(b, kind, benchmarkObjects)
+
+	jsonClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	streamingClient, protobufClient, err := NewClients(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	jsonMetadataClient, err := metadata.NewForConfig(JSONMetadataConfig(config))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	full := func(client dynamic.Interface) func() (int, error) {
+		return func() (int, error) {
+			list, err := client.Resource(res).List(context.Background(), v1.ListOptions{})
+			if err != nil {
+				return 0, err
+			}
+			return len(list.Items), nil
+		}
+	}
+	meta := func(client metadata.Interface) func() (int, error) {
+		return func() (int, error) {
+			list, err := client.Resource(res).List(context.Background(), v1.ListOptions{})
+			if err != nil {
+				return 0, err
+			}
+			return len(list.Items), nil
+		}
+	}
+	paths := []struct {
+		name string
+		list func() (int, error)
+	}{
+		{"json", full(jsonClient)},
+		{"json-stream", full(streamingClient)},
+		{"metadata-json", meta(jsonMetadataClient)},
+		{"metadata-protobuf", meta(protobufClient)},
+	}
+	for _, path := range paths {
+		b.Run(path.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				n, err := path.list()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if n != benchmarkObjects {
+					b.Fatalf("listed %d objects, want %d", n, benchmarkObjects)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDiscover compares a scan whose metadata client is served
+// protobuf, as it is against a real API server, with one served JSON
+func BenchmarkDiscover(b *testing.B) {
+	kind := schema.GroupKind{Group: "x.io", Kind: "App"}
+	config := listServer(b, kind, benchmarkObjects)
+
+	configs := []struct {
+		name   string
+		config *rest.Config
+	}{
+		{"json", JSONMetadataConfig(config)},
+		{"protobuf", config},
+	}
+	for _, c := range configs {
+		b.Run(c.name, func(b *testing.B) {
+			dynamicClient, metadataClient, err := NewClients(c.config)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				graph, err := Discover(context.Background(), dynamicClient, metadataClient, Options{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if got := graph.Counts[kind]; got != benchmarkObjects {
+					b.Fatalf("found %d resources, want %d", got, benchmarkObjects)
+				}
+			}
+		})
+	}
+}
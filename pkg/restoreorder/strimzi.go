@@ -0,0 +1,49 @@
+package restoreorder
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Strimzi is the name of the Strimzi detector
+const Strimzi = "strimzi"
+
+// StrimziGroup is the group of the Strimzi Kafka kinds
+const StrimziGroup = "kafka.strimzi.io"
+
+// StrimziClusterLabel names the cluster a Strimzi resource belongs to
+const StrimziClusterLabel = "strimzi.io/cluster"
+
+func init() {
+	RegisterDetector(Strimzi, StrimziDetector{})
+}
+
+// the Strimzi kinds other kinds belong to
+var (
+	strimziKafka        = schema.GroupKind{Group: StrimziGroup, Kind: "Kafka"}
+	strimziKafkaConnect = schema.GroupKind{Group: StrimziGroup, Kind: "KafkaConnect"}
+	strimziConnector    = schema.GroupKind{Group: StrimziGroup, Kind: "KafkaConnector"}
+)
+
+// StrimziDetector orders the KafkaNodePools, KafkaTopics, KafkaUsers and
+// KafkaRebalances of a Kafka cluster after the Kafka, and KafkaConnectors
+// after the KafkaConnect running them. Strimzi ties them together with the
+// strimzi.io/cluster label rather than owner references, so only the
+// metadata of the resources is read
+type StrimziDetector struct{}
+
+// Detect returns the edge from a Strimzi resource to the kind of the cluster
+// its strimzi.io/cluster label names
+func (StrimziDetector) Detect(obj unstructured.Unstructured) []Edge {
+	kind := obj.GroupVersionKind().GroupKind()
+	if kind.Group != StrimziGroup || obj.GetLabels()[StrimziClusterLabel] == "" {
+		return nil
+	}
+	switch kind {
+	case strimziKafka, strimziKafkaConnect:
+		return nil
+	case strimziConnector:
+		return []Edge{{Kind: kind, Owner: strimziKafkaConnect}}
+	}
+	return []Edge{{Kind: kind, Owner: strimziKafka}}
+}
@@ -0,0 +1,98 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestStrimziDetector(t *testing.T) {
+	topic := schema.GroupKind{Group: StrimziGroup, Kind: "KafkaTopic"}
+	nodePool := schema.GroupKind{Group: StrimziGroup, Kind: "KafkaNodePool"}
+
+	testDetector(t, StrimziDetector{}, []detectorTest{
+		{
+			name: "topic",
+			manifest: `
+apiVersion: kafka.strimzi.io/v1beta2
+kind: KafkaTopic
+metadata:
+  name: orders
+  namespace: kafka
+  labels:
+    strimzi.io/cluster: events
+spec:
+  partitions: 12
+`,
+			want: []Edge{{Kind: topic, Owner: strimziKafka}},
+		},
+		{
+			name: "node pool",
+			manifest: `
+apiVersion: kafka.strimzi.io/v1beta2
+kind: KafkaNodePool
+metadata:
+  name: brokers
+  namespace: kafka
+  labels:
+    strimzi.io/cluster: events
+spec:
+  replicas: 3
+  roles: [broker]
+`,
+			want: []Edge{{Kind: nodePool, Owner: strimziKafka}},
+		},
+		{
+			name: "connector",
+			manifest: `
+apiVersion: kafka.strimzi.io/v1beta2
+kind: KafkaConnector
+metadata:
+  name: orders-sink
+  namespace: kafka
+  labels:
+    strimzi.io/cluster: events-connect
+spec:
+  class: io.confluent.connect.s3.S3SinkConnector
+`,
+			want: []Edge{{Kind: strimziConnector, Owner: strimziKafkaConnect}},
+		},
+		{
+			// Strimzi labels the clusters themselves too
+			name: "kafka connect",
+			manifest: `
+apiVersion: kafka.strimzi.io/v1beta2
+kind: KafkaConnect
+metadata:
+  name: events-connect
+  namespace: kafka
+  labels:
+    strimzi.io/cluster: events-connect
+spec:
+  bootstrapServers: events-kafka-bootstrap:9093
+`,
+		},
+		{
+			name: "unlabeled topic",
+			manifest: `
+apiVersion: kafka.strimzi.io/v1beta2
+kind: KafkaTopic
+metadata:
+  name: orders
+  namespace: kafka
+`,
+		},
+		{
+			name: "other group",
+			manifest: `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: events-cluster-ca-cert
+  namespace: kafka
+  labels:
+    strimzi.io/cluster: events
+`,
+		},
+	})
+}
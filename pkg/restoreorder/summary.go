@@ -0,0 +1,75 @@
+package restoreorder
+
+import (
+	"cmp"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Summary are statistics about a scan, to help tune what is scanned
+type Summary struct {
+	// CRDs is the number of CRDs whose resources were scanned
+	CRDs int
+	// Resources is the number of resources listed
+	Resources int
+	// Edges is the number of ownership edges between kinds
+	Edges int
+	// MaxDepth is the length of the longest owner chain
+	MaxDepth int
+	// MaxFanOut is the kind owning the most kinds and FanOut the number of kinds it owns
+	MaxFanOut string
+	FanOut    int
+	// Unrelated are the scanned kinds with neither owners nor owned kinds,
+	// which add nothing to the order
+	Unrelated []string
+	// NotEstablished are the CRDs left out of the scan as they were not established
+	NotEstablished []string
+	// Removed are the CRDs deleted while they were scanned
+	Removed []string
+}
+
+// Summary returns the statistics of the scan the graph was built from
+func (g *Graph) Summary() Summary {
+	s := Summary{Unrelated: []string{}}
+	builtin := builtinKinds()
+	for kind := range g.Resources {
+		if !slices.Contains(builtin, kind) {
+			s.CRDs++
+		}
+	}
+	for _, count := range g.Counts {
+		s.Resources += count
+	}
+
+	owned := map[schema.GroupKind]int{}
+	related := map[schema.GroupKind]bool{}
+	depths := map[schema.GroupKind]int{}
+	for _, kind := range sortedKinds(g.Owners) {
+		related[kind] = true
+		for owner := range g.Owners[kind] {
+			related[owner] = true
+			s.Edges++
+			if owner != kind {
+				owned[owner]++
+			}
+		}
+		s.MaxDepth = max(s.MaxDepth, depth(g.Owners, kind, depths, map[schema.GroupKind]bool{}))
+	}
+
+	for _, owner := range sortedKinds(owned) {
+		if owned[owner] > s.FanOut {
+			s.MaxFanOut, s.FanOut = g.Name(owner), owned[owner]
+		}
+	}
+
+	for kind, name := range g.Resources {
+		if !related[kind] {
+			s.Unrelated = append(s.Unrelated, name)
+		}
+	}
+	slices.SortFunc(s.Unrelated, cmp.Compare[string])
+	s.NotEstablished = slices.Clone(g.NotEstablished)
+	s.Removed = slices.Clone(g.Removed)
+	return s
+}
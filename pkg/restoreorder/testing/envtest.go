@@ -0,0 +1,222 @@
+package restoreordertesting
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// AssetsEnv is the environment variable naming the directory holding the
+// etcd and kube-apiserver binaries StartEnv runs, e.g. the directory printed
+// by setup-envtest use -p path
+const AssetsEnv = "KUBEBUILDER_ASSETS"
+
+// StartEnv starts an API server and etcd from the envtest binaries, installs
+// the CRDs among the manifests under dir and creates the other objects, owners
+// first so the UIDs of owner references point at the created owners. the
+// control plane is stopped when t ends and t is skipped when no binaries are
+// found, so integration tests only run where the binaries are installed.
+// the returned config authenticates as a cluster admin
+func StartEnv(t testing.TB, dir string) *rest.Config {
+	t.Helper()
+
+	assets := os.Getenv(AssetsEnv)
+	if assets == "" {
+		t.Skipf("envtest binaries not found, set %s", AssetsEnv)
+	}
+
+	manifests, err := restoreorder.ReadManifestDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read fixtures: %s", err)
+	}
+	crds := []*apiextensionsv1.CustomResourceDefinition{}
+	objects := []unstructured.Unstructured{}
+	for _, obj := range manifests {
+		if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: apiextensionsv1.GroupName, Kind: "CustomResourceDefinition"}) {
+			objects = append(objects, obj)
+			continue
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, crd); err != nil {
+			t.Fatalf("cannot read CRD %s: %s", obj.GetName(), err)
+		}
+		crds = append(crds, crd)
+	}
+
+	env := &envtest.Environment{BinaryAssetsDirectory: assets, CRDs: crds}
+	config, err := env.Start()
+	if err != nil {
+		t.Fatalf("cannot start envtest: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Errorf("cannot stop envtest: %s", err)
+		}
+	})
+
+	if err := createObjects(context.Background(), config, objects); err != nil {
+		t.Fatalf("cannot create fixtures: %s", err)
+	}
+	return config
+}
+
+// createObjects creates objects, creating their namespaces and every owner
+// among objects before the objects they own
+func createObjects(ctx context.Context, config *rest.Config, objects []unstructured.Unstructured) error {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("cannot create dynamic client: %w", err)
+	}
+	disco, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return fmt.Errorf("cannot create discovery client: %w", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco))
+
+	fixtureUIDs := map[types.UID]bool{}
+	for _, obj := range objects {
+		fixtureUIDs[obj.GetUID()] = true
+	}
+	// the UIDs the objects were created with by the UIDs of the fixtures
+	created := map[types.UID]types.UID{}
+	namespaces := map[string]bool{"default": true}
+
+	pending := slices.Clone(objects)
+	for len(pending) > 0 {
+		waiting := []unstructured.Unstructured{}
+		for _, obj := range pending {
+			refs := obj.GetOwnerReferences()
+			if slices.ContainsFunc(refs, func(ref v1.OwnerReference) bool {
+				_, ok := created[ref.UID]
+				return fixtureUIDs[ref.UID] && !ok
+			}) {
+				waiting = append(waiting, obj)
+				continue
+			}
+
+			obj = *obj.DeepCopy()
+			for i, ref := range refs {
+				if uid, ok := created[ref.UID]; ok {
+					refs[i].UID = uid
+				}
+			}
+			obj.SetOwnerReferences(refs)
+			fixtureUID := obj.GetUID()
+			obj.SetUID("")
+			obj.SetResourceVersion("")
+
+			mapping, err := mapper.RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+			if err != nil {
+				return fmt.Errorf("cannot map %s %s: %w", obj.GetKind(), obj.GetName(), err)
+			}
+			var ri dynamic.ResourceInterface = client.Resource(mapping.Resource)
+			if namespace := obj.GetNamespace(); namespace != "" {
+				if !namespaces[namespace] {
+					if err := createNamespace(ctx, client, namespace); err != nil {
+						return err
+					}
+					namespaces[namespace] = true
+				}
+				ri = client.Resource(mapping.Resource).Namespace(namespace)
+			}
+			res, err := ri.Create(ctx, &obj, v1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("cannot create %s %s: %w", obj.GetKind(), obj.GetName(), err)
+			}
+			if fixtureUID != "" {
+				created[fixtureUID] = res.GetUID()
+			}
+		}
+		if len(waiting) == len(pending) {
+			return fmt.Errorf("cannot create %d objects whose owners form a cycle", len(waiting))
+		}
+		pending = waiting
+	}
+	return nil
+}
+
+// createNamespace creates the named namespace unless it exists
+func createNamespace(ctx context.Context, client dynamic.Interface, name string) error {
+	namespace := &unstructured.Unstructured{}
+	namespace.SetAPIVersion("v1")
+	namespace.SetKind("Namespace")
+	namespace.SetName(name)
+	_, err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).Create(ctx, namespace, v1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("cannot create namespace %s: %w", name, err)
+	}
+	return nil
+}
+
+// ServiceAccountConfig creates the service account namespace/name bound to a
+// ClusterRole with rules, such as those printed by the rbac command, and
+// returns config impersonating it so a scan can be checked to succeed with
+// no more than those permissions
+func ServiceAccountConfig(t testing.TB, config *rest.Config, namespace, name string, rules []rbacv1.PolicyRule) *rest.Config {
+	t.Helper()
+
+	ctx := context.Background()
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("cannot create dynamic client: %s", err)
+	}
+	if err := createNamespace(ctx, client, namespace); err != nil {
+		t.Fatal(err)
+	}
+
+	binding := namespace + "-" + name
+	objects := []struct {
+		resource schema.GroupVersionResource
+		obj      runtime.Object
+	}{
+		{corev1.SchemeGroupVersion.WithResource("serviceaccounts"), &corev1.ServiceAccount{
+			TypeMeta:   v1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "ServiceAccount"},
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace},
+		}},
+		{rbacv1.SchemeGroupVersion.WithResource("clusterroles"), &rbacv1.ClusterRole{
+			TypeMeta:   v1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: v1.ObjectMeta{Name: binding},
+			Rules:      rules,
+		}},
+		{rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings"), &rbacv1.ClusterRoleBinding{
+			TypeMeta:   v1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: v1.ObjectMeta{Name: binding},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: binding},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Namespace: namespace, Name: name}},
+		}},
+	}
+	for _, o := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o.obj)
+		if err != nil {
+			t.Fatalf("cannot convert %s: %s", o.resource.Resource, err)
+		}
+		obj := &unstructured.Unstructured{Object: content}
+		if _, err := client.Resource(o.resource).Namespace(obj.GetNamespace()).Create(ctx, obj, v1.CreateOptions{}); err != nil {
+			t.Fatalf("cannot create %s %s: %s", o.resource.Resource, obj.GetName(), err)
+		}
+	}
+
+	impersonated := rest.CopyConfig(config)
+	impersonated.Impersonate = rest.ImpersonationConfig{UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)}
+	return impersonated
+}
@@ -0,0 +1,91 @@
+//go:build integration
+
+package restoreordertesting
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// discoverEnv discovers the graph of the cluster config points at with opts
+func discoverEnv(t *testing.T, config *rest.Config, opts restoreorder.Options) (*restoreorder.Graph, error) {
+	t.Helper()
+	dynamicClient, metadataClient, err := restoreorder.NewClients(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return restoreorder.Discover(context.Background(), dynamicClient, metadataClient, opts)
+}
+
+// TestStartEnv checks a real API server serving the fixtures computes the
+// order the fake clients compute
+func TestStartEnv(t *testing.T) {
+	config := StartEnv(t, "testdata/stack")
+
+	graph, err := discoverEnv(t, config, restoreorder.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := graph.Counts[schema.GroupKind{Group: "db.example.io", Kind: "Database"}]; got != 1 {
+		t.Errorf("got %d databases, want 1", got)
+	}
+	AssertGolden(t, "testdata/stack.golden", graph.Priorities())
+}
+
+// TestServiceAccountConfig scans the fixtures impersonating service accounts
+// granted every permission a scan needs, all but one of them and none
+func TestServiceAccountConfig(t *testing.T) {
+	config := StartEnv(t, "testdata/stack")
+
+	crds := rbacv1.PolicyRule{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"get", "list"}}
+	webhooks := rbacv1.PolicyRule{APIGroups: []string{"admissionregistration.k8s.io"}, Resources: []string{"mutatingwebhookconfigurations", "validatingwebhookconfigurations"}, Verbs: []string{"list"}}
+	databases := rbacv1.PolicyRule{APIGroups: []string{"db.example.io"}, Resources: []string{"*"}, Verbs: []string{"list"}}
+	backups := rbacv1.PolicyRule{APIGroups: []string{"backup.example.io"}, Resources: []string{"backups"}, Verbs: []string{"list"}}
+
+	t.Run("every permission", func(t *testing.T) {
+		impersonated := ServiceAccountConfig(t, config, "velero", "scanner", []rbacv1.PolicyRule{crds, webhooks, databases, backups})
+		if impersonated.Impersonate.UserName != "system:serviceaccount:velero:scanner" {
+			t.Errorf("got impersonated user %q", impersonated.Impersonate.UserName)
+		}
+
+		graph, err := discoverEnv(t, impersonated, restoreorder.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertGolden(t, "testdata/stack.golden", graph.Priorities())
+	})
+
+	t.Run("backups forbidden", func(t *testing.T) {
+		impersonated := ServiceAccountConfig(t, config, "velero", "restricted", []rbacv1.PolicyRule{crds, webhooks, databases})
+
+		_, err := discoverEnv(t, impersonated, restoreorder.Options{})
+		listErrs := restoreorder.ListErrors{}
+		if !errors.As(err, &listErrs) || len(listErrs) != 1 || !apierrors.IsForbidden(listErrs[0].Err) {
+			t.Fatalf("got error %v, want backups to be forbidden", err)
+		}
+
+		graph, err := discoverEnv(t, impersonated, restoreorder.Options{BestEffort: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := graph.Report().Counts[restoreorder.ReportListForbidden]; got != 1 {
+			t.Errorf("got %d forbidden lists, want 1: %v", got, graph.Skipped)
+		}
+	})
+
+	t.Run("no permissions", func(t *testing.T) {
+		impersonated := ServiceAccountConfig(t, config, "velero", "nobody", nil)
+
+		if _, err := discoverEnv(t, impersonated, restoreorder.Options{BestEffort: true}); !apierrors.IsForbidden(err) {
+			t.Errorf("got error %v, want listing CRDs to be forbidden", err)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+// Package restoreordertesting regression tests the restore order of CRDs
+// and custom resources kept as manifests, so platform repositories can check
+// in CI that a change to their operator stack does not change it unnoticed:
+//
+//	package stack_test
+//
+//	import (
+//		"testing"
+//
+//		"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+//		restoreordertesting "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder/testing"
+//	)
+//
+//	func TestRestoreOrder(t *testing.T) {
+//		restoreordertesting.AssertOrder(t, "testdata/stack", "testdata/stack.golden", restoreorder.Options{})
+//	}
+//
+// testdata/stack holds the manifests of the CRDs and custom resources. the
+// golden file holds one priorities entry per line, it does not exist until
+// the test is first run with the UPDATE_GOLDEN environment variable set,
+// which rewrites it with the computed order:
+//
+//	UPDATE_GOLDEN=1 go test ./...
+//
+// StartEnv runs the same fixtures against a real API server from the envtest
+// binaries, for integration tests kept behind a build tag. ServiceAccountConfig
+// checks a scan succeeds with no more permissions than rules grant:
+//
+//	//go:build integration
+//
+//	func TestRestoreOrderEnvtest(t *testing.T) {
+//		config := restoreordertesting.StartEnv(t, "testdata/stack")
+//		rules := []rbacv1.PolicyRule{
+//			{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"get", "list"}},
+//			{APIGroups: []string{"admissionregistration.k8s.io"}, Resources: []string{"mutatingwebhookconfigurations", "validatingwebhookconfigurations"}, Verbs: []string{"list"}},
+//			{APIGroups: []string{"db.example.io"}, Resources: []string{"*"}, Verbs: []string{"list"}},
+//		}
+//		config = restoreordertesting.ServiceAccountConfig(t, config, "velero", "scanner", rules)
+//
+//		dynamicClient, metadataClient, err := restoreorder.NewClients(config)
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		graph, err := restoreorder.Discover(context.Background(), dynamicClient, metadataClient, restoreorder.Options{})
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		restoreordertesting.AssertGolden(t, "testdata/stack.golden", graph.Priorities())
+//	}
+//
+// StartEnv skips the test unless KUBEBUILDER_ASSETS names the directory
+// holding the binaries:
+//
+//	KUBEBUILDER_ASSETS=$(setup-envtest use -p path) go test -tags integration ./...
+package restoreordertesting
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// UpdateEnv is the environment variable that, when set, has AssertGolden
+// write the golden files rather than compare against them
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// Discover discovers the graph of the CRDs, webhook configurations and
+// custom resources in the YAML and JSON manifests under dir with opts,
+// served by fake clients, failing t when they cannot be read
+func Discover(t testing.TB, dir string, opts restoreorder.Options) *restoreorder.Graph {
+	t.Helper()
+
+	manifests, err := restoreorder.ReadManifestDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read fixtures: %s", err)
+	}
+	dynamicClient, metadataClient, err := restoreorder.ManifestClients(manifests)
+	if err != nil {
+		t.Fatalf("cannot serve fixtures: %s", err)
+	}
+	graph, err := restoreorder.Discover(context.Background(), dynamicClient, metadataClient, opts)
+	if err != nil {
+		t.Fatalf("cannot discover graph of %s: %s", dir, err)
+	}
+	return graph
+}
+
+// AssertGolden fails t with a diff when the priorities value differs from
+// the one in the golden file, or writes it there when UpdateEnv is set
+func AssertGolden(t testing.TB, golden, priorities string) {
+	t.Helper()
+
+	want := strings.Join(restoreorder.ParsePriorities(priorities), "\n") + "\n"
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatalf("cannot create golden file directory: %s", err)
+		}
+		if err := os.WriteFile(golden, []byte(want), 0o644); err != nil {
+			t.Fatalf("cannot write golden file: %s", err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(golden)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist, run with %s=1 to write it", golden, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("cannot read golden file: %s", err)
+	}
+
+	diff := &strings.Builder{}
+	// the golden file holds an entry per line, a value holds them comma separated
+	expected := strings.Join(strings.Fields(string(data)), ",")
+	drifted, err := restoreorder.Diff(diff, golden, "computed", expected, priorities)
+	if err != nil {
+		t.Fatalf("cannot compare with golden file: %s", err)
+	}
+	if drifted {
+		t.Errorf("restore order differs from %s, run with %s=1 to update it:\n%s", golden, UpdateEnv, diff)
+	}
+}
+
+// AssertOrder discovers the graph of the manifests under dir with opts and
+// asserts its priorities value against the golden file
+func AssertOrder(t testing.TB, dir, golden string, opts restoreorder.Options) {
+	t.Helper()
+	AssertGolden(t, golden, Discover(t, dir, opts).Priorities())
+}
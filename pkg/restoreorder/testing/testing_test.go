@@ -0,0 +1,87 @@
+package restoreordertesting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// TestRestoreOrder is the test of the package documentation
+func TestRestoreOrder(t *testing.T) {
+	AssertOrder(t, "testdata/stack", "testdata/stack.golden", restoreorder.Options{})
+}
+
+// recorder is a testing.TB recording its failures rather than failing the test
+type recorder struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	r.fatal = true
+	runtime.Goexit()
+}
+
+// record runs f with a recorder, returning once f returns or fails fatally
+func record(t *testing.T, f func(tb testing.TB)) *recorder {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+	return r
+}
+
+func TestAssertGolden(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "testdata", "stack.golden")
+	priorities := Discover(t, "testdata/stack", restoreorder.Options{}).Priorities()
+
+	// a missing golden file fails, telling how to write it
+	if r := record(t, func(tb testing.TB) { AssertGolden(tb, golden, priorities) }); !r.fatal || !strings.Contains(r.failures[0], UpdateEnv) {
+		t.Errorf("got failures %v for a missing golden file, want a fatal one naming %s", r.failures, UpdateEnv)
+	}
+
+	// UPDATE_GOLDEN writes the golden file, creating its directory
+	t.Setenv(UpdateEnv, "1")
+	if r := record(t, func(tb testing.TB) { AssertOrder(tb, "testdata/stack", golden, restoreorder.Options{}) }); len(r.failures) > 0 {
+		t.Fatalf("cannot update golden file: %v", r.failures)
+	}
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(restoreorder.ParsePriorities(priorities), "\n") + "\n"; string(data) != want {
+		t.Errorf("wrote golden file\n%s\nwant\n%s", data, want)
+	}
+
+	// the written golden file matches the order it was written from
+	t.Setenv(UpdateEnv, "")
+	if r := record(t, func(tb testing.TB) { AssertOrder(tb, "testdata/stack", golden, restoreorder.Options{}) }); len(r.failures) > 0 {
+		t.Errorf("got failures %v for an up to date golden file", r.failures)
+	}
+
+	// an order that changed fails with a diff
+	drifted := strings.Replace(priorities, "backups.backup.example.io,", "", 1)
+	if drifted == priorities {
+		t.Fatalf("priorities %s do not hold backups.backup.example.io", priorities)
+	}
+	r := record(t, func(tb testing.TB) { AssertGolden(tb, golden, drifted) })
+	if r.fatal || len(r.failures) != 1 || !strings.Contains(r.failures[0], "-backups.backup.example.io") {
+		t.Errorf("got failures %v for a drifted order, want a diff removing backups.backup.example.io", r.failures)
+	}
+}
@@ -0,0 +1,25 @@
+package restoreorder
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer the spans of a scan are recorded by
+const TracerName = "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+
+// tracer records the spans of a scan with the global tracer provider,
+// which drops them unless the program has set one up
+func tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// endSpan ends span, marking it failed when err is not nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
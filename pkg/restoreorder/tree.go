@@ -0,0 +1,86 @@
+package restoreorder
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WriteTree writes the ownership graph as an indented tree, kinds without
+// owners first and every kind indented under each of its owners, with the
+// number of resources of every kind. a kind owned by more than one kind is
+// only expanded the first time it is written
+func (g *Graph) WriteTree(w io.Writer) error {
+	children := map[schema.GroupKind][]schema.GroupKind{}
+	kinds := map[schema.GroupKind]bool{}
+	for kind, owners := range g.Owners {
+		kinds[kind] = true
+		for owner := range owners {
+			kinds[owner] = true
+			if owner != kind {
+				children[owner] = append(children[owner], kind)
+			}
+		}
+	}
+	byName := func(a, b schema.GroupKind) int {
+		return cmp.Or(cmp.Compare(g.Name(a), g.Name(b)), cmp.Compare(a.String(), b.String()))
+	}
+
+	roots := []schema.GroupKind{}
+	for kind := range kinds {
+		if len(g.Owners[kind]) == 0 {
+			roots = append(roots, kind)
+		}
+	}
+	slices.SortFunc(roots, byName)
+
+	written := map[schema.GroupKind]bool{}
+	var write func(kind schema.GroupKind, depth int) error
+	write = func(kind schema.GroupKind, depth int) error {
+		note := ""
+		if written[kind] {
+			note = ", see above"
+		}
+		if _, err := fmt.Fprintf(w, "%s%s (%d%s)\n", strings.Repeat("  ", depth), g.Name(kind), g.Counts[kind], note); err != nil {
+			return err
+		}
+		if written[kind] {
+			return nil
+		}
+		written[kind] = true
+
+		slices.SortFunc(children[kind], byName)
+		for _, child := range children[kind] {
+			if err := write(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range roots {
+		if err := write(root, 0); err != nil {
+			return err
+		}
+	}
+
+	// kinds in ownership cycles have no root to be written under
+	rest := []schema.GroupKind{}
+	for kind := range kinds {
+		if !written[kind] {
+			rest = append(rest, kind)
+		}
+	}
+	slices.SortFunc(rest, byName)
+	for _, kind := range rest {
+		if !written[kind] {
+			if err := write(kind, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package restoreorder
+
+import (
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Violation is a resource that a priorities value restores before one of its owners
+type Violation struct {
+	// Resource is the CRD name of the owned resource
+	Resource string
+	// Owner is the CRD name of the owner
+	Owner string
+	// ResourcePosition and OwnerPosition are the indexes of the entries
+	// in the priorities value, -1 when the entry is not listed
+	ResourcePosition int
+	OwnerPosition    int
+}
+
+// ParsePriorities splits a restore-resource-priorities value into its entries,
+// the value may include the flag name (e.g. --restore-resource-priorities=a,b)
+func ParsePriorities(value string) []string {
+	value = strings.TrimPrefix(strings.TrimSpace(value), RestoreFlag+"=")
+	entries := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// LowPriorityDelimiter separates the high and low priority resources in a priorities value
+const LowPriorityDelimiter = "-"
+
+// restoreRank returns when the entry at pos is restored: 0 for high priority
+// entries, 1 for unlisted resources (pos -1) and 2 for low priority entries
+func restoreRank(priorities []string, pos int) int {
+	delimiter := slices.Index(priorities, LowPriorityDelimiter)
+	switch {
+	case pos < 0:
+		return 1
+	case delimiter >= 0 && pos > delimiter:
+		return 2
+	}
+	return 0
+}
+
+// entryIndex returns the index of the first entry of priorities naming the
+// resource name, the plural.group name of kind, or -1 when none does.
+// velero resolves entries through a RESTMapper, so an entry names a resource
+// by its plural or its singular, the lowercase kind, with or without its group
+func entryIndex(priorities []string, name string, kind schema.GroupKind) int {
+	res := schema.ParseGroupResource(name)
+	singular := strings.ToLower(kind.Kind)
+	return slices.IndexFunc(priorities, func(entry string) bool {
+		if entry == LowPriorityDelimiter {
+			return false
+		}
+		gr := schema.ParseGroupResource(entry)
+		if gr.Group != "" && gr.Group != res.Group {
+			return false
+		}
+		return gr.Resource == res.Resource || (singular != "" && gr.Resource == singular)
+	})
+}
+
+// Validate checks a priorities value against the graph and returns every
+// resource that would be restored before one of its owners.
+// velero restores the listed resources first, then everything else and then
+// the low priority resources listed after "-", so a high priority resource
+// whose owner is not listed is also a violation. entries may name resources
+// in any form velero accepts, see entryIndex
+func (g *Graph) Validate(priorities []string) []Violation {
+	violations := []Violation{}
+	for kind, owners := range g.Owners {
+		resource, ok := g.Resources[kind]
+		if !ok {
+			continue
+		}
+		resourcePos := entryIndex(priorities, resource, kind)
+
+		for owner := range owners {
+			ownerName, ok := g.Resources[owner]
+			if !ok || ownerName == resource {
+				continue
+			}
+			ownerPos := entryIndex(priorities, ownerName, owner)
+			if restoreRank(priorities, ownerPos) > restoreRank(priorities, resourcePos) ||
+				(ownerPos > resourcePos && resourcePos >= 0 && ownerPos >= 0) {
+				violations = append(violations, Violation{
+					Resource:         resource,
+					Owner:            ownerName,
+					ResourcePosition: resourcePos,
+					OwnerPosition:    ownerPos,
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(violations, func(a, b Violation) int {
+		if c := a.ResourcePosition - b.ResourcePosition; c != 0 {
+			return c
+		}
+		return strings.Compare(a.Owner, b.Owner)
+	})
+	return violations
+}
@@ -0,0 +1,43 @@
+package restoreorder
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestValidateEntryForms(t *testing.T) {
+	cluster := schema.GroupKind{Group: "example.com", Kind: "Cluster"}
+	database := schema.GroupKind{Group: "example.com", Kind: "Database"}
+	graph := &Graph{
+		Owners: map[schema.GroupKind]map[schema.GroupKind]any{
+			database: {cluster: nil},
+		},
+		Resources: map[schema.GroupKind]string{
+			cluster:  "clusters.example.com",
+			database: "databases.example.com",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		priorities string
+		violations int
+	}{
+		{"qualified", "clusters.example.com,databases.example.com", 0},
+		{"plural", "clusters,databases", 0},
+		{"singular", "cluster,database.example.com", 0},
+		{"reversed", "database,clusters", 1},
+		{"other group", "clusters.other.io,databases.example.com", 1},
+		{"owner low priority", "databases,-,cluster", 1},
+		{"unlisted", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := graph.Validate(ParsePriorities(tt.priorities))
+			if len(got) != tt.violations {
+				t.Errorf("Validate(%q) = %+v, want %d violations", tt.priorities, got, tt.violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package restoreorder
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DeploymentResource is the resource the Velero server Deployment is served by
+var DeploymentResource = schema.GroupVersionResource{
+	Group:    "apps",
+	Version:  "v1",
+	Resource: "deployments",
+}
+
+// GetDeploymentPriorities returns the value of the restore-resource-priorities
+// flag passed to the named container of a Velero Deployment and whether it is set.
+// both the --flag=value and --flag value forms are understood
+func GetDeploymentPriorities(deploy *unstructured.Unstructured, container string) (string, bool, error) {
+	args, _, err := containerArgs(deploy, container)
+	if err != nil {
+		return "", false, err
+	}
+
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, RestoreFlag+"="); ok {
+			return value, true, nil
+		}
+		if arg == RestoreFlag && i+1 < len(args) {
+			return args[i+1], true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// SetDeploymentPriorities sets the restore-resource-priorities flag passed to
+// the named container of a Velero Deployment to value, replacing the existing
+// flag in place or adding it to the end of the arguments
+func SetDeploymentPriorities(deploy *unstructured.Unstructured, container string, value string) error {
+	args, idx, err := containerArgs(deploy, container)
+	if err != nil {
+		return err
+	}
+
+	set := false
+	for i, arg := range args {
+		if strings.HasPrefix(arg, RestoreFlag+"=") {
+			args[i] = RestoreFlag + "=" + value
+			set = true
+			break
+		}
+		if arg == RestoreFlag && i+1 < len(args) {
+			args[i+1] = value
+			set = true
+			break
+		}
+	}
+	if !set {
+		args = append(args, RestoreFlag+"="+value)
+	}
+
+	containers, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	c := containers[idx].(map[string]interface{})
+	newArgs := make([]interface{}, len(args))
+	for i := range args {
+		newArgs[i] = args[i]
+	}
+	c["args"] = newArgs
+	containers[idx] = c
+	return unstructured.SetNestedSlice(deploy.Object, containers, "spec", "template", "spec", "containers")
+}
+
+// PatchOperation is a JSON patch (RFC 6902) operation
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// DeploymentPrioritiesPatch returns the JSON patch that sets the
+// restore-resource-priorities flag of the named container of a Velero
+// Deployment to value, replacing the existing argument at its index
+// or adding the flag to the end of the arguments
+func DeploymentPrioritiesPatch(deploy *unstructured.Unstructured, container string, value string) ([]PatchOperation, error) {
+	args, idx, err := containerArgs(deploy, container)
+	if err != nil {
+		return nil, err
+	}
+	argsPath := fmt.Sprintf("/spec/template/spec/containers/%d/args", idx)
+
+	// the replaced argument is tested first so the patch fails rather than
+	// overwriting another argument when the arguments have changed
+	replace := func(i int, value string) []PatchOperation {
+		path := fmt.Sprintf("%s/%d", argsPath, i)
+		return []PatchOperation{
+			{Op: "test", Path: path, Value: args[i]},
+			{Op: "replace", Path: path, Value: value},
+		}
+	}
+	for i, arg := range args {
+		if strings.HasPrefix(arg, RestoreFlag+"=") {
+			return replace(i, RestoreFlag+"="+value), nil
+		}
+		if arg == RestoreFlag && i+1 < len(args) {
+			return replace(i+1, value), nil
+		}
+	}
+	if args == nil {
+		return []PatchOperation{{Op: "add", Path: argsPath, Value: []string{RestoreFlag + "=" + value}}}, nil
+	}
+	return []PatchOperation{{Op: "add", Path: argsPath + "/-", Value: RestoreFlag + "=" + value}}, nil
+}
+
+// containerArgs returns the args of the named container and its index in the pod spec
+func containerArgs(deploy *unstructured.Unstructured, container string) ([]string, int, error) {
+	containers, found, err := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read containers of %s: %w", deploy.GetName(), err)
+	}
+	if !found {
+		return nil, 0, fmt.Errorf("deployment %s has no containers", deploy.GetName())
+	}
+
+	for i, c := range containers {
+		c, ok := c.(map[string]interface{})
+		if !ok || c["name"] != container {
+			continue
+		}
+		args, _, err := unstructured.NestedStringSlice(c, "args")
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot read args of container %s: %w", container, err)
+		}
+		return args, i, nil
+	}
+	return nil, 0, fmt.Errorf("deployment %s has no container named %s", deploy.GetName(), container)
+}
@@ -0,0 +1,446 @@
+package restoreorder
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchRetryInterval is how long Watcher waits before starting over after
+// it failed to read the CRDs or to sync the informers of their resources
+var watchRetryInterval = 30 * time.Second
+
+// DefaultSyncTimeout is how long a Watcher waits for the informers of the
+// scanned resources to sync unless its SyncTimeout says otherwise
+const DefaultSyncTimeout = 2 * time.Minute
+
+// Watcher keeps the graph of a cluster up to date from informers rather than
+// rescanning it: when a resource is added, changed or deleted only its edges
+// are detected again, and the graph is rebuilt from the edges of every
+// resource the next time it is asked for. the CRDs being added, changed or
+// removed, which changes what is scanned, starts the informers over.
+// the resources whose informers do not sync, e.g. as they cannot be listed,
+// are ListErrors, failing the watch unless Options.BestEffort
+type Watcher struct {
+	Dynamic  dynamic.Interface
+	Metadata metadata.Interface
+	Options  Options
+	// SyncTimeout bounds how long the informers of the scanned resources
+	// take to sync, DefaultSyncTimeout when 0
+	SyncTimeout time.Duration
+
+	mu sync.Mutex
+	// scan is what the current CRDs contribute to the graph
+	scan     *crdScan
+	webhooks []WebhookDependency
+	// resources are the scanned resources and the edges detected from each
+	resources map[types.UID]scannedResource
+	// skipped are the resources that could not be watched
+	skipped ListErrors
+	synced  bool
+	// dirty records that resources changed since graph was built
+	dirty bool
+	// trigger is the CRD whose definition or resources last changed
+	trigger string
+	graph   *Graph
+	updated time.Time
+	err     error
+}
+
+// Run watches the cluster until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := w.watch(ctx)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+
+		slog.Error("cannot watch resources", "error", err)
+		w.mu.Lock()
+		w.err = err
+		w.mu.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// Latest returns the graph built from the resources as last seen, when they
+// last changed and the error of the last attempt to watch them.
+// the graph is nil until every informer has synced, or with
+// Options.BestEffort until the others failed to within SyncTimeout
+func (w *Watcher) Latest() (*Graph, time.Time, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.dirty && w.synced {
+		all := make([]v1.PartialObjectMetadata, 0, len(w.resources))
+		for _, res := range w.resources {
+			all = append(all, res.meta)
+		}
+		crossScope := map[types.UID][]types.UID{}
+		if !w.Options.IncludeCrossScopeOwners {
+			crossScope = crossScopeRefs(findCrossScopeOwners(all, w.scan.namespaced))
+		}
+		edges := []Edge{}
+		for _, res := range w.resources {
+			edges = append(edges, withoutCrossScope(res.edges, res.meta, crossScope)...)
+		}
+		w.graph = w.scan.build(all, edges, w.webhooks)
+		if len(w.skipped) > 0 {
+			w.graph.Skipped = w.skipped
+		}
+		w.dirty = false
+	}
+	return w.graph, w.updated, w.err
+}
+
+// watch runs informers on the resources of the current CRDs until the CRDs
+// change, returning nil, or ctx is done
+func (w *Watcher) watch(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	crds, err := listCRDs(ctx, w.Dynamic)
+	if err != nil {
+		return err
+	}
+	// the resources of aggregated APIs are only discovered again when the CRDs change
+	crdItems := crds.Items
+	skipped := ListErrors{}
+	if w.Options.Aggregated != nil {
+		aggregated, listErrs, err := aggregatedCRDs(ctx, w.Dynamic, w.Options.Aggregated)
+		if err != nil {
+			return err
+		}
+		skipped = append(skipped, listErrs...)
+		crdItems = slices.Concat(crdItems, aggregated)
+	}
+	scan := newCRDScan(crdItems, w.Options)
+	defer closeDetectors(scan.detectors)
+	skipped = append(skipped, scan.invalid...)
+	if len(skipped) > 0 && !w.Options.BestEffort {
+		return skipped.sorted()
+	}
+	webhooks := webhookDependencies(ctx, w.Dynamic, scan.scanned.Items)
+	if w.Options.OperatorsFirst {
+		scan.operators = findOperators(ctx, w.Dynamic, scan.scanned.Items)
+	}
+
+	w.mu.Lock()
+	w.scan = scan
+	w.webhooks = webhooks
+	w.resources = map[types.UID]scannedResource{}
+	w.skipped = nil
+	w.synced = false
+	w.mu.Unlock()
+
+	// start over once the CRDs change, watching them as the version they were listed as
+	crdResource := CRDResource
+	if crds.GetAPIVersion() == CRDResourceV1beta1.GroupVersion().String() {
+		crdResource = CRDResourceV1beta1
+	}
+	crdInformer := dynamicinformer.NewFilteredDynamicInformer(w.Dynamic, crdResource, "", 0, cache.Indexers{}, nil)
+	restart := func(reason, name string) {
+		slog.Info("CRDs changed, starting over", "reason", reason, "crd", name)
+		w.mu.Lock()
+		w.trigger = name
+		w.mu.Unlock()
+		cancel()
+	}
+	_, err = crdInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		// the CRDs can have changed between listing and watching them
+		AddFunc: func(obj interface{}) {
+			crd, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			i := slices.IndexFunc(crds.Items, func(c unstructured.Unstructured) bool {
+				return c.GetUID() == crd.GetUID()
+			})
+			if i < 0 {
+				restart("added", crd.GetName())
+			} else if crdChanged(&crds.Items[i], crd) {
+				restart("changed", crd.GetName())
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok1 := oldObj.(*unstructured.Unstructured)
+			crd, ok2 := newObj.(*unstructured.Unstructured)
+			if ok1 && ok2 && crdChanged(old, crd) {
+				restart("changed", crd.GetName())
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if crd, ok := obj.(*unstructured.Unstructured); ok {
+				restart("removed", crd.GetName())
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot watch CRDs: %w", err)
+	}
+	go crdInformer.Informer().Run(ctx.Done())
+
+	tweak := func(opts *v1.ListOptions) {
+		opts.LabelSelector = w.Options.listOptions().LabelSelector
+		opts.FieldSelector = w.Options.FieldSelector
+	}
+	metadataInformers := metadatainformer.NewFilteredSharedInformerFactory(w.Metadata, 0, "", tweak)
+	objectInformers := dynamicinformer.NewFilteredDynamicSharedInformerFactory(w.Dynamic, 0, "", tweak)
+
+	// the last error listing or watching the resources of every informer,
+	// reported for the informers that do not sync
+	watched := map[string]cache.SharedIndexInformer{}
+	watchErrsMu := sync.Mutex{}
+	watchErrs := map[string]error{}
+	track := func(name string, informer informers.GenericInformer) error {
+		watched[name] = informer.Informer()
+		return informer.Informer().SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			watchErrsMu.Lock()
+			watchErrs[name] = err
+			watchErrsMu.Unlock()
+			cache.DefaultWatchErrorHandler(r, err)
+		})
+	}
+
+	for _, crd := range scan.scanned.Items {
+		res, namespaced, err := GetRes(crd)
+		if err != nil {
+			return fmt.Errorf("cannot get resource: %w", err)
+		}
+		// some detectors read more than the metadata of a resource
+		var informer informers.GenericInformer
+		if wantsObjects(scan.detectors, crd) {
+			informer = objectInformers.ForResource(res.GVR)
+		} else {
+			informer = metadataInformers.ForResource(res.GVR)
+			if err := informer.Informer().SetTransform(dropManagedFields); err != nil {
+				return fmt.Errorf("cannot watch %s: %w", crd.GetName(), err)
+			}
+		}
+		if err := track(crd.GetName(), informer); err != nil {
+			return fmt.Errorf("cannot watch %s: %w", crd.GetName(), err)
+		}
+		if err := w.handle(informer, scan, crd.GetName(), res, func(meta v1.PartialObjectMetadata) bool {
+			return !namespaced || w.Options.Scope.includesNamespace(meta.Namespace)
+		}); err != nil {
+			return fmt.Errorf("cannot watch %s: %w", crd.GetName(), err)
+		}
+	}
+	if w.Options.IncludeBuiltinChildren {
+		for _, res := range BuiltinChildResources {
+			if !w.Options.Scope.includesResource(res, true) {
+				continue
+			}
+			informer := metadataInformers.ForResource(res.GVR)
+			if err := informer.Informer().SetTransform(dropManagedFields); err != nil {
+				return fmt.Errorf("cannot watch %s: %w", res.GVR.GroupResource(), err)
+			}
+			if err := track(res.GVR.GroupResource().String(), informer); err != nil {
+				return fmt.Errorf("cannot watch %s: %w", res.GVR.GroupResource(), err)
+			}
+			// only resources owned by custom resources need to be ordered
+			if err := w.handle(informer, scan, res.GVR.GroupResource().String(), res, func(meta v1.PartialObjectMetadata) bool {
+				return w.Options.Scope.includesNamespace(meta.Namespace) && ownedByCustomResource(meta, scan.allGroups)
+			}); err != nil {
+				return fmt.Errorf("cannot watch %s: %w", res.GVR.GroupResource(), err)
+			}
+		}
+	}
+
+	metadataInformers.Start(ctx.Done())
+	objectInformers.Start(ctx.Done())
+	// the informers only stop once ctx is done, which a failed sync returns before
+	defer func() {
+		cancel()
+		metadataInformers.Shutdown()
+		objectInformers.Shutdown()
+	}()
+
+	// an informer whose resources cannot be listed never syncs, so it is
+	// only waited for so long
+	timeout := w.SyncTimeout
+	if timeout <= 0 {
+		timeout = DefaultSyncTimeout
+	}
+	syncCtx, cancelSync := context.WithTimeout(ctx, timeout)
+	hasSynced := []cache.InformerSynced{}
+	for _, informer := range watched {
+		hasSynced = append(hasSynced, informer.HasSynced)
+	}
+	cache.WaitForCacheSync(syncCtx.Done(), hasSynced...)
+	cancelSync()
+	if ctx.Err() != nil {
+		return nil
+	}
+	for name, informer := range watched {
+		if informer.HasSynced() {
+			continue
+		}
+		watchErrsMu.Lock()
+		err := watchErrs[name]
+		watchErrsMu.Unlock()
+		if err == nil {
+			err = fmt.Errorf("informer did not sync within %s", timeout)
+		}
+		skipped = append(skipped, ListError{Resource: name, Err: err})
+	}
+	if len(skipped) > 0 && !w.Options.BestEffort {
+		return skipped.sorted()
+	}
+	for _, err := range skipped {
+		slog.Error("skipping resources", "resource", err.Resource, "error", err.Err)
+	}
+
+	w.mu.Lock()
+	if len(skipped) > 0 {
+		w.skipped = skipped.sorted()
+	}
+	w.synced = true
+	w.dirty = true
+	w.err = nil
+	w.updated = time.Now()
+	w.mu.Unlock()
+	slog.Info("watching resources", "crds", len(scan.scanned.Items))
+
+	<-ctx.Done()
+	return nil
+}
+
+// handle keeps the resources of res, served by the named CRD, that match
+// included, and the edges detected from them, up to date from informer
+// while scan is current
+func (w *Watcher) handle(informer informers.GenericInformer, scan *crdScan, name string, res GVK, included func(v1.PartialObjectMetadata) bool) error {
+	update := func(obj interface{}) {
+		meta, object, ok := watchedObject(obj, res)
+		if !ok {
+			return
+		}
+		if !included(meta) || ignored(&meta) {
+			w.remove(scan, name, meta.UID)
+			return
+		}
+		edges, err := detect(scan.detectors, meta, object)
+		if err != nil {
+			slog.Error("cannot detect dependencies", "kind", res.Kind, "namespace", meta.Namespace, "name", meta.Name, "error", err)
+			return
+		}
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		// the informers of CRDs that changed can still be stopping
+		if w.scan != scan {
+			return
+		}
+		w.resources[meta.UID] = scannedResource{meta: trimMetadata(meta), edges: edges}
+		w.changed(name)
+	}
+
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: update,
+		UpdateFunc: func(_, obj interface{}) {
+			update(obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if meta, _, ok := watchedObject(obj, res); ok {
+				w.remove(scan, name, meta.UID)
+			}
+		},
+	})
+	return err
+}
+
+// remove forgets the resource with uid, served by the named CRD, while scan is current
+func (w *Watcher) remove(scan *crdScan, name string, uid types.UID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.resources[uid]; ok && w.scan == scan {
+		delete(w.resources, uid)
+		w.changed(name)
+	}
+}
+
+// changed records that the resources of the named CRD changed and the graph
+// has to be rebuilt, w.mu is held
+func (w *Watcher) changed(name string) {
+	w.dirty = true
+	if w.synced {
+		w.updated = time.Now()
+		w.trigger = name
+	}
+}
+
+// Trigger returns the CRD whose definition or resources last changed the graph
+func (w *Watcher) Trigger() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.trigger
+}
+
+// watchedObject returns the metadata of an object of res from an informer
+// and, for full objects, the object keyed by its UID as detect takes it
+func watchedObject(obj interface{}, res GVK) (v1.PartialObjectMetadata, map[types.UID]unstructured.Unstructured, bool) {
+	var meta v1.PartialObjectMetadata
+	var objects map[types.UID]unstructured.Unstructured
+	switch o := obj.(type) {
+	case *v1.PartialObjectMetadata:
+		meta = *o
+	case *unstructured.Unstructured:
+		meta = v1.PartialObjectMetadata{ObjectMeta: v1.ObjectMeta{
+			Namespace:       o.GetNamespace(),
+			Name:            o.GetName(),
+			UID:             o.GetUID(),
+			Labels:          o.GetLabels(),
+			Annotations:     o.GetAnnotations(),
+			OwnerReferences: o.GetOwnerReferences(),
+		}}
+		objects = map[types.UID]unstructured.Unstructured{o.GetUID(): *o}
+	default:
+		return meta, nil, false
+	}
+	// the metadata API leaves out the type of every item, as in findResources
+	meta.APIVersion = res.GVR.GroupVersion().String()
+	meta.Kind = res.Kind
+	return meta, objects, true
+}
+
+// crdChanged reports whether a CRD changed in a way that changes the scan:
+// its spec, whether it is established, or the labels and annotations the scan reads
+func crdChanged(old, crd *unstructured.Unstructured) bool {
+	_, oldSkipped := notEstablished(*old)
+	_, skipped := notEstablished(*crd)
+	return old.GetGeneration() != crd.GetGeneration() || oldSkipped != skipped ||
+		old.GetLabels()[ExcludeFromBackupLabel] != crd.GetLabels()[ExcludeFromBackupLabel] ||
+		old.GetAnnotations()[DependsOnAnnotation] != crd.GetAnnotations()[DependsOnAnnotation] ||
+		ignored(old) != ignored(crd)
+}
+
+// dropManagedFields is an informer transform leaving out the managed fields
+// of objects, which the graph never reads, to keep the informer caches small
+func dropManagedFields(obj interface{}) (interface{}, error) {
+	if meta, ok := obj.(*v1.PartialObjectMetadata); ok {
+		meta.ManagedFields = nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,92 @@
+package restoreorder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/metadata"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// forbidList makes the list calls of client for resource fail as forbidden
+func forbidList(t *testing.T, client metadata.Interface, resource string) {
+	t.Helper()
+	fake, ok := client.(*metadatafake.FakeMetadataClient)
+	if !ok {
+		t.Fatalf("got metadata client %T, want a fake one", client)
+	}
+	fake.PrependReactor("list", resource, func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(action.GetResource().GroupResource(), "", errors.New("no access"))
+	})
+}
+
+// latest waits for the watcher to have a graph or an error
+func latest(t *testing.T, w *Watcher) (*Graph, error) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if graph, _, err := w.Latest(); graph != nil || err != nil {
+			return graph, err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("watcher has no graph or error")
+	return nil, nil
+}
+
+// TestWatcherForbidden checks the resources a watcher is not allowed to list
+// fail a strict watch and are skipped in a best effort one
+func TestWatcherForbidden(t *testing.T) {
+	forbidden := "kind001s.x.io"
+
+	tests := []struct {
+		name       string
+		bestEffort bool
+	}{
+		{name: "strict"},
+		{name: "best effort", bestEffort: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient, metadataClient, err := ManifestClients(chainManifests(3))
+			if err != nil {
+				t.Fatal(err)
+			}
+			forbidList(t, metadataClient, "kind001s")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			w := &Watcher{
+				Dynamic:     dynamicClient,
+				Metadata:    metadataClient,
+				Options:     Options{BestEffort: tt.bestEffort},
+				SyncTimeout: 500 * time.Millisecond,
+			}
+			go w.Run(ctx)
+
+			graph, err := latest(t, w)
+			if !tt.bestEffort {
+				listErrs := ListErrors{}
+				if !errors.As(err, &listErrs) || len(listErrs) != 1 || listErrs[0].Resource != forbidden || !apierrors.IsForbidden(listErrs[0].Err) {
+					t.Fatalf("got graph %v and error %v, want %s to be forbidden", graph, err, forbidden)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("best effort watch failed: %s", err)
+			}
+			if len(graph.Skipped) != 1 || graph.Skipped[0].Resource != forbidden || !apierrors.IsForbidden(graph.Skipped[0].Err) {
+				t.Errorf("got skipped %v, want %s to be forbidden", graph.Skipped, forbidden)
+			}
+			if got := len(graph.Resources); got != 3 {
+				t.Errorf("got %d kinds, want 3", got)
+			}
+		})
+	}
+}
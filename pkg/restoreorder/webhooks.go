@@ -0,0 +1,147 @@
+package restoreorder
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// the webhook configurations resources can be admitted by
+var (
+	ValidatingWebhookResource = schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"}
+	MutatingWebhookResource   = schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"}
+)
+
+// WebhookDependency is a resource that cannot be restored until a webhook
+// backend is running, as its CRD has a conversion webhook or a webhook
+// configuration intercepts it
+type WebhookDependency struct {
+	// Resource is the CRD name of the resource
+	Resource string
+	// Webhook is the name of the webhook configuration, empty for a conversion webhook
+	Webhook string
+	// Service is the namespace/name of the service backing the webhook, empty for a URL
+	Service string
+}
+
+// conversionWebhook returns the dependency of the resources of crd on its conversion webhook
+func conversionWebhook(crd unstructured.Unstructured) (WebhookDependency, bool) {
+	strategy, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy")
+	if strategy != "Webhook" {
+		return WebhookDependency{}, false
+	}
+	return WebhookDependency{
+		Resource: crd.GetName(),
+		Service:  webhookService(crd.Object, "spec", "conversion", "webhook", "clientConfig", "service"),
+	}, true
+}
+
+// webhookService returns the namespace/name of the service at fields
+func webhookService(obj map[string]interface{}, fields ...string) string {
+	namespace, _, _ := unstructured.NestedString(obj, append(fields, "namespace")...)
+	name, _, _ := unstructured.NestedString(obj, append(fields, "name")...)
+	if name == "" {
+		return ""
+	}
+	return namespace + "/" + name
+}
+
+// admissionWebhooks returns the scanned resources the validating and mutating
+// webhook configurations of the cluster intercept on create
+func admissionWebhooks(ctx context.Context, client dynamic.Interface, crds []unstructured.Unstructured) []WebhookDependency {
+	deps := []WebhookDependency{}
+	for _, res := range []schema.GroupVersionResource{ValidatingWebhookResource, MutatingWebhookResource} {
+		configs, err := client.Resource(res).List(ctx, v1.ListOptions{})
+		if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+			slog.Warn("cannot check webhook configurations", "resource", res.Resource, "error", err)
+			continue
+		}
+		if err != nil {
+			slog.Error("cannot list webhook configurations", "resource", res.Resource, "error", err)
+			continue
+		}
+
+		for _, config := range configs.Items {
+			webhooks, _, _ := unstructured.NestedSlice(config.Object, "webhooks")
+			for _, webhook := range webhooks {
+				webhook, ok := webhook.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				service := webhookService(webhook, "clientConfig", "service")
+				rules, _, _ := unstructured.NestedSlice(webhook, "rules")
+				for _, crd := range crds {
+					res, _, err := GetRes(crd)
+					if err != nil {
+						continue
+					}
+					if slices.ContainsFunc(rules, func(r interface{}) bool {
+						rule, ok := r.(map[string]interface{})
+						return ok && ruleMatches(rule, res.GVR.Group, res.GVR.Resource)
+					}) {
+						deps = append(deps, WebhookDependency{Resource: crd.GetName(), Webhook: config.GetName(), Service: service})
+					}
+				}
+			}
+		}
+	}
+	return deps
+}
+
+// ruleMatches reports whether a webhook rule intercepts creating resources of group
+func ruleMatches(rule map[string]interface{}, group, resource string) bool {
+	operations, _, _ := unstructured.NestedStringSlice(rule, "operations")
+	groups, _, _ := unstructured.NestedStringSlice(rule, "apiGroups")
+	resources, _, _ := unstructured.NestedStringSlice(rule, "resources")
+	return (slices.Contains(operations, "*") || slices.Contains(operations, "CREATE")) &&
+		(slices.Contains(groups, "*") || slices.Contains(groups, group)) &&
+		(slices.Contains(resources, "*") || slices.Contains(resources, resource))
+}
+
+// webhookDependencies returns the scanned resources that depend on webhooks
+func webhookDependencies(ctx context.Context, client dynamic.Interface, crds []unstructured.Unstructured) []WebhookDependency {
+	deps := []WebhookDependency{}
+	for _, crd := range crds {
+		if dep, ok := conversionWebhook(crd); ok {
+			deps = append(deps, dep)
+		}
+	}
+	deps = append(deps, admissionWebhooks(ctx, client, crds)...)
+	slices.SortFunc(deps, func(a, b WebhookDependency) int {
+		return cmp.Or(cmp.Compare(a.Resource, b.Resource), cmp.Compare(a.Webhook, b.Webhook))
+	})
+	return deps
+}
+
+// addWebhookDependencies records the resources that depend on webhooks in
+// graph and, with webhooksFirst, orders the Deployments and Services the
+// webhooks are served by before them
+func addWebhookDependencies(graph *Graph, deps []WebhookDependency, webhooksFirst bool) {
+	graph.Webhooks = append(graph.Webhooks, deps...)
+	if !webhooksFirst {
+		return
+	}
+	backends := []GVK{}
+	for _, res := range BuiltinChildResources {
+		if res.GVR.Resource == "deployments" || res.GVR.Resource == "services" {
+			backends = append(backends, res)
+		}
+	}
+	for _, dep := range graph.Webhooks {
+		kind, ok := graph.Kind(dep.Resource)
+		if !ok {
+			continue
+		}
+		for _, res := range backends {
+			addBuiltin(graph, res)
+			graph.AddEdge(kind, res.GroupKind())
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package restoreorder
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want bool
+	}{
+		{name: "create", rule: `{operations: [CREATE], apiGroups: [x.io], resources: [apps]}`, want: true},
+		{name: "any operation", rule: `{operations: ["*"], apiGroups: [x.io], resources: [apps]}`, want: true},
+		{name: "any group and resource", rule: `{operations: [CREATE, UPDATE], apiGroups: ["*"], resources: ["*"]}`, want: true},
+		{name: "update only", rule: `{operations: [UPDATE, DELETE], apiGroups: [x.io], resources: [apps]}`},
+		{name: "other group", rule: `{operations: [CREATE], apiGroups: [y.io], resources: [apps]}`},
+		{name: "other resource", rule: `{operations: [CREATE], apiGroups: [x.io], resources: [databases]}`},
+		{name: "subresource", rule: `{operations: [CREATE], apiGroups: [x.io], resources: [apps/status]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := testManifest(t, tt.rule)
+			if got := ruleMatches(rule.Object, "x.io", "apps"); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverWebhooks(t *testing.T) {
+	app := schema.GroupKind{Group: "x.io", Kind: "App"}
+	database := schema.GroupKind{Group: "x.io", Kind: "Database"}
+	cache := schema.GroupKind{Group: "y.io", Kind: "Cache"}
+	deployment := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+	service := schema.GroupKind{Kind: "Service"}
+
+	converted := testCRD(app.Group, app.Kind)
+	if err := unstructured.SetNestedField(converted.Object, map[string]interface{}{
+		"strategy": "Webhook",
+		"webhook": map[string]interface{}{
+			"clientConfig": map[string]interface{}{
+				"service": map[string]interface{}{"namespace": "x-system", "name": "x-webhook"},
+			},
+			"conversionReviewVersions": []interface{}{"v1"},
+		},
+	}, "spec", "conversion"); err != nil {
+		t.Fatal(err)
+	}
+	manifests := []unstructured.Unstructured{
+		converted,
+		testCRD(database.Group, database.Kind),
+		testCRD(cache.Group, cache.Kind),
+		testManifest(t, `
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: x-validation
+webhooks:
+- name: databases.x.io
+  clientConfig:
+    service:
+      namespace: x-system
+      name: x-validator
+  rules:
+  - operations: [CREATE, UPDATE]
+    apiGroups: [x.io]
+    apiVersions: [v1]
+    resources: [databases]
+`),
+		testManifest(t, `
+apiVersion: admissionregistration.k8s.io/v1
+kind: MutatingWebhookConfiguration
+metadata:
+  name: y-defaults
+webhooks:
+- name: caches.y.io
+  clientConfig:
+    url: https://y.example.com/mutate
+  rules:
+  - operations: [UPDATE]
+    apiGroups: [y.io]
+    apiVersions: [v1]
+    resources: [caches]
+`),
+	}
+
+	want := []WebhookDependency{
+		{Resource: "apps.x.io", Service: "x-system/x-webhook"},
+		{Resource: "databases.x.io", Webhook: "x-validation", Service: "x-system/x-validator"},
+	}
+	tests := []struct {
+		name          string
+		webhooksFirst bool
+	}{
+		{name: "recorded"},
+		{name: "webhooks first", webhooksFirst: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := discoverManifests(t, manifests, Options{WebhooksFirst: tt.webhooksFirst})
+			if !slices.Equal(graph.Webhooks, want) {
+				t.Errorf("got webhook dependencies %v, want %v", graph.Webhooks, want)
+			}
+			for _, kind := range []schema.GroupKind{app, database} {
+				for _, backend := range []schema.GroupKind{deployment, service} {
+					if _, ok := graph.Owners[kind][backend]; ok != tt.webhooksFirst {
+						t.Errorf("got %s ordered after %s %t, want %t", kind, backend, ok, tt.webhooksFirst)
+					}
+				}
+			}
+			if owners := graph.Owners[cache]; len(owners) > 0 {
+				t.Errorf("got owners %v of %s, which no webhook intercepts", owners, cache)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	apiv1 "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/api/v1"
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// NewGRPCServer returns a gRPC server serving the latest computed order with
+// the RestoreOrderService of pkg/api/v1, and server reflection so the service
+// can be explored with tools such as grpcurl
+func NewGRPCServer(source restoreorder.GraphSource) *grpc.Server {
+	srv := grpc.NewServer()
+	apiv1.RegisterRestoreOrderServiceServer(srv, &orderService{source: source})
+	reflection.Register(srv)
+	return srv
+}
+
+// orderService implements the RestoreOrderService
+type orderService struct {
+	apiv1.UnimplementedRestoreOrderServiceServer
+	source restoreorder.GraphSource
+}
+
+func (s *orderService) GetOrder(context.Context, *apiv1.GetOrderRequest) (*apiv1.GetOrderResponse, error) {
+	graph, updated, err := s.latest()
+	if err != nil {
+		return nil, err
+	}
+	priorities := graph.Priorities()
+	return &apiv1.GetOrderResponse{
+		Flag:       restoreorder.RestoreFlag,
+		Priorities: priorities,
+		Computed:   restoreorder.ParsePriorities(priorities),
+		UpdatedAt:  timestamppb.New(updated),
+	}, nil
+}
+
+func (s *orderService) GetGraph(context.Context, *apiv1.GetGraphRequest) (*apiv1.GetGraphResponse, error) {
+	graph, updated, err := s.latest()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &apiv1.GetGraphResponse{UpdatedAt: timestamppb.New(updated)}
+	for kind, name := range graph.Resources {
+		res.Kinds = append(res.Kinds, &apiv1.Kind{
+			GroupKind:  groupKind(kind),
+			Resource:   name,
+			Namespaced: graph.Namespaced[kind],
+			Count:      int64(graph.Counts[kind]),
+		})
+	}
+	slices.SortFunc(res.Kinds, func(a, b *apiv1.Kind) int {
+		return strings.Compare(a.Resource, b.Resource)
+	})
+
+	// sorted as in the JSON form of the graph, so the same graph always returns the same edges
+	for kind, owners := range graph.Owners {
+		for owner := range owners {
+			res.Edges = append(res.Edges, &apiv1.Edge{Kind: groupKind(kind), Owner: groupKind(owner)})
+		}
+	}
+	slices.SortFunc(res.Edges, func(a, b *apiv1.Edge) int {
+		return cmp.Or(
+			strings.Compare(kindString(a.Kind), kindString(b.Kind)),
+			strings.Compare(kindString(a.Owner), kindString(b.Owner)),
+		)
+	})
+	return res, nil
+}
+
+func (s *orderService) Explain(_ context.Context, req *apiv1.ExplainRequest) (*apiv1.ExplainResponse, error) {
+	graph, _, err := s.latest()
+	if err != nil {
+		return nil, err
+	}
+	explanation, err := graph.Explain(req.GetResource())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	res := &apiv1.ExplainResponse{
+		Resource: explanation.Resource,
+		Depth:    int64(explanation.Depth),
+		Forcing:  explanation.Forcing,
+	}
+	for _, chain := range explanation.Chains {
+		res.Chains = append(res.Chains, &apiv1.Chain{Resources: chain})
+	}
+	return res, nil
+}
+
+// latest returns the latest graph, failing as unavailable when no graph
+// has been discovered yet
+func (s *orderService) latest() (*restoreorder.Graph, time.Time, error) {
+	graph, updated, err := s.source.Latest()
+	if graph == nil {
+		msg := "restore order not computed yet"
+		if err != nil {
+			msg = fmt.Sprintf("%s: %s", msg, err)
+		}
+		return nil, time.Time{}, status.Error(codes.Unavailable, msg)
+	}
+	return graph, updated, nil
+}
+
+func groupKind(kind schema.GroupKind) *apiv1.GroupKind {
+	return &apiv1.GroupKind{Group: kind.Group, Kind: kind.Kind}
+}
+
+// kindString returns kind in Kind.group form
+func kindString(kind *apiv1.GroupKind) string {
+	return schema.GroupKind{Group: kind.GetGroup(), Kind: kind.GetKind()}.String()
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	apiv1 "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/api/v1"
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// failedSource has no graph, as a scan that failed
+type failedSource struct {
+	err error
+}
+
+func (s failedSource) Latest() (*restoreorder.Graph, time.Time, error) {
+	return nil, time.Time{}, s.err
+}
+
+// grpcClient serves source over an in-memory connection and returns a client of it
+func grpcClient(t *testing.T, source restoreorder.GraphSource) apiv1.RestoreOrderServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	srv := NewGRPCServer(source)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return apiv1.NewRestoreOrderServiceClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	cluster := schema.GroupKind{Group: "example.io", Kind: "Cluster"}
+	database := schema.GroupKind{Group: "example.io", Kind: "Database"}
+	graph := restoreorder.NewGraph()
+	graph.DefaultOrder = []string{"namespaces"}
+	graph.AddEdge(database, cluster)
+	graph.Resources[cluster] = "clusters.example.io"
+	graph.Resources[database] = "databases.example.io"
+	graph.Namespaced[database] = true
+	graph.Counts[database] = 3
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	client := grpcClient(t, staticSource{graph: graph, updated: updated})
+
+	order, err := client.GetOrder(ctx, &apiv1.GetOrderRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPriorities := "namespaces,clusters.example.io,databases.example.io"
+	if order.GetFlag() != restoreorder.RestoreFlag || order.GetPriorities() != wantPriorities {
+		t.Errorf("got order %s=%s, want %s=%s", order.GetFlag(), order.GetPriorities(), restoreorder.RestoreFlag, wantPriorities)
+	}
+	if got := strings.Join(order.GetComputed(), ","); got != wantPriorities {
+		t.Errorf("got computed entries %s, want %s", got, wantPriorities)
+	}
+	if got := order.GetUpdatedAt().AsTime(); !got.Equal(updated) {
+		t.Errorf("got update time %s, want %s", got, updated)
+	}
+
+	g, err := client.GetGraph(ctx, &apiv1.GetGraphRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []string{}
+	for _, kind := range g.GetKinds() {
+		kinds = append(kinds, kind.GetResource())
+	}
+	if want := []string{"clusters.example.io", "databases.example.io"}; !slices.Equal(kinds, want) {
+		t.Errorf("got kinds %v, want %v", kinds, want)
+	}
+	if db := g.GetKinds()[1]; !db.GetNamespaced() || db.GetCount() != 3 || db.GetGroupKind().GetKind() != "Database" {
+		t.Errorf("got kind %v, want 3 namespaced databases", db)
+	}
+	if len(g.GetEdges()) != 1 || kindString(g.GetEdges()[0].GetKind()) != database.String() || kindString(g.GetEdges()[0].GetOwner()) != cluster.String() {
+		t.Errorf("got edges %v, want %s owned by %s", g.GetEdges(), database, cluster)
+	}
+
+	explanation, err := client.Explain(ctx, &apiv1.ExplainRequest{Resource: "databases.example.io"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.GetDepth() != 1 || !slices.Equal(explanation.GetForcing(), []string{"clusters.example.io"}) ||
+		len(explanation.GetChains()) != 1 || !slices.Equal(explanation.GetChains()[0].GetResources(), []string{"clusters.example.io", "databases.example.io"}) {
+		t.Errorf("got explanation %v, want databases at depth 1 under clusters", explanation)
+	}
+	_, err = client.Explain(ctx, &apiv1.ExplainRequest{Resource: "widgets.example.io"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("got error %v explaining an unknown resource, want not found", err)
+	}
+}
+
+func TestGRPCServerNoGraph(t *testing.T) {
+	client := grpcClient(t, failedSource{err: errors.New("cannot list CRDs")})
+	_, err := client.GetOrder(context.Background(), &apiv1.GetOrderRequest{})
+	if status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "cannot list CRDs") {
+		t.Errorf("got error %v, want unavailable with the scan error", err)
+	}
+	_, err = client.GetGraph(context.Background(), &apiv1.GetGraphRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("got error %v, want unavailable", err)
+	}
+}
@@ -0,0 +1,106 @@
+// Package server serves the computed restore order to other systems.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// Order is the structured form of the computed order served on /order.json
+type Order struct {
+	// Flag is the velero server flag the priorities are passed to
+	Flag string `json:"flag"`
+	// Priorities is the full restore-resource-priorities value
+	Priorities string `json:"priorities"`
+	// Computed are the entries of Priorities in the order velero restores
+	// them, split so clients do not parse the flag value
+	Computed []string `json:"computed"`
+	// UpdatedAt is when the graph was last discovered
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NewHandler returns a handler serving the latest computed order on
+//
+//	GET /order       the restore-resource-priorities flag
+//	GET /order.json  the order as JSON
+//	GET /graph.dot   the ownership graph in DOT format
+func NewHandler(source restoreorder.GraphSource) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /order", func(w http.ResponseWriter, r *http.Request) {
+		graph, _, ok := latest(w, source)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%s=%s\n", restoreorder.RestoreFlag, graph.Priorities())
+	})
+
+	mux.HandleFunc("GET /order.json", func(w http.ResponseWriter, r *http.Request) {
+		graph, updated, ok := latest(w, source)
+		if !ok {
+			return
+		}
+		priorities := graph.Priorities()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(Order{
+			Flag:       restoreorder.RestoreFlag,
+			Priorities: priorities,
+			Computed:   restoreorder.ParsePriorities(priorities),
+			UpdatedAt:  updated,
+		}); err != nil {
+			slog.Error("cannot write response", "path", r.URL.Path, "error", err)
+		}
+	})
+
+	mux.HandleFunc("GET /graph.dot", func(w http.ResponseWriter, r *http.Request) {
+		graph, _, ok := latest(w, source)
+		if !ok {
+			return
+		}
+		buf := &bytes.Buffer{}
+		if err := graph.WriteDOT(buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Write(buf.Bytes())
+	})
+
+	return mux
+}
+
+// latest returns the latest graph, responding with an error when
+// no graph has been discovered yet
+func latest(w http.ResponseWriter, source restoreorder.GraphSource) (*restoreorder.Graph, time.Time, bool) {
+	graph, updated, err := source.Latest()
+	if graph == nil {
+		msg := "restore order not computed yet"
+		if err != nil {
+			msg = fmt.Sprintf("%s: %s", msg, err)
+		}
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return nil, time.Time{}, false
+	}
+	return graph, updated, true
+}
+
+// WithProfiling serves the runtime profiles of the process on /debug/pprof/
+// alongside the routes of handler
+func WithProfiling(handler http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/", handler)
+	return mux
+}
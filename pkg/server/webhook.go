@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// RestoreWebhookPath is the path the Restore mutating webhook is served on
+const RestoreWebhookPath = "/mutate-velero-io-v1-restore"
+
+// RestoreMutator points the Velero Restores created without resource
+// modifiers at a ConfigMap holding the resource modifiers of the latest
+// computed graph, see restoreorder.Graph.ResourceModifiers, which it applies
+// in the namespace of the Restore first.
+// velero only reads the restore order from the flag of its server, the
+// modifiers make the restored custom resources reconcile in that order
+type RestoreMutator struct {
+	Source restoreorder.GraphSource
+	// Dynamic applies the ConfigMaps
+	Dynamic dynamic.Interface
+	// ConfigMap is the name of the ConfigMap the resource modifiers are applied to
+	ConfigMap string
+	// Cluster is the cluster the graph is discovered from, recorded as provenance
+	Cluster string
+}
+
+// Handle sets spec.resourceModifier on created Restores. restores are never
+// rejected: those that already have resource modifiers, or created before
+// a graph is computed or when the ConfigMap cannot be applied, are admitted
+// unchanged. dry runs are patched without applying the ConfigMap
+func (m *RestoreMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	restore := &unstructured.Unstructured{}
+	if err := restore.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if _, ok, _ := unstructured.NestedMap(restore.Object, "spec", "resourceModifier"); ok {
+		return admission.Allowed("restore already has resource modifiers")
+	}
+
+	graph, _, err := m.Source.Latest()
+	if graph == nil {
+		slog.Warn("admitting restore without a computed graph", "namespace", req.Namespace, "name", req.Name, "error", err)
+		return admission.Allowed("no graph computed yet")
+	}
+
+	if req.DryRun == nil || !*req.DryRun {
+		provenance := restoreorder.NewProvenance(graph, m.Cluster)
+		if err := graph.ApplyResourceModifiers(ctx, m.Dynamic, req.Namespace, m.ConfigMap, provenance); err != nil {
+			slog.Error("admitting restore without resource modifiers", "namespace", req.Namespace, "name", req.Name, "error", err)
+			return admission.Allowed("cannot apply resource modifiers")
+		}
+	}
+
+	if err := unstructured.SetNestedStringMap(restore.Object, map[string]string{
+		"kind": "ConfigMap",
+		"name": m.ConfigMap,
+	}, "spec", "resourceModifier"); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	mutated, err := json.Marshal(restore.Object)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+	restoreordertesting "github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder/testing"
+)
+
+// staticSource always serves the same graph
+type staticSource struct {
+	graph   *restoreorder.Graph
+	updated time.Time
+}
+
+func (s staticSource) Latest() (*restoreorder.Graph, time.Time, error) {
+	return s.graph, s.updated, nil
+}
+
+func TestRestoreMutator(t *testing.T) {
+	graph := restoreordertesting.Discover(t, "../restoreorder/testdata/stable", restoreorder.Options{})
+	dryRun := true
+
+	tests := []struct {
+		name        string
+		graph       *restoreorder.Graph
+		operation   admissionv1.Operation
+		spec        map[string]interface{}
+		dryRun      *bool
+		applyErr    error
+		wantPatched bool
+		wantApplied bool
+	}{
+		{name: "create", graph: graph, operation: admissionv1.Create, wantPatched: true, wantApplied: true},
+		{name: "dry run", graph: graph, operation: admissionv1.Create, dryRun: &dryRun, wantPatched: true},
+		{name: "no graph yet", operation: admissionv1.Create},
+		{name: "update", graph: graph, operation: admissionv1.Update},
+		{
+			name:      "own resource modifiers",
+			graph:     graph,
+			operation: admissionv1.Create,
+			spec:      map[string]interface{}{"resourceModifier": map[string]interface{}{"kind": "ConfigMap", "name": "mine"}},
+		},
+		{
+			name:        "configmap cannot be applied",
+			graph:       graph,
+			operation:   admissionv1.Create,
+			applyErr:    apierrors.NewForbidden(restoreorder.ConfigMapResource.GroupResource(), "", errors.New("no access")),
+			wantApplied: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			applied := 0
+			client.PrependReactor("patch", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				applied++
+				if ns := action.GetNamespace(); ns != "velero" {
+					t.Errorf("applied configmap in namespace %q, want velero", ns)
+				}
+				return true, nil, tt.applyErr
+			})
+
+			spec := tt.spec
+			if spec == nil {
+				spec = map[string]interface{}{"backupName": "b"}
+			}
+			raw, err := json.Marshal(map[string]interface{}{
+				"apiVersion": "velero.io/v1",
+				"kind":       "Restore",
+				"metadata":   map[string]interface{}{"name": "r", "namespace": "velero"},
+				"spec":       spec,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: tt.operation,
+				Namespace: "velero",
+				Name:      "r",
+				DryRun:    tt.dryRun,
+				Object:    runtime.RawExtension{Raw: raw},
+			}}
+
+			m := &RestoreMutator{Source: staticSource{graph: tt.graph}, Dynamic: client, ConfigMap: "modifiers"}
+			resp := m.Handle(context.Background(), req)
+			if !resp.Allowed {
+				t.Fatalf("restore was not admitted: %v", resp.Result)
+			}
+			if applied > 0 != tt.wantApplied {
+				t.Errorf("applied the configmap %d times, want applied %t", applied, tt.wantApplied)
+			}
+			if !tt.wantPatched {
+				if len(resp.Patches) > 0 {
+					t.Errorf("got patches %v, want none", resp.Patches)
+				}
+				return
+			}
+			if len(resp.Patches) != 1 || resp.Patches[0].Path != "/spec/resourceModifier" {
+				t.Fatalf("got patches %v, want the resource modifier set", resp.Patches)
+			}
+			want := map[string]interface{}{"kind": "ConfigMap", "name": "modifiers"}
+			if got, _ := json.Marshal(resp.Patches[0].Value); string(got) != mustMarshal(t, want) {
+				t.Errorf("got resource modifier %s, want %s", got, mustMarshal(t, want))
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
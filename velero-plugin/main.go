@@ -0,0 +1,225 @@
+// Command velero-plugin is a Velero plugin that makes Velero restore the
+// owners of a custom resource before the resource itself.
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+// ActionName is the name the restore item action is registered as
+const ActionName = "whoisyourdaddy.io/restore-owners-first"
+
+// rediscoverInterval is how often the CRDs are listed again when the graph
+// is missing the kind of an item or owner, as its CRD may have been restored since
+const rediscoverInterval = 10 * time.Second
+
+// builtinResources are the built-in resources velero restores in an order of
+// its own, pods before the controllers owning them so the controllers adopt
+// the restored pods rather than create new ones. custom resources owning
+// them are left to that order too
+var builtinResources = []string{
+	"pods",
+	"replicationcontrollers",
+	"replicasets.apps",
+	"deployments.apps",
+	"statefulsets.apps",
+	"daemonsets.apps",
+	"jobs.batch",
+	"cronjobs.batch",
+}
+
+func main() {
+	framework.NewServer().
+		RegisterRestoreItemAction(ActionName, newOwnersFirstAction).
+		Serve()
+}
+
+// ownersFirstAction returns the custom resource owners of every restored
+// custom resource as additional items, which velero restores before the item
+type ownersFirstAction struct {
+	log      logrus.FieldLogger
+	discover func(ctx context.Context) (*restoreorder.Graph, error)
+
+	mu         sync.Mutex
+	graph      *restoreorder.Graph
+	discovered time.Time
+}
+
+// newOwnersFirstAction returns the action for the cluster being restored
+// to. the graph is not discovered up front: in a disaster recovery restore
+// the cluster holds none of the restored CRDs yet. velero restores the CRDs
+// in a backup before any custom resource, so the graph is discovered once
+// the first item is restored and again when it is missing a kind
+func newOwnersFirstAction(log logrus.FieldLogger) (interface{}, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot build client: %w", err)
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot build client: %w", err)
+	}
+	discover := func(ctx context.Context) (*restoreorder.Graph, error) {
+		return discoverKinds(ctx, client)
+	}
+	return &ownersFirstAction{log: log, discover: discover}, nil
+}
+
+// discoverKinds returns a graph holding only the resources and scopes of the
+// kinds the CRDs of the cluster serve, which is all Execute needs: the owners
+// are read from the restored items, so no custom resource is listed
+func discoverKinds(ctx context.Context, client dynamic.Interface) (*restoreorder.Graph, error) {
+	crds, err := client.Resource(restoreorder.CRDResource).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list CRDs: %w", err)
+	}
+	graph := restoreorder.NewGraph()
+	for _, crd := range crds.Items {
+		if crd.GetAnnotations()[restoreorder.IgnoreAnnotation] == "true" {
+			continue
+		}
+		res, namespaced, err := restoreorder.GetRes(crd)
+		if err != nil {
+			// a CRD that is not established yet serves nothing
+			continue
+		}
+		if slices.Contains(restoreorder.DefaultIgnoreGroups, res.GVR.Group) {
+			continue
+		}
+		graph.Resources[res.GroupKind()] = res.GVR.GroupResource().String()
+		graph.Namespaced[res.GroupKind()] = namespaced
+	}
+	return graph, nil
+}
+
+// AppliesTo selects every resource but the built-in ones velero orders
+// itself, Execute leaves the items that are not custom resources unchanged
+func (a *ownersFirstAction) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{ExcludedResources: builtinResources}, nil
+}
+
+// Execute adds the custom resource owners of the item as additional items.
+// the owners are read from the item as it was backed up, as additional
+// items are read from the backup under their original namespace
+func (a *ownersFirstAction) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	output := velero.NewRestoreItemActionExecuteOutput(input.Item)
+
+	backedUp := input.Item
+	if input.ItemFromBackup != nil {
+		backedUp = input.ItemFromBackup
+	}
+	item := &unstructured.Unstructured{Object: backedUp.UnstructuredContent()}
+	refs := item.GetOwnerReferences()
+	if len(refs) == 0 {
+		return output, nil
+	}
+
+	kinds := []schema.GroupKind{item.GroupVersionKind().GroupKind()}
+	if !customGroup(kinds[0].Group) {
+		// built-in resources are restored in velero's own order
+		return output, nil
+	}
+	for _, ref := range refs {
+		kinds = append(kinds, schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind())
+	}
+	graph := a.graphFor(kinds)
+	if graph == nil {
+		return output, nil
+	}
+	if _, ok := graph.Resources[kinds[0]]; !ok {
+		// only the owners of custom resources are restored first
+		return output, nil
+	}
+
+	for i, ref := range refs {
+		owner := kinds[i+1]
+		log := a.log.WithFields(logrus.Fields{
+			"item":  item.GetName(),
+			"owner": ref.Name,
+			"kind":  owner.String(),
+		})
+		name, ok := graph.Resources[owner]
+		if !ok {
+			log.Debug("skipping owner that is not a custom resource")
+			continue
+		}
+
+		namespace := ""
+		if graph.Namespaced[owner] {
+			namespace = item.GetNamespace()
+		}
+		output.AdditionalItems = append(output.AdditionalItems, velero.ResourceIdentifier{
+			GroupResource: schema.ParseGroupResource(name),
+			Namespace:     namespace,
+			Name:          ref.Name,
+		})
+		log.Debug("restoring owner first")
+	}
+
+	return output, nil
+}
+
+// graphFor returns the discovered graph, discovering it again, at most every
+// rediscoverInterval, when it is missing one of kinds. it returns nil when
+// the graph was never discovered
+func (a *ownersFirstAction) graphFor(kinds []schema.GroupKind) *restoreorder.Graph {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.graph != nil && (!missingKind(a.graph, kinds) || time.Since(a.discovered) < rediscoverInterval) {
+		return a.graph
+	}
+	if a.graph == nil && time.Since(a.discovered) < rediscoverInterval {
+		return nil
+	}
+
+	a.discovered = time.Now()
+	graph, err := a.discover(context.Background())
+	if err != nil {
+		a.log.WithError(err).Warn("cannot discover custom resource kinds")
+		return a.graph
+	}
+	a.log.WithField("kinds", len(graph.Resources)).Info("discovered custom resource kinds")
+	a.graph = graph
+	return a.graph
+}
+
+// missingKind reports whether one of kinds could be served by a CRD but is
+// not in graph
+func missingKind(graph *restoreorder.Graph, kinds []schema.GroupKind) bool {
+	for _, kind := range kinds {
+		if _, ok := graph.Resources[kind]; !ok && customGroup(kind.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinGroups are the groups the API server serves itself that the
+// client-go scheme does not register
+var builtinGroups = []string{"apiextensions.k8s.io", "apiregistration.k8s.io"}
+
+// customGroup reports whether group can be served by a CRD, whose groups
+// must hold a dot, unlike the built-in core, apps or batch groups. dotted
+// built-in groups, such as networking.k8s.io, are not, while approved
+// groups ending in k8s.io, such as gateway.networking.k8s.io, still are
+func customGroup(group string) bool {
+	return strings.Contains(group, ".") && !scheme.Scheme.IsGroupRegistered(group) && !slices.Contains(builtinGroups, group)
+}
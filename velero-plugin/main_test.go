@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/alam0rt/whoisyourdaddyandwhatdoeshedo/pkg/restoreorder"
+)
+
+var (
+	databaseCluster = schema.GroupKind{Group: "db.example.io", Kind: "DatabaseCluster"}
+	database        = schema.GroupKind{Group: "db.example.io", Kind: "Database"}
+	tenant          = schema.GroupKind{Group: "tenancy.example.io", Kind: "Tenant"}
+)
+
+// testGraph is the graph of a cluster serving the CRDs of kinds
+func testGraph(kinds ...schema.GroupKind) *restoreorder.Graph {
+	graph := restoreorder.NewGraph()
+	resources := map[schema.GroupKind]string{
+		databaseCluster: "databaseclusters.db.example.io",
+		database:        "databases.db.example.io",
+		tenant:          "tenants.tenancy.example.io",
+	}
+	for _, kind := range kinds {
+		graph.Resources[kind] = resources[kind]
+		graph.Namespaced[kind] = kind != tenant
+	}
+	return graph
+}
+
+// testAction returns an action discovering the graphs in turn, counting
+// how often it discovered one
+func testAction(graphs ...*restoreorder.Graph) (*ownersFirstAction, *int) {
+	discovered := 0
+	discover := func(context.Context) (*restoreorder.Graph, error) {
+		discovered++
+		if discovered > len(graphs) {
+			return nil, errors.New("no graph left")
+		}
+		return graphs[discovered-1], nil
+	}
+	return &ownersFirstAction{log: logrus.New(), discover: discover}, &discovered
+}
+
+// testItem is a resource in namespace shop of kind named name with owners
+func testItem(kind schema.GroupKind, name string, owners ...v1.OwnerReference) *unstructured.Unstructured {
+	item := &unstructured.Unstructured{}
+	item.SetGroupVersionKind(kind.WithVersion("v1"))
+	item.SetNamespace("shop")
+	item.SetName(name)
+	item.SetOwnerReferences(owners)
+	return item
+}
+
+func testOwner(kind schema.GroupKind, name string) v1.OwnerReference {
+	return v1.OwnerReference{APIVersion: kind.WithVersion("v1").GroupVersion().String(), Kind: kind.Kind, Name: name}
+}
+
+func execute(t *testing.T, a *ownersFirstAction, item *unstructured.Unstructured) []velero.ResourceIdentifier {
+	t.Helper()
+	output, err := a.Execute(&velero.RestoreItemActionExecuteInput{Item: item, ItemFromBackup: item})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.UpdatedItem != item {
+		t.Errorf("got updated item %v, want the item unchanged", output.UpdatedItem)
+	}
+	return output.AdditionalItems
+}
+
+func TestExecute(t *testing.T) {
+	replicaSet := schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}
+	deployment := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+
+	tests := []struct {
+		name string
+		item *unstructured.Unstructured
+		want []velero.ResourceIdentifier
+	}{
+		{
+			name: "namespaced owner",
+			item: testItem(database, "orders", testOwner(databaseCluster, "main")),
+			want: []velero.ResourceIdentifier{{GroupResource: schema.GroupResource{Group: "db.example.io", Resource: "databaseclusters"}, Namespace: "shop", Name: "main"}},
+		},
+		{
+			name: "cluster-scoped owner",
+			item: testItem(databaseCluster, "main", testOwner(tenant, "acme")),
+			want: []velero.ResourceIdentifier{{GroupResource: schema.GroupResource{Group: "tenancy.example.io", Resource: "tenants"}, Name: "acme"}},
+		},
+		{
+			name: "several owners",
+			item: testItem(database, "orders", testOwner(databaseCluster, "main"), testOwner(tenant, "acme")),
+			want: []velero.ResourceIdentifier{
+				{GroupResource: schema.GroupResource{Group: "db.example.io", Resource: "databaseclusters"}, Namespace: "shop", Name: "main"},
+				{GroupResource: schema.GroupResource{Group: "tenancy.example.io", Resource: "tenants"}, Name: "acme"},
+			},
+		},
+		{
+			name: "built-in owner",
+			item: testItem(database, "orders", testOwner(deployment, "operator"), testOwner(databaseCluster, "main")),
+			want: []velero.ResourceIdentifier{{GroupResource: schema.GroupResource{Group: "db.example.io", Resource: "databaseclusters"}, Namespace: "shop", Name: "main"}},
+		},
+		{
+			name: "pod owned by a replica set",
+			item: testItem(schema.GroupKind{Kind: "Pod"}, "web-1", testOwner(replicaSet, "web")),
+		},
+		{
+			name: "replica set owned by a deployment",
+			item: testItem(replicaSet, "web", testOwner(deployment, "web")),
+		},
+		{
+			name: "built-in resource owned by a custom resource",
+			item: testItem(schema.GroupKind{Kind: "Secret"}, "orders-credentials", testOwner(database, "orders")),
+		},
+		{
+			name: "no owners",
+			item: testItem(database, "orders"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := testAction(testGraph(databaseCluster, database, tenant))
+			if got := execute(t, a, tt.item); !slices.Equal(got, tt.want) {
+				t.Errorf("got additional items %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExecuteItemFromBackup checks the owners are read from the item as it
+// was backed up, rather than as it is restored
+func TestExecuteItemFromBackup(t *testing.T) {
+	a, _ := testAction(testGraph(databaseCluster, database))
+	backedUp := testItem(database, "orders", testOwner(databaseCluster, "main"))
+	restored := testItem(database, "orders")
+
+	output, err := a.Execute(&velero.RestoreItemActionExecuteInput{Item: restored, ItemFromBackup: backedUp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output.AdditionalItems) != 1 || output.AdditionalItems[0].Name != "main" {
+		t.Errorf("got additional items %v, want the owner of the backed up item", output.AdditionalItems)
+	}
+}
+
+// TestExecuteRediscovers checks the graph is discovered again when a CRD
+// it is missing was restored since, but not more than every rediscoverInterval
+func TestExecuteRediscovers(t *testing.T) {
+	a, discovered := testAction(testGraph(database), testGraph(databaseCluster, database))
+	item := testItem(database, "orders", testOwner(databaseCluster, "main"))
+
+	if got := execute(t, a, item); len(got) != 0 {
+		t.Errorf("got additional items %v before the owner CRD was restored", got)
+	}
+	if got := execute(t, a, item); len(got) != 0 || *discovered != 1 {
+		t.Errorf("got additional items %v after %d discoveries, want the graph discovered once", got, *discovered)
+	}
+
+	a.discovered = a.discovered.Add(-rediscoverInterval)
+	if got := execute(t, a, item); len(got) != 1 || *discovered != 2 {
+		t.Errorf("got additional items %v after %d discoveries, want the owner once discovered again", got, *discovered)
+	}
+
+	// built-in kinds never send the graph to be discovered again
+	a.discovered = a.discovered.Add(-rediscoverInterval)
+	execute(t, a, testItem(database, "orders", testOwner(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "operator")))
+	if *discovered != 2 {
+		t.Errorf("discovered the graph %d times for a built-in owner, want 2", *discovered)
+	}
+
+	// nor do built-in kinds of dotted groups
+	roleBinding := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}
+	execute(t, a, testItem(roleBinding, "view", testOwner(schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}, "web")))
+	execute(t, a, testItem(database, "orders", testOwner(roleBinding, "view")))
+	if *discovered != 2 {
+		t.Errorf("discovered the graph %d times for built-in dotted groups, want 2", *discovered)
+	}
+}
+
+func TestCustomGroup(t *testing.T) {
+	tests := []struct {
+		group string
+		want  bool
+	}{
+		{group: "", want: false},
+		{group: "apps", want: false},
+		{group: "networking.k8s.io", want: false},
+		{group: "rbac.authorization.k8s.io", want: false},
+		{group: "storage.k8s.io", want: false},
+		{group: "apiextensions.k8s.io", want: false},
+		{group: "apiregistration.k8s.io", want: false},
+		{group: "gateway.networking.k8s.io", want: true},
+		{group: "snapshot.storage.k8s.io", want: true},
+		{group: "db.example.io", want: true},
+	}
+	for _, tt := range tests {
+		if got := customGroup(tt.group); got != tt.want {
+			t.Errorf("got custom group %t for %q, want %t", got, tt.group, tt.want)
+		}
+	}
+}
+
+// TestExecuteDiscoverError checks a failed discovery leaves items unchanged
+// rather than failing the restore
+func TestExecuteDiscoverError(t *testing.T) {
+	a, discovered := testAction()
+	item := testItem(database, "orders", testOwner(databaseCluster, "main"))
+
+	if got := execute(t, a, item); len(got) != 0 {
+		t.Errorf("got additional items %v without a graph", got)
+	}
+	execute(t, a, item)
+	if *discovered != 1 {
+		t.Errorf("discovered %d times, want failures to be retried every rediscoverInterval", *discovered)
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	a, _ := testAction()
+	selector, err := a.AppliesTo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range []string{"pods", "replicasets.apps", "deployments.apps", "jobs.batch"} {
+		if !slices.Contains(selector.ExcludedResources, res) {
+			t.Errorf("selector %v does not exclude %s, which velero restores in its own order", selector, res)
+		}
+	}
+}
+
+// testCRD is the CRD serving kind, namespaced unless cluster
+func testCRD(kind schema.GroupKind, plural string, cluster bool) *unstructured.Unstructured {
+	scope := "Namespaced"
+	if cluster {
+		scope = "Cluster"
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": restoreorder.CRDResource.GroupVersion().String(),
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": plural + "." + kind.Group},
+		"spec": map[string]interface{}{
+			"group": kind.Group,
+			"names": map[string]interface{}{"kind": kind.Kind, "plural": plural},
+			"scope": scope,
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1", "served": true, "storage": true},
+			},
+		},
+	}}
+}
+
+// TestDiscoverKinds checks the graph holds the resources and scopes of the
+// CRDs without listing any custom resource
+func TestDiscoverKinds(t *testing.T) {
+	ignored := testCRD(schema.GroupKind{Group: "x.io", Kind: "Noise"}, "noises", false)
+	ignored.SetAnnotations(map[string]string{restoreorder.IgnoreAnnotation: "true"})
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{restoreorder.CRDResource: "CustomResourceDefinitionList"},
+		testCRD(databaseCluster, "databaseclusters", false),
+		testCRD(database, "databases", false),
+		testCRD(tenant, "tenants", true),
+		ignored,
+	)
+
+	graph, err := discoverKinds(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testGraph(databaseCluster, database, tenant)
+	if !maps.Equal(graph.Resources, want.Resources) || !maps.Equal(graph.Namespaced, want.Namespaced) {
+		t.Errorf("got resources %v and scopes %v, want %v and %v", graph.Resources, graph.Namespaced, want.Resources, want.Namespaced)
+	}
+	for _, action := range client.Actions() {
+		if action.GetResource() != restoreorder.CRDResource {
+			t.Errorf("got %s of %s, want only CRDs listed", action.GetVerb(), action.GetResource())
+		}
+	}
+}